@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"time"
+)
+
+// stage is a step of the shutdown, the stages run in order so every one
+// flushes into the next still running: requests into the event bus, the bus
+// into the webhook dispatcher and all of them into the repositories
+type stage struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// components are what the shutdown stops, tracer is nil without tracing
+type components struct {
+	server     shutdowner
+	broker     interface{ Close() }
+	bus        interface{ Close() }
+	dispatcher interface{ Stop() }
+	repos      []interface{}
+	tracer     shutdowner
+}
+
+// stages lists the shutdown of the components in order. The broker is closed
+// first, so the streams don't hold the HTTP shutdown until the deadline
+func (c components) stages() []stage {
+	return []stage{
+		{"http", func(ctx context.Context) error {
+			c.broker.Close()
+			return c.server.Shutdown(ctx)
+		}},
+		{"events", blocking(c.bus.Close)},
+		{"webhooks", blocking(c.dispatcher.Stop)},
+		{"repositories", closeAll(c.repos...)},
+		{"tracing", func(ctx context.Context) error {
+			if c.tracer == nil {
+				return nil
+			}
+			// exports the spans still in the batch
+			return c.tracer.Shutdown(ctx)
+		}},
+	}
+}
+
+// blocking makes a stage of a stop function not taking a context, the
+// shutdown stops waiting for it at the deadline
+func blocking(stop func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			stop()
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// closeAll closes the repositories holding resources, the in-memory ones
+// have nothing to close
+func closeAll(repos ...interface{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, repo := range repos {
+			if closer, ok := repo.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					errs = append(errs, fmt.Errorf("%T: %w", repo, err))
+				}
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// shutdown runs the stages until the deadline of the context, the stages
+// left after it are skipped
+func shutdown(ctx context.Context, logger *zap.SugaredLogger, stages ...stage) error {
+	var errs []error
+	for i, st := range stages {
+		if ctx.Err() != nil {
+			for _, skipped := range stages[i:] {
+				logger.Errorw("shutdown stage skipped", "type", "STOP", "stage", skipped.name)
+			}
+			errs = append(errs, ctx.Err())
+			break
+		}
+		start := time.Now()
+		err := st.stop(ctx)
+		if err != nil {
+			logger.Errorw("shutdown stage failed", "type", "STOP", "stage", st.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", st.name, err))
+			continue
+		}
+		logger.Infow("shutdown stage done", "type", "STOP", "stage", st.name, "time", time.Since(start))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// calls logs what the parts of the server were asked to stop, in order
+type calls struct {
+	names []string
+	mu    sync.Mutex
+}
+
+func (c *calls) add(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names = append(c.names, name)
+}
+
+func (c *calls) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return strings.Join(c.names, " ")
+}
+
+// part is a stopped component, it waits for hang to be closed if it's set
+type part struct {
+	name  string
+	calls *calls
+	hang  chan struct{}
+}
+
+func (p part) call() {
+	p.calls.add(p.name)
+	if p.hang != nil {
+		<-p.hang
+	}
+}
+
+func (p part) Close() { p.call() }
+
+func (p part) Stop() { p.call() }
+
+func (p part) Shutdown(ctx context.Context) error {
+	p.calls.add(p.name)
+	return nil
+}
+
+type repo struct {
+	name  string
+	calls *calls
+	err   error
+}
+
+func (r repo) Close() error {
+	r.calls.add(r.name)
+	return r.err
+}
+
+func testComponents(c *calls) components {
+	return components{
+		server:     part{name: "server", calls: c},
+		broker:     part{name: "broker", calls: c},
+		bus:        part{name: "bus", calls: c},
+		dispatcher: part{name: "dispatcher", calls: c},
+		// the in-memory repositories have nothing to close
+		repos:  []interface{}{repo{name: "users", calls: c}, struct{}{}, repo{name: "mailer", calls: c}},
+		tracer: part{name: "tracer", calls: c},
+	}
+}
+
+func TestShutdownOrder(t *testing.T) {
+	c := &calls{}
+	err := shutdown(context.Background(), zap.NewNop().Sugar(), testComponents(c).stages()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.String(), "broker server bus dispatcher users mailer tracer"; got != want {
+		t.Errorf("stopped %q, want %q", got, want)
+	}
+
+	// without tracing there is nothing to flush the spans from
+	c = &calls{}
+	comps := testComponents(c)
+	comps.tracer = nil
+	if err = shutdown(context.Background(), zap.NewNop().Sugar(), comps.stages()...); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.String(), "broker server bus dispatcher users mailer"; got != want {
+		t.Errorf("stopped %q without tracing, want %q", got, want)
+	}
+}
+
+// The failed stage doesn't keep the next ones from running
+func TestShutdownFailedStage(t *testing.T) {
+	c := &calls{}
+	comps := testComponents(c)
+	comps.repos[0] = repo{name: "users", calls: c, err: errors.New("disk full")}
+	err := shutdown(context.Background(), zap.NewNop().Sugar(), comps.stages()...)
+	if err == nil || !strings.Contains(err.Error(), "repositories: main.repo: disk full") {
+		t.Errorf("error = %v, want the error of the repositories", err)
+	}
+	if got, want := c.String(), "broker server bus dispatcher users mailer tracer"; got != want {
+		t.Errorf("stopped %q, want %q", got, want)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	c := &calls{}
+	comps := testComponents(c)
+	hang := make(chan struct{})
+	defer close(hang)
+	comps.dispatcher = part{name: "dispatcher", calls: c, hang: hang}
+	core, logs := observer.New(zap.InfoLevel)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := shutdown(ctx, zap.New(core).Sugar(), comps.stages()...)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("shutdown took %v, want it to give up at the deadline", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "webhooks:") {
+		t.Errorf("error = %v, want the deadline of the webhooks", err)
+	}
+	if got, want := c.String(), "broker server bus dispatcher"; got != want {
+		t.Errorf("stopped %q, want the stages after the deadline skipped", got)
+	}
+	skipped := make([]string, 0)
+	for _, entry := range logs.FilterMessage("shutdown stage skipped").All() {
+		skipped = append(skipped, entry.ContextMap()["stage"].(string))
+	}
+	if got := strings.Join(skipped, " "); got != "repositories tracing" {
+		t.Errorf("skipped stages %q, want repositories and tracing", got)
+	}
+}
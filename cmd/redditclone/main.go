@@ -1,51 +1,370 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/gorilla/mux"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/config"
+	"myredditclone/pkg/events"
 	"myredditclone/pkg/handlers"
+	"myredditclone/pkg/health"
+	"myredditclone/pkg/hidden"
+	"myredditclone/pkg/mail"
+	"myredditclone/pkg/metrics"
+	"myredditclone/pkg/middleware"
+	"myredditclone/pkg/notifications"
+	"myredditclone/pkg/oauth"
+	"myredditclone/pkg/oidc"
 	"myredditclone/pkg/posts"
+	"myredditclone/pkg/profile"
+	"myredditclone/pkg/reset"
+	"myredditclone/pkg/saved"
 	"myredditclone/pkg/session"
+	"myredditclone/pkg/stream"
+	"myredditclone/pkg/tokens"
+	"myredditclone/pkg/tracing"
+	"myredditclone/pkg/twofactor"
 	"myredditclone/pkg/user"
+	"myredditclone/pkg/verify"
+	"myredditclone/pkg/version"
+	"myredditclone/pkg/webhooks"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	userRepo := user.NewUserRepository()
-	postRepo := posts.NewPostMemoryRepository()
-	sm := session.NewSessionManager()
-	zapLogger, err := zap.NewProduction()
+	cfg, opts, err := config.Load(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid config:")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.PrintConfig {
+		out, err := json.MarshalIndent(cfg.Redacted(), "", "  ")
+		if err != nil {
+			exit("print config", err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+	session.Key = []byte(cfg.Auth.JWTKey)
+	session.TokenTTL = time.Duration(cfg.Auth.JWTTTL)
+	reset.TokenTTL = time.Duration(cfg.Auth.ResetTTL)
+	verify.TokenTTL = time.Duration(cfg.Auth.VerifyTTL)
 
+	broker := stream.NewBroker()
+	notificationRepo := stream.NewNotificationRepo(notifications.NewNotificationMemoryRepository(), broker)
+	sm := session.NewSessionManager()
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level, err = zap.ParseAtomicLevel(cfg.Log.Level)
+	if err != nil {
+		exit("log level", err)
+	}
+	zapLogger, err := zapConfig.Build()
 	if err != nil {
-		fmt.Println(err)
+		exit("logger", err)
 	}
 	defer func() {
+		// stderr can't be synced when it's a terminal, that's no loss
 		err := zapLogger.Sync()
-		if err != nil {
-			fmt.Println(err)
+		if err != nil && !errors.Is(err, syscall.ENOTTY) && !errors.Is(err, syscall.EINVAL) {
+			exit("flush the logs", err)
 		}
 	}()
 
 	logger := zapLogger.Sugar()
+	if cfg.Dev {
+		logger.Warnw("dev mode, the insecure defaults are allowed", "type", "START")
+	}
+	var tracerProvider *sdktrace.TracerProvider
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		exporter, err := tracing.NewExporter(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.Endpoint, os.Stdout)
+		if err != nil {
+			logger.Errorw("trace exporter failed", "type", "START", "error", err)
+			_ = zapLogger.Sync()
+			os.Exit(1)
+		}
+		tracerProvider = tracing.NewProvider(exporter, cfg.Tracing.ServiceName, version.Version, cfg.Tracing.SampleRatio)
+	}
+	tracing.Install(tracerProvider)
+	webhookRepo := webhooks.NewWebhookMemoryRepository()
+	dispatcher := webhooks.NewDispatcher(webhookRepo, logger)
+	dispatcher.Start(4)
+
+	bus := events.NewBus(logger)
+	appMetrics := metrics.New()
+	bus.OnDrop = appMetrics.Dropped
+	bus.Subscribe("metrics", appMetrics.Handle)
+	bus.Subscribe("stream", broker.Handle)
+	karmaRepo := profile.NewKarmaMemoryRepository()
+	bus.Subscribe("karma", profile.NewTracker(karmaRepo).Handle)
+	blockRepo := blocks.NewBlockMemoryRepository()
+	notifier := notifications.NewNotifier(notificationRepo, blockRepo)
+	bus.SubscribeAsync("notifications", 256, notifier.Handle)
+	bus.SubscribeAsync("webhooks", 256, dispatcher.Handle)
+	userRepo := events.NewUserRepo(tracing.NewUserRepo(metrics.NewUserRepo(user.NewUserRepository(), appMetrics)), bus)
+	postRepo := events.NewPostRepo(tracing.NewPostRepo(metrics.NewPostRepo(posts.NewPostMemoryRepository(), appMetrics)), bus)
+	tokenRepo := tokens.NewTokenMemoryRepository()
+	tokenAuthenticator := tokens.NewAuthenticator(tokenRepo, userRepo)
+	appRepo := oauth.NewAppMemoryRepository()
+	grantRepo := oauth.NewGrantMemoryRepository()
+	oauthServer := oauth.NewServer(appRepo, grantRepo, userRepo)
+	sm.Tokens = append(sm.Tokens, tokenAuthenticator, oauthServer)
+	sm.Users = userRepo
+
+	admins := user.Admins{Repo: userRepo, Logins: cfg.Auth.Admins}
+	bus.Subscribe("admins", events.On(func(e events.UserRegistered) error {
+		return admins.GrantTo(context.Background(), e.User.Login)
+	}))
+	if err := admins.Grant(context.Background()); err != nil {
+		logger.Errorw("admin bootstrap failed", "type", "START", "error", err)
+		_ = zapLogger.Sync()
+		os.Exit(1)
+	}
+
+	var mailer mail.Sender = mail.NewFileSender(cfg.Mail.From, cfg.Mail.SinkPath)
+	if cfg.Mail.SMTPAddr != "" {
+		mailer = mail.NewSMTPSender(cfg.Mail.SMTPAddr, cfg.Mail.From, cfg.Mail.SMTPUser, cfg.Mail.SMTPPassword)
+	}
+	mailer = mail.NewQueue(mailer, 256, logger)
+	notifier.Users = userRepo
+	notifier.Mailer = mailer
+	verifyRepo := verify.NewVerifyMemoryRepository()
+	verifier := verify.NewVerifier(verifyRepo, mailer, userRepo, cfg.Server.PublicURL)
+	twoFactorRepo := twofactor.NewTwoFactorMemoryRepository()
+	twoFactorService := twofactor.NewService(twoFactorRepo, "redditclone")
 	userHandler := handlers.UserHandler{
-		Logger:   logger,
-		Sessions: sm,
-		UserRepo: userRepo,
+		Logger:    logger,
+		Sessions:  sm,
+		UserRepo:  userRepo,
+		Verifier:  verifier,
+		TwoFactor: twoFactorService,
+		Linker:    oidc.NewLinker(userRepo),
+		Bus:       bus,
 	}
+	if cfg.OIDC.Issuer != "" {
+		userHandler.SSO = oidc.NewRelyingParty(oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+		})
+	}
+	postService := posts.NewPostService(postRepo, blockRepo)
+	postService.RequireVerified(userRepo, cfg.Posts.VerifiedOnly...)
+	savedRepo := saved.NewSavedMemoryRepository()
+	hiddenRepo := hidden.NewHiddenMemoryRepository()
 	postHandler := handlers.PostHandler{
+		Service:    postService,
+		SavedRepo:  savedRepo,
+		HiddenRepo: hiddenRepo,
+		BlockRepo:  blockRepo,
+		UserRepo:   userRepo,
+		Logger:     logger,
+	}
+	notificationHandler := handlers.NotificationHandler{
+		NotificationsRepo: notificationRepo,
+		Logger:            logger,
+	}
+	streamHandler := handlers.StreamHandler{
+		Broker:    broker,
+		PostsRepo: postRepo,
+		Sessions:  sm,
+		Heartbeat: 15 * time.Second,
+		Logger:    logger,
+	}
+	liveHandler := handlers.LiveHandler{
+		Broker:    broker,
 		PostsRepo: postRepo,
+		BlockRepo: blockRepo,
+		Sessions:  sm,
 		Logger:    logger,
 	}
-	addHandlersMux := handlers.GenerateRoutes(userHandler, postHandler)
-	addProcessingRouter := handlers.PostProcess(addHandlersMux, sm, logger)
+	webhookHandler := handlers.WebhookHandler{
+		WebhooksRepo: webhookRepo,
+		Logger:       logger,
+	}
+	savedHandler := handlers.SavedHandler{
+		Service:   postService,
+		SavedRepo: savedRepo,
+		BlockRepo: blockRepo,
+		Logger:    logger,
+	}
+	hiddenHandler := handlers.HiddenHandler{
+		Service:    postService,
+		HiddenRepo: hiddenRepo,
+		BlockRepo:  blockRepo,
+		Logger:     logger,
+	}
+	blockHandler := handlers.BlockHandler{
+		BlockRepo: blockRepo,
+		UserRepo:  userRepo,
+		Logger:    logger,
+	}
+	profileHandler := handlers.ProfileHandler{
+		UserRepo:  userRepo,
+		KarmaRepo: karmaRepo,
+		Service:   postService,
+		Logger:    logger,
+	}
+	resetRepo := reset.NewResetMemoryRepository()
+	accountHandler := handlers.AccountHandler{
+		UserRepo:          userRepo,
+		Sessions:          sm,
+		Service:           postService,
+		Verifier:          verifier,
+		TokensRepo:        tokenRepo,
+		OAuth:             oauthServer,
+		ResetRepo:         resetRepo,
+		WebhooksRepo:      webhookRepo,
+		TwoFactor:         twoFactorService,
+		SavedRepo:         savedRepo,
+		HiddenRepo:        hiddenRepo,
+		BlockRepo:         blockRepo,
+		NotificationsRepo: notificationRepo,
+		Logger:            logger,
+	}
+	resetHandler := handlers.ResetHandler{
+		UserRepo:  userRepo,
+		ResetRepo: resetRepo,
+		Mailer:    mailer,
+		Sessions:  sm,
+		// five emails an hour per client IP and per account
+		Limiter: middleware.NewRateLimiter(5/time.Hour.Seconds(), 5),
+		Logger:  logger,
+		BaseURL: cfg.Server.PublicURL,
+	}
+	twoFactorHandler := handlers.TwoFactorHandler{
+		Service: twoFactorService,
+		Logger:  logger,
+	}
+	tokenHandler := handlers.TokenHandler{
+		Authenticator: tokenAuthenticator,
+		TokensRepo:    tokenRepo,
+		Logger:        logger,
+	}
+	oauthHandler := handlers.OAuthHandler{
+		Server: oauthServer,
+		Logger: logger,
+	}
+	addHandlersMux := handlers.GenerateRoutes(handlers.Handlers{
+		User:         userHandler,
+		Post:         postHandler,
+		Notification: notificationHandler,
+		Stream:       streamHandler,
+		Live:         liveHandler,
+		Webhook:      webhookHandler,
+		Saved:        savedHandler,
+		Hidden:       hiddenHandler,
+		Block:        blockHandler,
+		Profile:      profileHandler,
+		Account:      accountHandler,
+		Reset:        resetHandler,
+		TwoFactor:    twoFactorHandler,
+		Token:        tokenHandler,
+		OAuth:        oauthHandler,
+	}, cfg.Server.StaticDir)
+	extra := []mux.MiddlewareFunc{middleware.Route}
+	if cfg.RateLimit.RPS > 0 {
+		extra = append(extra, middleware.NewRateLimiter(cfg.RateLimit.RPS, cfg.RateLimit.Burst).Limit)
+	}
+	addProcessingRouter := handlers.PostProcess(addHandlersMux, sm, logger, extra...)
+	healthHandler := handlers.HealthHandler{
+		Checks: []health.Check{
+			{Name: "users", Target: userRepo},
+			{Name: "posts", Target: postRepo},
+			{Name: "notifications", Target: notificationRepo},
+			{Name: "webhooks", Target: webhookRepo},
+			{Name: "karma", Target: karmaRepo},
+			{Name: "blocks", Target: blockRepo},
+			{Name: "tokens", Target: tokenRepo},
+			{Name: "saved", Target: savedRepo},
+			{Name: "hidden", Target: hiddenRepo},
+			{Name: "oauthApps", Target: appRepo},
+			{Name: "oauthGrants", Target: grantRepo},
+			{Name: "verifications", Target: verifyRepo},
+			{Name: "resets", Target: resetRepo},
+			{Name: "twoFactor", Target: twoFactorRepo},
+			{Name: "eventBus", Target: bus},
+			{Name: "webhookDispatcher", Target: dispatcher},
+			{Name: "streamBroker", Target: broker},
+		},
+		Logger: logger,
+	}
 
-	addr := ":8080"
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      handlers.WithProbes(healthHandler, appMetrics.Handler(), middleware.Metrics(appMetrics)(middleware.Tracing(addProcessingRouter))),
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
+	}
+	tls := cfg.Server.TLS.CertFile != ""
 	logger.Infow("starting server",
 		"type", "START",
-		"addr", addr,
+		"addr", srv.Addr,
+		"tls", tls,
+		"storage", cfg.Storage.Backend,
+		"version", version.Version,
 	)
-	err = http.ListenAndServe(addr, addProcessingRouter)
-	if err != nil {
-		fmt.Println(err)
+	serveErr := make(chan error, 1)
+	go func() {
+		if tls {
+			serveErr <- srv.ListenAndServeTLS(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		} else {
+			serveErr <- srv.ListenAndServe()
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	failed := false
+	select {
+	case err = <-serveErr:
+		logger.Errorw("server failed", "type", "STOP", "error", err)
+		failed = true
+	case sig := <-signals:
+		logger.Infow("shutting down", "type", "STOP", "signal", sig.String())
 	}
+	// the second signal kills the process without waiting
+	signal.Stop(signals)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	stopped := components{
+		server:     srv,
+		broker:     broker,
+		bus:        bus,
+		dispatcher: dispatcher,
+		repos: []interface{}{userRepo, postRepo, notificationRepo, webhookRepo, karmaRepo,
+			blockRepo, tokenRepo, savedRepo, hiddenRepo, appRepo, grantRepo, verifyRepo, resetRepo,
+			twoFactorRepo, mailer},
+	}
+	if tracerProvider != nil {
+		stopped.tracer = tracerProvider
+	}
+	err = shutdown(ctx, logger, stopped.stages()...)
+	if err != nil || failed {
+		_ = zapLogger.Sync()
+		os.Exit(1)
+	}
+	logger.Infow("server stopped", "type", "STOP")
+}
+
+// exit reports the error of the startup before the logger is there
+func exit(stage string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", stage, err)
+	os.Exit(1)
 }
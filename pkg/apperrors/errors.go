@@ -0,0 +1,67 @@
+package apperrors
+
+import "errors"
+
+// Kinds of the domain errors, every domain error matches one of them with errors.Is
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrForbidden       = errors.New("forbidden")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrConflict        = errors.New("conflict")
+	ErrValidation      = errors.New("validation failed")
+)
+
+type Error struct {
+	Kind  error
+	Msg   string
+	Param string //the field the error is about, if any
+	Value string
+}
+
+func New(kind error, msg string) error {
+	return &Error{
+		Kind: kind,
+		Msg:  msg,
+	}
+}
+
+func Validation(param, value, msg string) error {
+	return &Error{
+		Kind:  ErrValidation,
+		Msg:   msg,
+		Param: param,
+		Value: value,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// Kind returns the kind of the error or nil for unexpected errors
+func Kind(err error) error {
+	for _, kind := range []error{ErrNotFound, ErrForbidden, ErrUnauthenticated, ErrConflict, ErrValidation} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
+
+// WithField binds the domain error to the request field it was caused by
+func WithField(err error, param, value string) error {
+	kind := Kind(err)
+	if kind == nil {
+		return err
+	}
+	return &Error{
+		Kind:  kind,
+		Msg:   err.Error(),
+		Param: param,
+		Value: value,
+	}
+}
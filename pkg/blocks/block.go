@@ -0,0 +1,15 @@
+package blocks
+
+type Item struct {
+	Login   string `json:"username"` //Login of the blocked user
+	Blocked string `json:"blocked"`
+}
+
+type BlockRepo interface {
+	Block(login, blocked string) error
+	Unblock(login, blocked string) error
+	IsBlocked(login, blocked string) (bool, error)
+	GetByUser(login string) ([]Item, error)
+	// DeleteByUser drops the blocks of the user and the blocks of the user by the others
+	DeleteByUser(login string) error
+}
@@ -0,0 +1,88 @@
+package blocks
+
+import (
+	"myredditclone/pkg/apperrors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotBlocked = apperrors.New(apperrors.ErrNotFound, "Current user isn't blocked")
+	ErrBlockSelf  = apperrors.Validation("username", "", "You can't block yourself")
+)
+
+var _ BlockRepo = NewBlockMemoryRepository()
+
+type BlockMemoryRepository struct {
+	data map[string]map[string]Item
+	mu   sync.RWMutex
+}
+
+func NewBlockMemoryRepository() *BlockMemoryRepository {
+	return &BlockMemoryRepository{
+		data: map[string]map[string]Item{},
+	}
+}
+
+func (repo *BlockMemoryRepository) Block(login, blocked string) error {
+	if login == blocked {
+		return ErrBlockSelf
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	items, ok := repo.data[login]
+	if !ok {
+		items = map[string]Item{}
+		repo.data[login] = items
+	}
+	if _, ok = items[blocked]; ok {
+		return nil
+	}
+	items[blocked] = Item{
+		Login:   blocked,
+		Blocked: time.Now().Format("2006-01-02T15:04:05.000"),
+	}
+	return nil
+}
+
+func (repo *BlockMemoryRepository) Unblock(login, blocked string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, ok := repo.data[login][blocked]; !ok {
+		return ErrNotBlocked
+	}
+	delete(repo.data[login], blocked)
+	return nil
+}
+
+// IsBlocked reports whether the user with login has blocked the other one
+func (repo *BlockMemoryRepository) IsBlocked(login, blocked string) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	_, ok := repo.data[login][blocked]
+	return ok, nil
+}
+
+func (repo *BlockMemoryRepository) GetByUser(login string) ([]Item, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	res := make([]Item, 0, len(repo.data[login]))
+	for _, item := range repo.data[login] {
+		res = append(res, item)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Blocked > res[j].Blocked
+	})
+	return res, nil
+}
+
+func (repo *BlockMemoryRepository) DeleteByUser(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.data, login)
+	for _, items := range repo.data {
+		delete(items, login)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package blocks
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBlock(t *testing.T) {
+	repo := NewBlockMemoryRepository()
+	if err := repo.Block("alice", "alice"); !errors.Is(err, ErrBlockSelf) {
+		t.Errorf("block yourself: error = %v, want ErrBlockSelf", err)
+	}
+	for _, blocked := range []string{"bob", "carol", "bob"} {
+		if err := repo.Block("alice", blocked); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Block("carol", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := repo.GetByUser("alice"); len(items) != 2 {
+		t.Errorf("blocked by alice = %+v, want bob and carol once", items)
+	}
+	// the block works one way only
+	for _, c := range []struct {
+		login, blocked string
+		want           bool
+	}{
+		{"alice", "bob", true},
+		{"bob", "alice", false},
+		{"carol", "alice", true},
+	} {
+		if got, _ := repo.IsBlocked(c.login, c.blocked); got != c.want {
+			t.Errorf("%v blocked %v = %v, want %v", c.login, c.blocked, got, c.want)
+		}
+	}
+
+	if err := repo.Unblock("alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Unblock("alice", "bob"); !errors.Is(err, ErrNotBlocked) {
+		t.Errorf("unblock twice: error = %v, want ErrNotBlocked", err)
+	}
+
+	// the deleted user neither blocks nor is blocked
+	if err := repo.DeleteByUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := repo.GetByUser("alice"); len(items) != 0 {
+		t.Errorf("blocked by the deleted user = %+v, want none", items)
+	}
+	if got, _ := repo.IsBlocked("carol", "alice"); got {
+		t.Error("the deleted user is still blocked by carol")
+	}
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const StorageMemory = "memory"
+
+// DevJWTKey is the default JWT key, it's known to everybody, so it's only
+// good for the dev mode
+const DevJWTKey = "osfhvjfblkvbke"
+
+type TLS struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+// Duration is written as "1h30m" in the config file
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+type Server struct {
+	Addr            string   `json:"addr"`
+	PublicURL       string   `json:"publicUrl"` //scheme and host of the links in emails
+	TLS             TLS      `json:"tls"`
+	StaticDir       string   `json:"staticDir"`
+	ReadTimeout     Duration `json:"readTimeout"`
+	WriteTimeout    Duration `json:"writeTimeout"` //the streams aren't limited by it
+	IdleTimeout     Duration `json:"idleTimeout"`
+	ShutdownTimeout Duration `json:"shutdownTimeout"` //to drain the requests and flush the workers
+}
+
+type Storage struct {
+	Backend string `json:"backend"`
+}
+
+type Auth struct {
+	JWTKey    string   `json:"jwtKey"`
+	JWTTTL    Duration `json:"jwtTtl"`
+	ResetTTL  Duration `json:"resetTtl"`
+	VerifyTTL Duration `json:"verifyTtl"`
+	Admins    []string `json:"admins"` //logins granted the admin role at startup and on registration
+}
+
+// RateLimit is per client IP, zero RPS turns it off
+type RateLimit struct {
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
+}
+
+type Log struct {
+	Level string `json:"level"`
+}
+
+// Mail is sent by SMTP if its address is set, otherwise it's written to SinkPath
+type Mail struct {
+	From         string `json:"from"`
+	SMTPAddr     string `json:"smtpAddr"`
+	SMTPUser     string `json:"smtpUser"`
+	SMTPPassword string `json:"smtpPassword"`
+	SinkPath     string `json:"sinkPath"` //stdout when empty
+}
+
+type OIDC struct {
+	Issuer       string `json:"issuer"` //SSO is off when empty
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	RedirectURL  string `json:"redirectUrl"`
+}
+
+// Tracing sends the spans to Exporter: none, stdout or otlp
+type Tracing struct {
+	Exporter    string  `json:"exporter"`
+	Endpoint    string  `json:"endpoint"` //of the OTLP collector, OTEL_EXPORTER_OTLP_ENDPOINT when empty
+	SampleRatio float64 `json:"sampleRatio"`
+	ServiceName string  `json:"serviceName"`
+}
+
+type Posts struct {
+	VerifiedOnly []string `json:"verifiedOnly"` //categories for the users with verified emails
+}
+
+type Config struct {
+	Dev       bool      `json:"dev"` //allows the insecure defaults for local runs
+	Server    Server    `json:"server"`
+	Storage   Storage   `json:"storage"`
+	Auth      Auth      `json:"auth"`
+	RateLimit RateLimit `json:"rateLimit"`
+	Log       Log       `json:"log"`
+	Mail      Mail      `json:"mail"`
+	OIDC      OIDC      `json:"oidc"`
+	Tracing   Tracing   `json:"tracing"`
+	Posts     Posts     `json:"posts"`
+}
+
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            ":8080",
+			PublicURL:       "http://localhost:8080",
+			StaticDir:       "static",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(2 * time.Minute),
+			ShutdownTimeout: Duration(20 * time.Second),
+		},
+		Storage: Storage{Backend: StorageMemory},
+		Auth: Auth{
+			JWTKey:    DevJWTKey,
+			JWTTTL:    Duration(30 * 24 * time.Hour),
+			ResetTTL:  Duration(time.Hour),
+			VerifyTTL: Duration(48 * time.Hour),
+		},
+		RateLimit: RateLimit{RPS: 0, Burst: 20},
+		Log:       Log{Level: "info"},
+		Mail:      Mail{From: "noreply@redditclone.local"},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "redditclone",
+		},
+		Posts: Posts{VerifiedOnly: []string{}}, //no restriction unless the operator opts in
+	}
+}
+
+// Validate returns all the problems of the config at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	_, _, err := net.SplitHostPort(c.Server.Addr)
+	check(err == nil, "server.addr: %q isn't host:port", c.Server.Addr)
+	public, err := url.Parse(c.Server.PublicURL)
+	check(err == nil && (public.Scheme == "http" || public.Scheme == "https") && public.Host != "" &&
+		public.RawQuery == "" && public.Fragment == "",
+		"server.publicUrl: %q isn't an http(s) URL", c.Server.PublicURL)
+	check((c.Server.TLS.CertFile == "") == (c.Server.TLS.KeyFile == ""), "server.tls: certFile and keyFile go together")
+	for name, path := range map[string]string{"server.tls.certFile": c.Server.TLS.CertFile, "server.tls.keyFile": c.Server.TLS.KeyFile} {
+		if path != "" {
+			_, err = os.Stat(path)
+			check(err == nil, "%s: %v", name, err)
+		}
+	}
+	info, err := os.Stat(c.Server.StaticDir)
+	check(err == nil && info.IsDir(), "server.staticDir: %q isn't a directory", c.Server.StaticDir)
+	check(c.Server.ReadTimeout >= 0, "server.readTimeout: can't be negative")
+	check(c.Server.WriteTimeout >= 0, "server.writeTimeout: can't be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idleTimeout: can't be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout: must be positive")
+	check(c.Storage.Backend == StorageMemory, "storage.backend: %q is unknown, only %q is supported", c.Storage.Backend, StorageMemory)
+	check(c.Auth.JWTKey != "", "auth.jwtKey: is empty")
+	check(c.Auth.JWTKey != DevJWTKey || c.Dev, "auth.jwtKey: the default key is only allowed with dev")
+	check(c.Auth.JWTTTL > 0, "auth.jwtTtl: must be positive")
+	check(c.Auth.ResetTTL > 0, "auth.resetTtl: must be positive")
+	check(c.Auth.VerifyTTL > 0, "auth.verifyTtl: must be positive")
+	check(c.RateLimit.RPS >= 0, "rateLimit.rps: can't be negative")
+	check(c.RateLimit.RPS == 0 || c.RateLimit.Burst >= 1, "rateLimit.burst: must be at least 1")
+	_, err = zapcore.ParseLevel(c.Log.Level)
+	check(err == nil, "log.level: %q is unknown", c.Log.Level)
+	check(strings.Contains(c.Mail.From, "@"), "mail.from: %q isn't an email", c.Mail.From)
+	if c.Mail.SMTPAddr != "" {
+		_, _, err = net.SplitHostPort(c.Mail.SMTPAddr)
+		check(err == nil, "mail.smtpAddr: %q isn't host:port", c.Mail.SMTPAddr)
+	}
+	if c.OIDC.Issuer != "" {
+		check(c.OIDC.ClientID != "", "oidc.clientId: is required with oidc.issuer")
+		check(c.OIDC.RedirectURL != "", "oidc.redirectUrl: is required with oidc.issuer")
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		check(false, "tracing.exporter: %q is unknown, use none, stdout or otlp", c.Tracing.Exporter)
+	}
+	if c.Tracing.Endpoint != "" {
+		endpoint, err := url.Parse(c.Tracing.Endpoint)
+		check(err == nil && endpoint.Scheme != "" && endpoint.Host != "", "tracing.endpoint: %q isn't a URL", c.Tracing.Endpoint)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio: must be from 0 to 1")
+	check(c.Tracing.ServiceName != "", "tracing.serviceName: is empty")
+	return errors.Join(errs...)
+}
+
+const redacted = "***"
+
+// Redacted is the config safe to print
+func (c Config) Redacted() Config {
+	for _, secret := range []*string{&c.Auth.JWTKey, &c.Mail.SMTPPassword, &c.OIDC.ClientSecret} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return c
+}
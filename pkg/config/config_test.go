@@ -0,0 +1,182 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// valid is the config passing Validate, the tests break it one way at a time
+func valid(t *testing.T) Config {
+	cfg := Default()
+	cfg.Server.StaticDir = t.TempDir()
+	cfg.Auth.JWTKey = "0123456789abcdef0123456789abcdef"
+	return cfg
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	static := t.TempDir()
+	path := writeFile(t, `{
+		"server": {"addr": ":1", "staticDir": "`+static+`", "shutdownTimeout": "5s"},
+		"auth": {"jwtKey": "from the file"},
+		"log": {"level": "debug"},
+		"rateLimit": {"rps": 1}
+	}`)
+	t.Setenv("REDDITCLONE_SERVER_ADDR", ":2")
+	t.Setenv("REDDITCLONE_LOG_LEVEL", "warn")
+	t.Setenv("REDDITCLONE_AUTH_ADMINS", "alice, bob")
+
+	cfg, opts, err := Load([]string{"-config", path, "-server.addr", ":3"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Path != path {
+		t.Errorf("path = %q, want %q", opts.Path, path)
+	}
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"flag over env and file", cfg.Server.Addr, ":3"},
+		{"env over file", cfg.Log.Level, "warn"},
+		{"env list", strings.Join(cfg.Auth.Admins, ","), "alice,bob"},
+		{"file over default", cfg.RateLimit.RPS, 1.0},
+		{"file duration", cfg.Server.ShutdownTimeout, Duration(5 * time.Second)},
+		{"file secret", cfg.Auth.JWTKey, "from the file"},
+		{"default", cfg.RateLimit.Burst, 20},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	static := t.TempDir()
+	cases := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"missing file", []string{"-config", filepath.Join(static, "none.json")}, nil, "config file"},
+		{"unknown field", []string{"-config", writeFile(t, `{"server": {"port": 80}}`)}, nil, `unknown field "port"`},
+		{"bad env", nil, map[string]string{"REDDITCLONE_AUTH_JWTTTL": "forever"}, "env REDDITCLONE_AUTH_JWTTTL"},
+		{"bad flag", []string{"-dev", "maybe"}, nil, "flag -dev"},
+		{"invalid", []string{"-rateLimit.rps", "-1"}, nil, "rateLimit.rps"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			args := append([]string{"-dev", "true", "-server.staticDir", static}, c.args...)
+			_, _, err := Load(args, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("error = %v, want %q", err, c.want)
+			}
+		})
+	}
+}
+
+// The default JWT key is in the source, so anybody could sign tokens with it
+func TestLoadDefaultKeyNeedsDev(t *testing.T) {
+	static := t.TempDir()
+	_, _, err := Load([]string{"-server.staticDir", static}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "auth.jwtKey") {
+		t.Errorf("default key without dev: error = %v, want auth.jwtKey", err)
+	}
+
+	t.Setenv("REDDITCLONE_DEV", "true")
+	cfg, _, err := Load([]string{"-server.staticDir", static}, io.Discard)
+	if err != nil {
+		t.Fatalf("default key with dev: %v", err)
+	}
+	if !cfg.Dev || cfg.Auth.JWTKey != DevJWTKey {
+		t.Errorf("dev = %v, key = %q, want the dev key", cfg.Dev, cfg.Auth.JWTKey)
+	}
+
+	_, _, err = Load([]string{"-server.staticDir", static, "-auth.jwtKey", ""}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "auth.jwtKey: is empty") {
+		t.Errorf("empty key with dev: error = %v, want auth.jwtKey", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := valid(t).Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+	cases := []struct {
+		want   string
+		change func(c *Config)
+	}{
+		{"server.addr", func(c *Config) { c.Server.Addr = "8080" }},
+		{"server.publicUrl", func(c *Config) { c.Server.PublicURL = "localhost:8080" }},
+		{"server.tls", func(c *Config) { c.Server.TLS.CertFile = "cert.pem" }},
+		{"server.staticDir", func(c *Config) { c.Server.StaticDir = filepath.Join(c.Server.StaticDir, "none") }},
+		{"server.shutdownTimeout", func(c *Config) { c.Server.ShutdownTimeout = 0 }},
+		{"storage.backend", func(c *Config) { c.Storage.Backend = "postgres" }},
+		{"auth.jwtKey", func(c *Config) { c.Auth.JWTKey = "" }},
+		{"auth.jwtKey", func(c *Config) { c.Auth.JWTKey = DevJWTKey }},
+		{"auth.jwtTtl", func(c *Config) { c.Auth.JWTTTL = 0 }},
+		{"rateLimit.burst", func(c *Config) { c.RateLimit.RPS, c.RateLimit.Burst = 1, 0 }},
+		{"log.level", func(c *Config) { c.Log.Level = "loud" }},
+		{"mail.from", func(c *Config) { c.Mail.From = "nobody" }},
+		{"oidc.clientId", func(c *Config) { c.OIDC.Issuer = "https://id.example.com" }},
+		{"tracing.exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }},
+		{"tracing.sampleRatio", func(c *Config) { c.Tracing.SampleRatio = 2 }},
+	}
+	for _, c := range cases {
+		cfg := valid(t)
+		c.change(&cfg)
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("error = %v, want %v", err, c.want)
+		}
+	}
+
+	// all the problems come at once
+	cfg := valid(t)
+	cfg.Server.Addr = "8080"
+	cfg.Log.Level = "loud"
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "server.addr") || !strings.Contains(err.Error(), "log.level") {
+		t.Errorf("error = %v, want both server.addr and log.level", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := valid(t)
+	cfg.Mail.SMTPPassword = "smtp secret"
+	got := cfg.Redacted()
+	for name, secret := range map[string]string{
+		"auth.jwtKey":       got.Auth.JWTKey,
+		"mail.smtpPassword": got.Mail.SMTPPassword,
+	} {
+		if secret != redacted {
+			t.Errorf("%s = %q, want it redacted", name, secret)
+		}
+	}
+	if got.OIDC.ClientSecret != "" {
+		t.Errorf("empty oidc.clientSecret = %q, want it left empty", got.OIDC.ClientSecret)
+	}
+	if cfg.Auth.JWTKey == redacted || cfg.Mail.SMTPPassword != "smtp secret" {
+		t.Error("Redacted changed the original config")
+	}
+	if got.Server != cfg.Server {
+		t.Errorf("server = %+v, want it as is", got.Server)
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the env vars, e.g. REDDITCLONE_SERVER_ADDR for server.addr
+const EnvPrefix = "REDDITCLONE_"
+
+// field is a setting available from env vars and flags by its key
+type field struct {
+	key   string
+	ptr   interface{}
+	usage string
+}
+
+func fields(c *Config) []field {
+	return []field{
+		{"dev", &c.Dev, "allow the insecure defaults for local runs: true or false"},
+		{"server.addr", &c.Server.Addr, "address to listen on"},
+		{"server.publicUrl", &c.Server.PublicURL, "URL the users reach the server at, for the links in emails"},
+		{"server.tls.certFile", &c.Server.TLS.CertFile, "TLS certificate, HTTPS is served when set"},
+		{"server.tls.keyFile", &c.Server.TLS.KeyFile, "TLS private key"},
+		{"server.staticDir", &c.Server.StaticDir, "directory of the frontend"},
+		{"server.readTimeout", &c.Server.ReadTimeout, "limit to read a request, 0 is unlimited"},
+		{"server.writeTimeout", &c.Server.WriteTimeout, "limit to write a response, 0 is unlimited"},
+		{"server.idleTimeout", &c.Server.IdleTimeout, "how long keep-alive connections wait for requests"},
+		{"server.shutdownTimeout", &c.Server.ShutdownTimeout, "deadline to drain the requests and stop the workers"},
+		{"storage.backend", &c.Storage.Backend, "storage backend: memory"},
+		{"auth.jwtKey", &c.Auth.JWTKey, "key signing the JWTs"},
+		{"auth.jwtTtl", &c.Auth.JWTTTL, "lifetime of the JWTs"},
+		{"auth.resetTtl", &c.Auth.ResetTTL, "lifetime of the password reset tokens"},
+		{"auth.verifyTtl", &c.Auth.VerifyTTL, "lifetime of the email verification tokens"},
+		{"auth.admins", &c.Auth.Admins, "comma separated logins granted the admin role"},
+		{"rateLimit.rps", &c.RateLimit.RPS, "requests per second per client IP, 0 is unlimited"},
+		{"rateLimit.burst", &c.RateLimit.Burst, "requests a client can make at once"},
+		{"log.level", &c.Log.Level, "log level: debug, info, warn, error"},
+		{"mail.from", &c.Mail.From, "sender of the emails"},
+		{"mail.smtpAddr", &c.Mail.SMTPAddr, "SMTP server host:port, emails go to mail.sinkPath when empty"},
+		{"mail.smtpUser", &c.Mail.SMTPUser, "SMTP user"},
+		{"mail.smtpPassword", &c.Mail.SMTPPassword, "SMTP password"},
+		{"mail.sinkPath", &c.Mail.SinkPath, "file to write the emails to, stdout when empty"},
+		{"oidc.issuer", &c.OIDC.Issuer, "OpenID provider for SSO, off when empty"},
+		{"oidc.clientId", &c.OIDC.ClientID, "client ID at the OpenID provider"},
+		{"oidc.clientSecret", &c.OIDC.ClientSecret, "client secret at the OpenID provider"},
+		{"oidc.redirectUrl", &c.OIDC.RedirectURL, "SSO callback URL, ending with /api/login/sso/callback"},
+		{"tracing.exporter", &c.Tracing.Exporter, "where the spans go: none, stdout or otlp"},
+		{"tracing.endpoint", &c.Tracing.Endpoint, "OTLP/HTTP collector URL, OTEL_EXPORTER_OTLP_ENDPOINT when empty"},
+		{"tracing.sampleRatio", &c.Tracing.SampleRatio, "share of the new traces to sample, from 0 to 1"},
+		{"tracing.serviceName", &c.Tracing.ServiceName, "service name in the traces"},
+		{"posts.verifiedOnly", &c.Posts.VerifiedOnly, "comma separated categories only verified users post in, none by default"},
+	}
+}
+
+func (f field) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_").Replace(f.key))
+}
+
+func (f field) set(value string) error {
+	var err error
+	switch ptr := f.ptr.(type) {
+	case *string:
+		*ptr = value
+	case *bool:
+		*ptr, err = strconv.ParseBool(value)
+	case *int:
+		*ptr, err = strconv.Atoi(value)
+	case *float64:
+		*ptr, err = strconv.ParseFloat(value, 64)
+	case *Duration:
+		var d time.Duration
+		d, err = time.ParseDuration(value)
+		*ptr = Duration(d)
+	case *[]string:
+		*ptr = []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*ptr = append(*ptr, item)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %q: %w", f.key, value, err)
+	}
+	return nil
+}
+
+// Options are the flags that aren't settings
+type Options struct {
+	Path        string
+	PrintConfig bool
+}
+
+// Load applies the config file, then the env vars, then the flags over the defaults
+func Load(args []string, output io.Writer) (Config, Options, error) {
+	cfg := Default()
+	opts := Options{}
+	fs := flag.NewFlagSet("redditclone", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&opts.Path, "config", os.Getenv(EnvPrefix+"CONFIG"), "JSON config file")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective config and exit")
+	flagValues := map[string]*string{}
+	for _, f := range fields(&cfg) {
+		flagValues[f.key] = fs.String(f.key, "", f.usage+" (env "+f.envName()+")")
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, opts, err
+	}
+
+	if opts.Path != "" {
+		data, err := os.ReadFile(opts.Path)
+		if err != nil {
+			return cfg, opts, fmt.Errorf("config file: %w", err)
+		}
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&cfg)
+		if err != nil {
+			return cfg, opts, fmt.Errorf("config file %s: %w", opts.Path, err)
+		}
+	}
+	for _, f := range fields(&cfg) {
+		if value, ok := os.LookupEnv(f.envName()); ok {
+			if err = f.set(value); err != nil {
+				return cfg, opts, fmt.Errorf("env %s: %w", f.envName(), err)
+			}
+		}
+	}
+	set := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	for _, f := range fields(&cfg) {
+		if set[f.key] {
+			if err = f.set(*flagValues[f.key]); err != nil {
+				return cfg, opts, fmt.Errorf("flag -%w", err)
+			}
+		}
+	}
+	return cfg, opts, cfg.Validate()
+}
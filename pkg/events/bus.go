@@ -0,0 +1,157 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"sync"
+)
+
+var ErrBusClosed = errors.New("Event bus is closed")
+
+type Handler func(event Event) error
+
+// DropHandler is told about the events an asynchronous subscriber lost
+// because its queue was full
+type DropHandler func(subscriber string, event Event)
+
+type asyncSubscriber struct {
+	name    string
+	handler Handler
+	queue   chan Event
+}
+
+// Bus delivers domain events to the subscribers. Synchronous subscribers run
+// in the publisher goroutine in subscription order, asynchronous ones get
+// their own goroutine and queue. A slow asynchronous subscriber never blocks
+// the publisher, the events not fitting into its queue are dropped
+type Bus struct {
+	Logger *zap.SugaredLogger
+	OnDrop DropHandler
+
+	sync   []Handler
+	names  []string
+	async  []*asyncSubscriber
+	closed bool
+	done   chan struct{}
+	mu     sync.RWMutex
+	wg     sync.WaitGroup
+}
+
+func NewBus(logger *zap.SugaredLogger) *Bus {
+	return &Bus{
+		Logger: logger,
+		done:   make(chan struct{}),
+	}
+}
+
+// On adapts a handler of one event type to the bus, other events are skipped
+func On[E Event](handler func(event E) error) Handler {
+	return func(event Event) error {
+		e, ok := event.(E)
+		if !ok {
+			return nil
+		}
+		return handler(e)
+	}
+}
+
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sync = append(b.sync, handler)
+	b.names = append(b.names, name)
+}
+
+func (b *Bus) SubscribeAsync(name string, buffer int, handler Handler) {
+	sub := &asyncSubscriber{
+		name:    name,
+		handler: handler,
+		queue:   make(chan Event, buffer),
+	}
+	b.mu.Lock()
+	b.async = append(b.async, sub)
+	b.mu.Unlock()
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			select {
+			case event := <-sub.queue:
+				b.handle(sub.name, sub.handler, event)
+			case <-b.done:
+				// finish the events queued before close
+				for {
+					select {
+					case event := <-sub.queue:
+						b.handle(sub.name, sub.handler, event)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (b *Bus) handle(name string, handler Handler, event Event) {
+	defer func() {
+		if err := recover(); err != nil {
+			b.Logger.Errorf("Subscriber %v panicked on event %v: %v", name, event.EventName(), err)
+		}
+	}()
+	if err := handler(event); err != nil {
+		b.Logger.Errorf("Subscriber %v failed on event %v: %v", name, event.EventName(), err)
+	}
+}
+
+// Publish never fails: the event has already happened, errors of the
+// subscribers are only logged. The subscribers run outside the lock, so
+// they may subscribe or publish themselves
+func (b *Bus) Publish(event Event) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		b.Logger.Warnf("Event %v was published after the bus was closed", event.EventName())
+		return
+	}
+	handlers, names, async := b.sync, b.names, b.async
+	b.mu.RUnlock()
+	for i, handler := range handlers {
+		b.handle(names[i], handler, event)
+	}
+	for _, sub := range async {
+		select {
+		case sub.queue <- event:
+		default:
+			b.Logger.Warnf("Subscriber %v queue is full, event %v is dropped", sub.name, event.EventName())
+			if b.OnDrop != nil {
+				b.OnDrop(sub.name, event)
+			}
+		}
+	}
+}
+
+// Close stops accepting events and waits until the asynchronous subscribers
+// process their queues
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.done)
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// Ping fails after Close, so the server isn't ready while shutting down
+func (b *Bus) Ping(ctx context.Context) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrBusClosed
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPublishDropsWhenQueueIsFull(t *testing.T) {
+	bus := NewBus(zap.NewNop().Sugar())
+	var dropped atomic.Int32
+	bus.OnDrop = func(subscriber string, event Event) {
+		if subscriber != "slow" {
+			t.Errorf("dropped by %v, want slow", subscriber)
+		}
+		dropped.Add(1)
+	}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var once sync.Once
+	var handled atomic.Int32
+	bus.SubscribeAsync("slow", 1, func(event Event) error {
+		once.Do(func() { close(started) })
+		<-release
+		handled.Add(1)
+		return nil
+	})
+
+	// the first event is taken by the subscriber, the second fills the queue
+	bus.Publish(LoggedIn{})
+	<-started
+	finished := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			bus.Publish(LoggedIn{})
+		}
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked on a slow subscriber")
+	}
+	if got := dropped.Load(); got != 9 {
+		t.Errorf("dropped = %v, want 9", got)
+	}
+
+	close(release)
+	bus.Close()
+	if got := handled.Load(); got != 2 {
+		t.Errorf("handled = %v, want 2", got)
+	}
+}
+
+func TestPublishOutsideLock(t *testing.T) {
+	bus := NewBus(zap.NewNop().Sugar())
+	var got atomic.Int32
+	bus.Subscribe("nested", func(event Event) error {
+		// a subscriber may subscribe while the event is delivered
+		if _, ok := event.(UserRegistered); ok {
+			bus.Subscribe("late", On(func(e LoggedIn) error {
+				got.Add(1)
+				return nil
+			}))
+		}
+		return nil
+	})
+	done := make(chan struct{})
+	go func() {
+		bus.Publish(UserRegistered{})
+		bus.Publish(LoggedIn{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish deadlocked")
+	}
+	if got.Load() != 1 {
+		t.Errorf("late subscriber got %v events, want 1", got.Load())
+	}
+	bus.Close()
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/user"
+)
+
+const (
+	NamePostCreated    = "post_created"
+	NamePostDeleted    = "post_deleted"
+	NameCommentAdded   = "comment_added"
+	NameCommentDeleted = "comment_deleted"
+	NameVoted          = "voted"
+	NameUserRegistered = "user_registered"
+	NameLoggedIn       = "logged_in"
+	NameLoginFailed    = "login_failed"
+)
+
+type Event interface {
+	EventName() string
+}
+
+type PostCreated struct {
+	Post posts.Post
+}
+
+type PostDeleted struct {
+	Post posts.Post
+}
+
+type CommentAdded struct {
+	Post    posts.Post
+	Comment posts.Comment
+}
+
+type CommentDeleted struct {
+	Post      posts.Post //without the comment
+	CommentID string
+	Author    posts.Author //of the comment
+}
+
+type Voted struct {
+	Post   posts.Post
+	UserID string
+	Vote   int8 //0 for unvote
+}
+
+type UserRegistered struct {
+	User user.User
+}
+
+type LoggedIn struct {
+	User user.User
+}
+
+// LoginFailed is a wrong login or password
+type LoginFailed struct {
+	Login string
+}
+
+func (PostCreated) EventName() string    { return NamePostCreated }
+func (PostDeleted) EventName() string    { return NamePostDeleted }
+func (CommentAdded) EventName() string   { return NameCommentAdded }
+func (CommentDeleted) EventName() string { return NameCommentDeleted }
+func (Voted) EventName() string          { return NameVoted }
+func (UserRegistered) EventName() string { return NameUserRegistered }
+func (LoggedIn) EventName() string       { return NameLoggedIn }
+func (LoginFailed) EventName() string    { return NameLoginFailed }
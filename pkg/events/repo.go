@@ -0,0 +1,130 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+)
+
+var (
+	_ posts.PostRepo = &PostRepo{}
+	_ user.UserRepo  = &UserRepo{}
+)
+
+// PostRepo publishes an event for every successful mutation of the wrapped repository
+type PostRepo struct {
+	posts.PostRepo
+	Bus *Bus
+}
+
+func NewPostRepo(repo posts.PostRepo, bus *Bus) *PostRepo {
+	return &PostRepo{
+		PostRepo: repo,
+		Bus:      bus,
+	}
+}
+
+func (repo *PostRepo) Add(ctx context.Context, item *posts.Post) (uint64, error) {
+	lastID, err := repo.PostRepo.Add(ctx, item)
+	if err != nil {
+		return lastID, err
+	}
+	post := *item
+	post.Votes = posts.MapToSlice(post.VotesFromDB)
+	repo.Bus.Publish(PostCreated{Post: post})
+	return lastID, nil
+}
+
+func (repo *PostRepo) AddComment(ctx context.Context, postID, parentID, newCom string, sess session.Session) (posts.Post, error) {
+	post, err := repo.PostRepo.AddComment(ctx, postID, parentID, newCom, sess)
+	if err != nil {
+		return post, err
+	}
+	repo.Bus.Publish(CommentAdded{
+		Post:    post,
+		Comment: post.Comments[len(post.Comments)-1],
+	})
+	return post, nil
+}
+
+func (repo *PostRepo) DeleteComment(ctx context.Context, postID, commID string, sess session.Session) (posts.Post, error) {
+	before, err := repo.PostRepo.GetByID(ctx, postID)
+	if err != nil {
+		return before, err
+	}
+	post, err := repo.PostRepo.DeleteComment(ctx, postID, commID, sess)
+	if err != nil {
+		return post, err
+	}
+	var author posts.Author
+	for _, comm := range before.Comments {
+		if comm.ID == commID {
+			author = comm.Author
+		}
+	}
+	repo.Bus.Publish(CommentDeleted{
+		Post:      post,
+		CommentID: commID,
+		Author:    author,
+	})
+	return post, nil
+}
+
+func (repo *PostRepo) Vote(ctx context.Context, postID, userID string, newVote int8) (posts.Post, error) {
+	post, err := repo.PostRepo.Vote(ctx, postID, userID, newVote)
+	if err != nil {
+		return post, err
+	}
+	repo.Bus.Publish(Voted{
+		Post:   post,
+		UserID: userID,
+		Vote:   newVote,
+	})
+	return post, nil
+}
+
+func (repo *PostRepo) Delete(ctx context.Context, id string) error {
+	post, err := repo.PostRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = repo.PostRepo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	repo.Bus.Publish(PostDeleted{Post: post})
+	return nil
+}
+
+// UserRepo publishes registrations and failed logins, the successful ones
+// are published by the login handler once the second factor is passed
+type UserRepo struct {
+	user.UserRepo
+	Bus *Bus
+}
+
+func NewUserRepo(repo user.UserRepo, bus *Bus) *UserRepo {
+	return &UserRepo{
+		UserRepo: repo,
+		Bus:      bus,
+	}
+}
+
+func (repo *UserRepo) Authorize(ctx context.Context, login, pass string) (user.User, error) {
+	usr, err := repo.UserRepo.Authorize(ctx, login, pass)
+	if errors.Is(err, user.ErrNoUser) || errors.Is(err, user.ErrBadPass) {
+		repo.Bus.Publish(LoginFailed{Login: login})
+	}
+	return usr, err
+}
+
+func (repo *UserRepo) Register(ctx context.Context, login, pass string) (user.User, error) {
+	usr, err := repo.UserRepo.Register(ctx, login, pass)
+	if err != nil {
+		return usr, err
+	}
+	repo.Bus.Publish(UserRegistered{User: usr})
+	return usr, nil
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+)
+
+// record subscribes to the bus and returns the events of the given type it got
+func record[E Event](bus *Bus) func() []E {
+	var got []E
+	bus.Subscribe("recorder", On(func(e E) error {
+		got = append(got, e)
+		return nil
+	}))
+	return func() []E { return got }
+}
+
+func TestCommentDeletedAuthor(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus(zap.NewNop().Sugar())
+	deleted := record[CommentDeleted](bus)
+	repo := NewPostRepo(posts.NewPostMemoryRepository(), bus)
+	alice := session.Session{UserID: 1, Login: "alice"}
+	bob := session.Session{UserID: 2, Login: "bob"}
+	post := &posts.Post{Title: "hello", Category: "music", Type: "text", Text: "body"}
+	if _, err := repo.Add(ctx, post); err != nil {
+		t.Fatal(err)
+	}
+	commented, err := repo.AddComment(ctx, post.ID, "", "hi", bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comm := commented.Comments[0]
+
+	if _, err := repo.DeleteComment(ctx, post.ID, comm.ID, alice); err == nil {
+		t.Fatal("alice deleted the comment of bob")
+	}
+	if _, err := repo.DeleteComment(ctx, post.ID, comm.ID, bob); err != nil {
+		t.Fatal(err)
+	}
+	got := deleted()
+	if len(got) != 1 {
+		t.Fatalf("events = %+v, want one", got)
+	}
+	if got[0].CommentID != comm.ID || got[0].Author != comm.Author || len(got[0].Post.Comments) != 0 {
+		t.Errorf("event = %+v, want the comment %v of %+v", got[0], comm.ID, comm.Author)
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"io"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/hidden"
+	"myredditclone/pkg/notifications"
+	"myredditclone/pkg/oauth"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/reset"
+	"myredditclone/pkg/saved"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/tokens"
+	"myredditclone/pkg/twofactor"
+	"myredditclone/pkg/user"
+	"myredditclone/pkg/verify"
+	"myredditclone/pkg/webhooks"
+	"net/http"
+)
+
+type AccountHandler struct {
+	UserRepo          user.UserRepo
+	Sessions          *session.SessionsManager
+	Service           *posts.PostService
+	Verifier          *verify.Verifier
+	TokensRepo        tokens.TokenRepo
+	OAuth             *oauth.Server
+	ResetRepo         reset.ResetRepo
+	WebhooksRepo      webhooks.WebhookRepo
+	TwoFactor         *twofactor.Service
+	SavedRepo         saved.SavedRepo
+	HiddenRepo        hidden.HiddenRepo
+	BlockRepo         blocks.BlockRepo
+	NotificationsRepo notifications.NotificationRepo
+	Logger            *zap.SugaredLogger
+}
+
+// ownerSession returns the session of the user the URL is about or writes the error
+func ownerSession(w http.ResponseWriter, r *http.Request) (*session.Session, bool) {
+	vars := mux.Vars(r)
+	userLogin, ok := vars[ParamUserLogin]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
+		return nil, false
+	}
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return nil, false
+	}
+	if sess.Login != userLogin {
+		WriteError(w, ErrNotOwner)
+		return nil, false
+	}
+	return sess, true
+}
+
+// badPassword keeps a wrong confirmation password from looking like a lost session
+func badPassword(err error, param string) error {
+	if errors.Is(err, user.ErrBadPass) {
+		return apperrors.Validation(param, "", err.Error())
+	}
+	return err
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "cant read request body")
+		return false
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "cant unpack payload")
+		return false
+	}
+	return true
+}
+
+// logOut revokes everything that lets in without the password: the
+// sessions except keepID, the personal access tokens, the apps and the
+// pending password resets
+func (ah *AccountHandler) logOut(sess *session.Session, keepID string) error {
+	ah.Sessions.DestroyByUser(sess.UserID, keepID)
+	err := ah.TokensRepo.DeleteByUser(sess.Login)
+	if err != nil {
+		return err
+	}
+	err = ah.OAuth.RevokeUser(sess.Login)
+	if err != nil {
+		return err
+	}
+	return ah.ResetRepo.Revoke(sess.Login)
+}
+
+// forget drops what is kept for the login apart from the tombstone of the account
+func (ah *AccountHandler) forget(login string) error {
+	cleanups := []func(login string) error{
+		ah.OAuth.DeleteUser,
+		ah.WebhooksRepo.DeleteByOwner,
+		ah.TwoFactor.Forget,
+		ah.SavedRepo.DeleteByUser,
+		ah.HiddenRepo.DeleteByUser,
+		ah.BlockRepo.DeleteByUser,
+		ah.NotificationsRepo.DeleteByRecipient,
+	}
+	for _, cleanup := range cleanups {
+		err := cleanup(login)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangePassword logs the user out of all the other sessions, the personal
+// access tokens and the apps
+func (ah *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	pd := &struct {
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	}{}
+	if !readJSON(w, r, pd) {
+		return
+	}
+	if pd.NewPassword == "" {
+		WriteError(w, apperrors.Validation("newPassword", "", "password is required"))
+		return
+	}
+	err := ah.UserRepo.ChangePassword(r.Context(), sess.Login, pd.OldPassword, pd.NewPassword)
+	if err != nil {
+		WriteError(w, badPassword(err, "oldPassword"))
+		return
+	}
+	err = ah.logOut(sess, sess.ID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"message": "password changed"})
+	ah.Logger.Infof("Changed password for user with ID: %v", sess.UserID)
+}
+
+func (ah *AccountHandler) SetEmail(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	ed := &struct {
+		Email string `json:"email"`
+	}{}
+	if !readJSON(w, r, ed) {
+		return
+	}
+	if !govalidator.IsEmail(ed.Email) {
+		WriteError(w, apperrors.Validation("email", ed.Email, "email is not valid"))
+		return
+	}
+	err := ah.UserRepo.SetEmail(r.Context(), sess.Login, ed.Email)
+	if err != nil {
+		WriteError(w, apperrors.WithField(err, "email", ed.Email))
+		return
+	}
+	usr, err := ah.UserRepo.GetByLogin(r.Context(), sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if !usr.EmailVerified {
+		err = ah.Verifier.Send(usr)
+		if err != nil {
+			ah.Logger.Errorf("Failed to send verification email to user with ID: %v: %v", usr.ID, err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"email": usr.Email, "verified": usr.EmailVerified})
+	ah.Logger.Infof("Set email for user with ID: %v", sess.UserID)
+}
+
+func (ah *AccountHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	usr, err := ah.UserRepo.GetByLogin(r.Context(), sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	err = ah.Verifier.Send(usr)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"message": "verification email sent"})
+}
+
+func (ah *AccountHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	usr, err := ah.Verifier.Confirm(r.Context(), r.URL.Query().Get("token"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"username": usr.Login, "email": usr.Email, "verified": usr.EmailVerified})
+	ah.Logger.Infof("Verified email of user with ID: %v", usr.ID)
+}
+
+func (ah *AccountHandler) Preferences(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	usr, err := ah.UserRepo.GetByLogin(r.Context(), sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, usr.Preferences)
+}
+
+func (ah *AccountHandler) SetPreferences(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	prefs := user.DefaultPreferences()
+	if !readJSON(w, r, &prefs) {
+		return
+	}
+	if prefs.DefaultSort != user.SortTop && prefs.DefaultSort != user.SortNew {
+		WriteError(w, apperrors.Validation("defaultSort", prefs.DefaultSort, "unknown sort"))
+		return
+	}
+	if prefs.NotifyEmail != "" && !govalidator.IsEmail(prefs.NotifyEmail) {
+		WriteError(w, apperrors.Validation("notifyEmail", prefs.NotifyEmail, "email is not valid"))
+		return
+	}
+	err := ah.UserRepo.SetPreferences(r.Context(), sess.Login, prefs)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, prefs)
+	ah.Logger.Infof("Set preferences for user with ID: %v", sess.UserID)
+}
+
+// Delete removes the account with everything kept for it, leaving its
+// posts and comments as [deleted]
+func (ah *AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	pd := &struct {
+		Password string `json:"password"`
+	}{}
+	if !readJSON(w, r, pd) {
+		return
+	}
+	_, err := ah.UserRepo.Delete(r.Context(), sess.Login, pd.Password)
+	if err != nil {
+		WriteError(w, badPassword(err, "password"))
+		return
+	}
+	err = ah.logOut(sess, "")
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	err = ah.forget(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	err = ah.Service.AnonymizeAuthor(r.Context(), sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"message": "success"})
+	ah.Logger.Infof("Deleted account of user with ID: %v", sess.UserID)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"myredditclone/pkg/notifications"
+	"myredditclone/pkg/oauth"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+	"myredditclone/pkg/webhooks"
+)
+
+const pkceVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+// appToken authorizes an app of another user to act for the login and
+// returns the access token
+func (api *testAPI) appToken(t *testing.T, login string) string {
+	t.Helper()
+	const redirect = "https://app.example.com/callback"
+	app, _, err := api.OAuth.RegisterApp("carol", "app", []string{redirect}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(pkceVerifier))
+	to, err := api.OAuth.Approve(login, oauth.AuthRequest{
+		ResponseType:        "code",
+		ClientID:            app.ClientID,
+		RedirectURI:         redirect,
+		Scope:               session.ScopeRead,
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: "S256",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := api.OAuth.ExchangeCode(app, u.Query().Get("code"), redirect, pkceVerifier)
+	if err != nil {
+		t.Fatalf("exchange the code from %v: %v", to, err)
+	}
+	return resp.AccessToken
+}
+
+func TestChangePassword(t *testing.T) {
+	api := newTestAPI(t)
+	current := api.login(t, "alice")
+	usr, err := api.Users.GetByLogin(context.Background(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := api.session(t, usr)
+	_, pat, err := api.Tokens.Create("alice", "ci", []string{session.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := api.appToken(t, "alice")
+	for _, token := range []string{other, pat, app} {
+		api.ok(t, http.MethodGet, "/api/notifications", token, "", nil)
+	}
+
+	rec := api.do(http.MethodPut, testUserPath+"/password", current, `{"oldPassword":"wrong","newPassword":"secret"}`)
+	resp := assertError(t, rec, http.StatusUnprocessableEntity, "")
+	if len(resp.Errors) != 1 || resp.Errors[0].Param != "oldPassword" {
+		t.Errorf("errors = %+v, want oldPassword", resp.Errors)
+	}
+	rec = api.do(http.MethodPut, testUserPath+"/password", current, `{"oldPassword":"password","newPassword":""}`)
+	assertError(t, rec, http.StatusUnprocessableEntity, "")
+	api.ok(t, http.MethodGet, "/api/notifications", other, "", nil)
+
+	api.ok(t, http.MethodPut, testUserPath+"/password", current, `{"oldPassword":"password","newPassword":"secret"}`, nil)
+	if _, err := api.Users.Authorize(context.Background(), "alice", "secret"); err != nil {
+		t.Errorf("login with the new password: %v", err)
+	}
+	api.ok(t, http.MethodGet, "/api/notifications", current, "", nil)
+	for name, token := range map[string]string{"other session": other, "personal token": pat, "app token": app} {
+		if rec := api.do(http.MethodGet, "/api/notifications", token, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%v after the password change: status %v, want 401", name, rec.Code)
+		}
+	}
+
+	bob := api.login(t, "bob")
+	rec = api.do(http.MethodPut, testUserPath+"/password", bob, `{"oldPassword":"password","newPassword":"x"}`)
+	assertError(t, rec, http.StatusForbidden, "")
+}
+
+func TestPreferences(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.login(t, "alice")
+
+	var prefs user.Preferences
+	api.ok(t, http.MethodGet, testUserPath+"/preferences", alice, "", &prefs)
+	if prefs != user.DefaultPreferences() {
+		t.Errorf("preferences = %+v, want the defaults", prefs)
+	}
+
+	bad := map[string]string{
+		"defaultSort": `{"defaultSort":"random"}`,
+		"notifyEmail": `{"notifyEmail":"not an email"}`,
+	}
+	for param, body := range bad {
+		rec := api.do(http.MethodPut, testUserPath+"/preferences", alice, body)
+		resp := assertError(t, rec, http.StatusUnprocessableEntity, "")
+		if len(resp.Errors) != 1 || resp.Errors[0].Param != param {
+			t.Errorf("%v: errors = %+v", body, resp.Errors)
+		}
+	}
+
+	want := user.Preferences{DefaultSort: user.SortNew, ShowNSFW: true, NotifyEmail: "alice@example.com"}
+	api.ok(t, http.MethodPut, testUserPath+"/preferences", alice,
+		`{"defaultSort":"new","showNsfw":true,"notifyEmail":"alice@example.com"}`, nil)
+	api.ok(t, http.MethodGet, testUserPath+"/preferences", alice, "", &prefs)
+	if prefs != want {
+		t.Errorf("preferences = %+v, want %+v", prefs, want)
+	}
+	// the fields left out get the defaults
+	api.ok(t, http.MethodPut, testUserPath+"/preferences", alice, `{"showNsfw":true}`, &prefs)
+	if want := (user.Preferences{DefaultSort: user.SortTop, ShowNSFW: true}); prefs != want {
+		t.Errorf("preferences = %+v, want %+v", prefs, want)
+	}
+
+	bob := api.login(t, "bob")
+	assertError(t, api.do(http.MethodGet, testUserPath+"/preferences", bob, ""), http.StatusForbidden, "")
+	assertError(t, api.do(http.MethodPut, testUserPath+"/preferences", bob, `{}`), http.StatusForbidden, "")
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	api := newTestAPI(t)
+	alice := api.login(t, "alice")
+	bob := api.login(t, "bob")
+	post := api.post(t, alice)
+	comment := api.comment(t, alice, post.ID, "first")
+	bobPost := api.post(t, bob)
+
+	_, pat, err := api.Tokens.Create("alice", "ci", []string{session.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := api.appToken(t, "alice")
+	ownApp, _, err := api.OAuth.RegisterApp("alice", "own", []string{"https://alice.example.com"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Webhooks.Add(&webhooks.Webhook{Owner: "alice", URL: "https://example.com/hook"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := api.TwoFactor.Enroll("alice"); err != nil {
+		t.Fatal(err)
+	}
+	api.ok(t, http.MethodPost, "/api/post/"+bobPost.ID+"/save", alice, "", nil)
+	api.ok(t, http.MethodPost, "/api/post/"+bobPost.ID+"/hide", alice, "", nil)
+	api.ok(t, http.MethodPost, "/api/user/bob/block", alice, "", nil)
+	api.ok(t, http.MethodPost, testUserPath+"/block", bob, "", nil)
+	if err := api.Notifications.Add(&notifications.Notification{Type: notifications.TypeMention, Recipient: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	resetToken, err := api.Resets.Create("alice", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := api.do(http.MethodDelete, testUserPath, alice, `{"password":"wrong"}`)
+	assertError(t, rec, http.StatusUnprocessableEntity, "")
+	assertError(t, api.do(http.MethodDelete, testUserPath, bob, `{"password":"password"}`), http.StatusForbidden, "")
+	api.ok(t, http.MethodDelete, testUserPath, alice, `{"password":"password"}`, nil)
+
+	for name, token := range map[string]string{"session": alice, "personal token": pat, "app token": app} {
+		if rec := api.do(http.MethodGet, "/api/notifications", token, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%v of the deleted account: status %v, want 401", name, rec.Code)
+		}
+	}
+	got, err := api.Posts.Get(ctx, post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Author.Username != posts.DeletedAuthor.Username || got.Comments[0].ID != comment.ID || got.Comments[0].Author.Username != posts.DeletedAuthor.Username {
+		t.Errorf("post = %+v, want the post and the comment anonymized", got)
+	}
+	if _, err := api.OAuth.Apps.Get(ownApp.ClientID); !errors.Is(err, oauth.ErrNoApp) {
+		t.Errorf("app of the deleted account: error = %v, want ErrNoApp", err)
+	}
+	if hooks, _ := api.Webhooks.GetByOwner("alice"); len(hooks) != 0 {
+		t.Errorf("webhooks = %+v, want none", hooks)
+	}
+	if api.TwoFactor.Enabled("alice") {
+		t.Error("two-factor state is kept")
+	}
+	if state, err := api.TwoFactor.Repo.Get("alice"); err == nil {
+		t.Errorf("two-factor secret %+v is kept", state)
+	}
+	if _, err := api.Resets.Consume(resetToken); err == nil {
+		t.Error("the reset token of the deleted account is accepted")
+	}
+
+	if items, _ := api.Saved.GetByUser("alice"); len(items) != 0 {
+		t.Errorf("saved items = %+v, want none", items)
+	}
+	if items, _ := api.Hidden.GetByUser("alice"); len(items) != 0 {
+		t.Errorf("hidden posts = %+v, want none", items)
+	}
+	if items, _ := api.Blocks.GetByUser("alice"); len(items) != 0 {
+		t.Errorf("blocks = %+v, want none", items)
+	}
+	if blocked, _ := api.Blocks.IsBlocked("bob", "alice"); blocked {
+		t.Error("the block of the deleted account by bob is kept")
+	}
+	if items, _ := api.Notifications.GetByRecipient("alice"); len(items) != 0 {
+		t.Errorf("notifications = %+v, want none", items)
+	}
+	if items, _ := api.Tokens.Repo.GetByUser("alice"); len(items) != 0 {
+		t.Errorf("personal tokens = %+v, want none", items)
+	}
+	// the login stays taken
+	if _, err := api.Users.Register(ctx, "alice", "password"); !errors.Is(err, user.ErrExistUser) {
+		t.Errorf("register the deleted login: error = %v, want ErrExistUser", err)
+	}
+}
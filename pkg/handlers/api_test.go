@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/hidden"
+	"myredditclone/pkg/notifications"
+	"myredditclone/pkg/oauth"
+	"myredditclone/pkg/oidc"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/profile"
+	"myredditclone/pkg/reset"
+	"myredditclone/pkg/saved"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/stream"
+	"myredditclone/pkg/tokens"
+	"myredditclone/pkg/twofactor"
+	"myredditclone/pkg/user"
+	"myredditclone/pkg/verify"
+	"myredditclone/pkg/webhooks"
+)
+
+func TestMain(m *testing.M) {
+	session.Key = []byte("test key")
+	os.Exit(m.Run())
+}
+
+// testAPI serves the handlers with the memory repositories and the common
+// middlewares, the way main does
+type testAPI struct {
+	http.Handler
+	Users         *user.UserRepository
+	Sessions      *session.SessionsManager
+	Posts         *posts.PostService
+	Tokens        *tokens.Authenticator
+	OAuth         *oauth.Server
+	Resets        *reset.ResetMemoryRepository
+	Webhooks      *webhooks.WebhookMemoryRepository
+	TwoFactor     *twofactor.Service
+	Saved         *saved.SavedMemoryRepository
+	Hidden        *hidden.HiddenMemoryRepository
+	Blocks        *blocks.BlockMemoryRepository
+	Notifications *notifications.NotificationMemoryRepository
+	Karma         *profile.KarmaMemoryRepository
+	Broker        *stream.Broker
+	Mail          *mailbox
+}
+
+// newTestAPI serves all the handlers, the options change them before
+// the routes are made, e.g. to configure SSO
+func newTestAPI(t *testing.T, options ...func(*Handlers)) *testAPI {
+	t.Helper()
+	api := &testAPI{
+		Users:         user.NewUserRepository(),
+		Sessions:      session.NewSessionManager(),
+		Resets:        reset.NewResetMemoryRepository(),
+		Webhooks:      webhooks.NewWebhookMemoryRepository(),
+		TwoFactor:     twofactor.NewService(twofactor.NewTwoFactorMemoryRepository(), "test"),
+		Saved:         saved.NewSavedMemoryRepository(),
+		Hidden:        hidden.NewHiddenMemoryRepository(),
+		Blocks:        blocks.NewBlockMemoryRepository(),
+		Notifications: notifications.NewNotificationMemoryRepository(),
+		Karma:         profile.NewKarmaMemoryRepository(),
+		Broker:        stream.NewBroker(),
+		Mail:          &mailbox{},
+	}
+	t.Cleanup(api.Broker.Close)
+	api.Posts = posts.NewPostService(posts.NewPostMemoryRepository(), api.Blocks)
+	api.Tokens = tokens.NewAuthenticator(tokens.NewTokenMemoryRepository(), api.Users)
+	api.OAuth = oauth.NewServer(oauth.NewAppMemoryRepository(), oauth.NewGrantMemoryRepository(), api.Users)
+	api.Sessions.Users = api.Users
+	api.Sessions.Tokens = append(api.Sessions.Tokens, api.Tokens, api.OAuth)
+	logger := zap.NewNop().Sugar()
+	verifier := verify.NewVerifier(verify.NewVerifyMemoryRepository(), api.Mail, api.Users, "http://localhost/")
+	h := Handlers{
+		User: UserHandler{
+			Logger:    logger,
+			Sessions:  api.Sessions,
+			UserRepo:  api.Users,
+			Verifier:  verifier,
+			TwoFactor: api.TwoFactor,
+			Linker:    oidc.NewLinker(api.Users),
+		},
+		Post: PostHandler{
+			Service:    api.Posts,
+			SavedRepo:  api.Saved,
+			HiddenRepo: api.Hidden,
+			BlockRepo:  api.Blocks,
+			UserRepo:   api.Users,
+			Logger:     logger,
+		},
+		Notification: NotificationHandler{NotificationsRepo: api.Notifications, Logger: logger},
+		Stream:       StreamHandler{Broker: api.Broker, PostsRepo: api.Posts.Repo, Sessions: api.Sessions, Heartbeat: time.Minute, Logger: logger},
+		Live: LiveHandler{
+			Broker:    api.Broker,
+			PostsRepo: api.Posts.Repo,
+			BlockRepo: api.Blocks,
+			Sessions:  api.Sessions,
+			Logger:    logger,
+		},
+		Webhook: WebhookHandler{WebhooksRepo: api.Webhooks, Logger: logger},
+		Saved:   SavedHandler{Service: api.Posts, SavedRepo: api.Saved, BlockRepo: api.Blocks, Logger: logger},
+		Hidden:  HiddenHandler{Service: api.Posts, HiddenRepo: api.Hidden, BlockRepo: api.Blocks, Logger: logger},
+		Block:   BlockHandler{BlockRepo: api.Blocks, UserRepo: api.Users, Logger: logger},
+		Profile: ProfileHandler{UserRepo: api.Users, KarmaRepo: api.Karma, Service: api.Posts, Logger: logger},
+		Account: AccountHandler{
+			UserRepo:          api.Users,
+			Sessions:          api.Sessions,
+			Service:           api.Posts,
+			Verifier:          verifier,
+			TokensRepo:        api.Tokens.Repo,
+			OAuth:             api.OAuth,
+			ResetRepo:         api.Resets,
+			WebhooksRepo:      api.Webhooks,
+			TwoFactor:         api.TwoFactor,
+			SavedRepo:         api.Saved,
+			HiddenRepo:        api.Hidden,
+			BlockRepo:         api.Blocks,
+			NotificationsRepo: api.Notifications,
+			Logger:            logger,
+		},
+		Reset: ResetHandler{
+			UserRepo:  api.Users,
+			ResetRepo: api.Resets,
+			Mailer:    api.Mail,
+			Sessions:  api.Sessions,
+			Logger:    logger,
+			BaseURL:   "http://localhost",
+		},
+		TwoFactor: TwoFactorHandler{Service: api.TwoFactor, Logger: logger},
+		Token:     TokenHandler{Authenticator: api.Tokens, TokensRepo: api.Tokens.Repo, Logger: logger},
+		OAuth:     OAuthHandler{Server: api.OAuth, Logger: logger},
+	}
+	for _, option := range options {
+		option(&h)
+	}
+	api.Handler = PostProcess(GenerateRoutes(h, t.TempDir()), api.Sessions, logger)
+	return api
+}
+
+// login registers the user and returns the token of a new session
+func (api *testAPI) login(t *testing.T, login string) string {
+	t.Helper()
+	usr, err := api.Users.Register(context.Background(), login, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api.session(t, usr)
+}
+
+// session returns the token of a new session of the registered user
+func (api *testAPI) session(t *testing.T, usr user.User) string {
+	t.Helper()
+	sess, err := api.Sessions.Create(nil, usr.ID, usr.Login, usr.Roles...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := session.CreateNewToken(usr, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func (api *testAPI) do(method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+// ok does the request expecting 200 and decodes the response into v, if not nil
+func (api *testAPI) ok(t *testing.T, method, path, token, body string, v interface{}) {
+	t.Helper()
+	rec := api.do(method, path, token, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%v %v: status %v: %s", method, path, rec.Code, rec.Body)
+	}
+	if v == nil {
+		return
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("%v %v: body %q: %v", method, path, rec.Body, err)
+	}
+}
+
+func (api *testAPI) post(t *testing.T, token string) posts.Post {
+	t.Helper()
+	var post posts.Post
+	api.ok(t, http.MethodPost, "/api/posts", token,
+		`{"category":"music","type":"text","title":"hello","text":"body"}`, &post)
+	return post
+}
+
+func (api *testAPI) comment(t *testing.T, token, postID, body string) posts.Comment {
+	t.Helper()
+	var post posts.Post
+	api.ok(t, http.MethodPost, "/api/post/"+postID, token, `{"comment":"`+body+`"}`, &post)
+	return post.Comments[len(post.Comments)-1]
+}
+
+// assertError checks the status and the envelope of an error response
+func assertError(t *testing.T, rec *httptest.ResponseRecorder, status int, message string) ErrorResponse {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %v, want %v: %s", rec.Code, status, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	if resp.Status != status || resp.Error != http.StatusText(status) {
+		t.Errorf("body = %+v, want status %v", resp, status)
+	}
+	if message != "" && resp.Message != message {
+		t.Errorf("message = %q, want %q", resp.Message, message)
+	}
+	return resp
+}
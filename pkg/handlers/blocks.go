@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+	"net/http"
+)
+
+type BlockHandler struct {
+	BlockRepo blocks.BlockRepo
+	UserRepo  user.UserRepo
+	Logger    *zap.SugaredLogger
+}
+
+func (bh *BlockHandler) toggle(w http.ResponseWriter, r *http.Request, block bool) {
+	vars := mux.Vars(r)
+	userLogin, ok := vars[ParamUserLogin]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
+		return
+	}
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	_, err = bh.UserRepo.GetByLogin(r.Context(), userLogin)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if block {
+		err = bh.BlockRepo.Block(sess.Login, userLogin)
+	} else {
+		err = bh.BlockRepo.Unblock(sess.Login, userLogin)
+	}
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"username": userLogin, "blocked": block})
+	bh.Logger.Infof("Set blocked %v for user with Login: %v by user with ID: %v", block, userLogin, sess.UserID)
+}
+
+func (bh *BlockHandler) Block(w http.ResponseWriter, r *http.Request) {
+	bh.toggle(w, r, true)
+}
+
+func (bh *BlockHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	bh.toggle(w, r, false)
+}
+
+func (bh *BlockHandler) List(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userLogin, ok := vars[ParamUserLogin]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
+		return
+	}
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if sess.Login != userLogin {
+		WriteError(w, ErrNotOwner)
+		return
+	}
+	items, err := bh.BlockRepo.GetByUser(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, items)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/user"
+)
+
+func hasComment(comments []posts.Comment, commentID string) bool {
+	for _, comm := range comments {
+		if comm.ID == commentID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBlockUser(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.login(t, "alice")
+	bob := api.login(t, "bob")
+	own := api.post(t, alice)
+	comm := api.comment(t, bob, own.ID, "from bob")
+	other := api.post(t, bob)
+
+	api.ok(t, http.MethodPost, "/api/user/bob/block", alice, "", nil)
+	var items []blocks.Item
+	api.ok(t, http.MethodGet, "/api/user/alice/blocked", alice, "", &items)
+	if len(items) != 1 || items[0].Login != "bob" {
+		t.Errorf("blocked = %+v, want bob", items)
+	}
+
+	// the posts and the comments of bob are gone for alice only
+	if api.listed(t, "/api/posts/", alice, other.ID) {
+		t.Error("post of the blocked user is in the listing")
+	}
+	if !api.listed(t, "/api/posts/", "", other.ID) {
+		t.Error("post of bob is missing for the guest")
+	}
+	var post PostResponse
+	api.ok(t, http.MethodGet, "/api/post/"+own.ID, alice, "", &post)
+	if hasComment(post.Comments, comm.ID) {
+		t.Error("comment of the blocked user is in the post")
+	}
+	api.ok(t, http.MethodGet, "/api/post/"+own.ID, "", "", &post)
+	if !hasComment(post.Comments, comm.ID) {
+		t.Error("comment of bob is missing for the guest")
+	}
+
+	// bob can't reply to alice any more
+	rec := api.do(http.MethodPost, "/api/post/"+own.ID, bob, `{"comment":"still here"}`)
+	assertError(t, rec, http.StatusForbidden, posts.ErrBlocked.Error())
+
+	api.ok(t, http.MethodPost, "/api/user/bob/unblock", alice, "", nil)
+	if !api.listed(t, "/api/posts/", alice, other.ID) {
+		t.Error("post of the unblocked user is missing in the listing")
+	}
+	rec = api.do(http.MethodPost, "/api/user/bob/unblock", alice, "")
+	assertError(t, rec, http.StatusNotFound, blocks.ErrNotBlocked.Error())
+	rec = api.do(http.MethodPost, "/api/user/alice/block", alice, "")
+	assertError(t, rec, http.StatusUnprocessableEntity, "")
+	rec = api.do(http.MethodPost, "/api/user/ghost/block", alice, "")
+	assertError(t, rec, http.StatusNotFound, user.ErrNoUser.Error())
+}
+
+// The comments saved or hidden with their posts before the block go as well
+func TestBlockUserInSavedAndHidden(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.login(t, "alice")
+	bob := api.login(t, "bob")
+	carol := api.login(t, "carol")
+	post := api.post(t, carol)
+	comm := api.comment(t, bob, post.ID, "from bob")
+	kept := api.comment(t, carol, post.ID, "from carol")
+	for _, path := range []string{
+		"/api/post/" + post.ID + "/save",
+		"/api/post/" + post.ID + "/" + comm.ID + "/save",
+		"/api/post/" + post.ID + "/" + kept.ID + "/save",
+		"/api/post/" + post.ID + "/hide",
+	} {
+		api.ok(t, http.MethodPost, path, alice, "", nil)
+	}
+	api.ok(t, http.MethodPost, "/api/user/bob/block", alice, "", nil)
+
+	var resp SavedResponse
+	api.ok(t, http.MethodGet, "/api/user/alice/saved", alice, "", &resp)
+	if len(resp.Posts) != 1 || hasComment(resp.Posts[0].Comments, comm.ID) || !hasComment(resp.Posts[0].Comments, kept.ID) {
+		t.Errorf("saved posts = %+v, want the post without the comment of bob", resp.Posts)
+	}
+	if len(resp.Comments) != 1 || resp.Comments[0].Comment.ID != kept.ID {
+		t.Errorf("saved comments = %+v, want the comment of carol only", resp.Comments)
+	}
+	var elems []posts.Post
+	api.ok(t, http.MethodGet, "/api/user/alice/hidden", alice, "", &elems)
+	if len(elems) != 1 || hasComment(elems[0].Comments, comm.ID) || !hasComment(elems[0].Comments, kept.ID) {
+		t.Errorf("hidden posts = %+v, want the post without the comment of bob", elems)
+	}
+}
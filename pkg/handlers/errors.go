@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"myredditclone/pkg/apperrors"
+	"net/http"
+)
+
+type FieldError struct {
+	Location string `json:"location"`
+	Param    string `json:"param"`
+	Value    string `json:"value"`
+	Msg      string `json:"msg"`
+}
+
+// ErrorResponse is the envelope of every error returned by the API
+type ErrorResponse struct {
+	Status  int          `json:"status"`
+	Error   string       `json:"error"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+func StatusFromError(err error) int {
+	switch apperrors.Kind(err) {
+	case apperrors.ErrNotFound:
+		return http.StatusNotFound
+	case apperrors.ErrForbidden:
+		return http.StatusForbidden
+	case apperrors.ErrUnauthenticated:
+		return http.StatusUnauthorized
+	case apperrors.ErrConflict:
+		return http.StatusConflict
+	case apperrors.ErrValidation:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteError maps the domain error to the HTTP status and writes the envelope,
+// unexpected errors are hidden behind the generic message
+func WriteError(w http.ResponseWriter, err error) {
+	status := StatusFromError(err)
+	resp := ErrorResponse{
+		Status:  status,
+		Error:   http.StatusText(status),
+		Message: err.Error(),
+	}
+	if status == http.StatusInternalServerError {
+		resp.Message = "Internal server error"
+	}
+	appErr := &apperrors.Error{}
+	if errors.As(err, &appErr) && appErr.Param != "" {
+		resp.Errors = []FieldError{{
+			Location: "body",
+			Param:    appErr.Param,
+			Value:    appErr.Value,
+			Msg:      appErr.Msg,
+		}}
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	MarshalAndWrite(w, resp)
+}
+
+func jsonError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	MarshalAndWrite(w, ErrorResponse{
+		Status:  status,
+		Error:   http.StatusText(status),
+		Message: msg,
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+		fields  []FieldError
+	}{
+		{
+			name:    "not found",
+			err:     posts.ErrRecordNotFound,
+			status:  http.StatusNotFound,
+			message: posts.ErrRecordNotFound.Error(),
+		},
+		{
+			name:    "forbidden",
+			err:     posts.ErrNotAuthor,
+			status:  http.StatusForbidden,
+			message: posts.ErrNotAuthor.Error(),
+		},
+		{
+			name:    "unauthenticated",
+			err:     session.ErrNoAuth,
+			status:  http.StatusUnauthorized,
+			message: session.ErrNoAuth.Error(),
+		},
+		{
+			name:    "conflict",
+			err:     apperrors.New(apperrors.ErrConflict, "already exists"),
+			status:  http.StatusConflict,
+			message: "already exists",
+		},
+		{
+			name:    "validation",
+			err:     apperrors.Validation("title", "", "title is required"),
+			status:  http.StatusUnprocessableEntity,
+			message: "title is required",
+			fields:  []FieldError{{Location: "body", Param: "title", Value: "", Msg: "title is required"}},
+		},
+		{
+			name:    "wrapped",
+			err:     fmt.Errorf("get post: %w", posts.ErrRecordNotFound),
+			status:  http.StatusNotFound,
+			message: "get post: " + posts.ErrRecordNotFound.Error(),
+		},
+		{
+			name:    "unexpected",
+			err:     errors.New("connection refused"),
+			status:  http.StatusInternalServerError,
+			message: "Internal server error",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteError(rec, c.err)
+
+			if rec.Code != c.status {
+				t.Errorf("status = %v, want %v", rec.Code, c.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			challenge := rec.Header().Get("WWW-Authenticate")
+			if (c.status == http.StatusUnauthorized) != (challenge != "") {
+				t.Errorf("WWW-Authenticate = %q for status %v", challenge, c.status)
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("body %q: %v", rec.Body, err)
+			}
+			if resp.Status != c.status || resp.Error != http.StatusText(c.status) || resp.Message != c.message {
+				t.Errorf("body = %+v, want status %v, message %q", resp, c.status, c.message)
+			}
+			if len(resp.Errors) != len(c.fields) {
+				t.Fatalf("errors = %+v, want %+v", resp.Errors, c.fields)
+			}
+			for i := range c.fields {
+				if resp.Errors[i] != c.fields[i] {
+					t.Errorf("errors[%v] = %+v, want %+v", i, resp.Errors[i], c.fields[i])
+				}
+			}
+		})
+	}
+}
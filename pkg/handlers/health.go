@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"go.uber.org/zap"
+	"myredditclone/pkg/health"
+	"myredditclone/pkg/version"
+	"net/http"
+)
+
+type HealthHandler struct {
+	Checks []health.Check
+	Logger *zap.SugaredLogger
+}
+
+type ReadyResponse struct {
+	Status string          `json:"status"`
+	Checks []health.Result `json:"checks"`
+}
+
+// Healthz answers while the process is able to serve anything at all
+func (hh *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]string{"status": health.StatusOK})
+}
+
+// Readyz answers 503 when any backend or worker fails, so the load balancer
+// stops sending requests, including while the server shuts down
+func (hh *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	results, ok := health.Run(r.Context(), hh.Checks)
+	resp := ReadyResponse{Status: health.StatusOK, Checks: results}
+	status := http.StatusOK
+	if !ok {
+		resp.Status = health.StatusFail
+		status = http.StatusServiceUnavailable
+		hh.Logger.Warnw("not ready", "checks", results)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	MarshalAndWrite(w, resp)
+}
+
+func (hh *HealthHandler) Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, version.Get())
+}
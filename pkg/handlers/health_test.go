@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"myredditclone/pkg/health"
+	"myredditclone/pkg/stream"
+	"myredditclone/pkg/version"
+)
+
+func newProbes(checks ...health.Check) http.Handler {
+	h := HealthHandler{Checks: checks, Logger: zap.NewNop().Sugar()}
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	return WithProbes(h, http.NotFoundHandler(), app)
+}
+
+func probe(t *testing.T, h http.Handler, method, path string, v interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%v %v: %v in %q", method, path, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+func TestHealthz(t *testing.T) {
+	broker := stream.NewBroker()
+	broker.Close()
+	// liveness doesn't depend on the backends
+	h := newProbes(health.Check{Name: "streamBroker", Target: broker})
+	var resp map[string]string
+	if code := probe(t, h, http.MethodGet, "/healthz", &resp); code != http.StatusOK || resp["status"] != health.StatusOK {
+		t.Errorf("healthz = %v %+v, want 200 ok", code, resp)
+	}
+	if code := probe(t, h, http.MethodPost, "/healthz", nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST healthz = %v, want 405", code)
+	}
+	if code := probe(t, h, http.MethodGet, "/", nil); code != http.StatusTeapot {
+		t.Errorf("GET / = %v, want the app", code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	broker := stream.NewBroker()
+	h := newProbes(
+		health.Check{Name: "posts", Target: struct{}{}},
+		health.Check{Name: "streamBroker", Target: broker},
+	)
+	var resp ReadyResponse
+	if code := probe(t, h, http.MethodGet, "/readyz", &resp); code != http.StatusOK || resp.Status != health.StatusOK {
+		t.Errorf("readyz = %v %+v, want 200 ok", code, resp)
+	}
+
+	// the closed broker means the server is going down
+	broker.Close()
+	resp = ReadyResponse{}
+	if code := probe(t, h, http.MethodGet, "/readyz", &resp); code != http.StatusServiceUnavailable || resp.Status != health.StatusFail {
+		t.Errorf("readyz = %v %+v, want 503 fail", code, resp)
+	}
+	want := []health.Result{
+		{Name: "posts", Status: health.StatusOK},
+		{Name: "streamBroker", Status: health.StatusFail, Error: stream.ErrBrokerClosed.Error()},
+	}
+	if len(resp.Checks) != len(want) {
+		t.Fatalf("checks = %+v, want %+v", resp.Checks, want)
+	}
+	for i := range want {
+		if resp.Checks[i] != want[i] {
+			t.Errorf("check %v = %+v, want %+v", i, resp.Checks[i], want[i])
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	var info version.Info
+	if code := probe(t, newProbes(), http.MethodGet, "/version", &info); code != http.StatusOK {
+		t.Fatalf("version = %v, want 200", code)
+	}
+	if info != version.Get() {
+		t.Errorf("version = %+v, want %+v", info, version.Get())
+	}
+}
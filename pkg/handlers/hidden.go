@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/hidden"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"net/http"
+)
+
+type HiddenHandler struct {
+	Service    *posts.PostService
+	HiddenRepo hidden.HiddenRepo
+	BlockRepo  blocks.BlockRepo
+	Logger     *zap.SugaredLogger
+}
+
+func (hh *HiddenHandler) toggle(w http.ResponseWriter, r *http.Request, hide bool) {
+	vars := mux.Vars(r)
+	postID, ok := vars[ParamPostID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
+		return
+	}
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	_, err = hh.Service.Get(r.Context(), postID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if hide {
+		err = hh.HiddenRepo.Hide(sess.Login, postID)
+	} else {
+		err = hh.HiddenRepo.Unhide(sess.Login, postID)
+	}
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"postId": postID, "hidden": hide})
+	hh.Logger.Infof("Set hidden %v for post with ID: %v for user with ID: %v", hide, postID, sess.UserID)
+}
+
+func (hh *HiddenHandler) Hide(w http.ResponseWriter, r *http.Request) {
+	hh.toggle(w, r, true)
+}
+
+func (hh *HiddenHandler) Unhide(w http.ResponseWriter, r *http.Request) {
+	hh.toggle(w, r, false)
+}
+
+func (hh *HiddenHandler) List(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userLogin, ok := vars[ParamUserLogin]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
+		return
+	}
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if sess.Login != userLogin {
+		WriteError(w, ErrNotOwner)
+		return
+	}
+	items, err := hh.HiddenRepo.GetByUser(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	elems := make([]posts.Post, 0, len(items))
+	for _, item := range items {
+		post, err := hh.Service.Get(r.Context(), item.PostID)
+		if apperrors.Kind(err) == apperrors.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		post.Comments, err = withoutBlocked(hh.BlockRepo, sess.Login, post.Comments)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		elems = append(elems, post)
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, elems)
+	hh.Logger.Infof("Viewed hidden posts of user with ID: %v", sess.UserID)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"myredditclone/pkg/hidden"
+	"myredditclone/pkg/posts"
+)
+
+// listed tells whether the post is in the listing the user with the token sees
+func (api *testAPI) listed(t *testing.T, path, token, postID string) bool {
+	t.Helper()
+	var elems []PostResponse
+	api.ok(t, http.MethodGet, path, token, "", &elems)
+	for _, elem := range elems {
+		if elem.ID == postID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHidePost(t *testing.T) {
+	api := newTestAPI(t)
+	bob := api.login(t, "bob")
+	post := api.post(t, bob)
+	alice := api.login(t, "alice")
+	path := "/api/post/" + post.ID
+
+	api.ok(t, http.MethodPost, path+"/hide", alice, "", nil)
+	// hiding twice is fine
+	api.ok(t, http.MethodPost, path+"/hide", alice, "", nil)
+	for _, listing := range []string{"/api/posts/", "/api/posts/" + post.Category, "/api/user/bob"} {
+		if api.listed(t, listing, alice, post.ID) {
+			t.Errorf("hidden post is in %v", listing)
+		}
+		if !api.listed(t, listing, bob, post.ID) {
+			t.Errorf("post hidden by alice is missing in %v of bob", listing)
+		}
+	}
+	// the post itself is still there
+	api.ok(t, http.MethodGet, path, alice, "", nil)
+
+	var elems []posts.Post
+	api.ok(t, http.MethodGet, "/api/user/alice/hidden", alice, "", &elems)
+	if len(elems) != 1 || elems[0].ID != post.ID {
+		t.Errorf("hidden posts = %+v, want %v", elems, post.ID)
+	}
+	rec := api.do(http.MethodGet, "/api/user/alice/hidden", bob, "")
+	assertError(t, rec, http.StatusForbidden, ErrNotOwner.Error())
+
+	api.ok(t, http.MethodPost, path+"/unhide", alice, "", nil)
+	if !api.listed(t, "/api/posts/", alice, post.ID) {
+		t.Error("unhidden post is missing in the listing")
+	}
+	rec = api.do(http.MethodPost, path+"/unhide", alice, "")
+	assertError(t, rec, http.StatusNotFound, hidden.ErrNotHidden.Error())
+	rec = api.do(http.MethodPost, "/api/post/999/hide", alice, "")
+	assertError(t, rec, http.StatusNotFound, posts.ErrRecordNotFound.Error())
+}
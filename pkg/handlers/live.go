@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/stream"
+	"net/http"
+	"time"
+)
+
+const (
+	liveWriteWait  = 10 * time.Second
+	livePongWait   = 60 * time.Second
+	livePingPeriod = livePongWait * 9 / 10
+)
+
+const (
+	LiveCommentAdded   = "comment_added"
+	LiveCommentDeleted = "comment_deleted"
+	LiveScore          = "score"
+	LivePostDeleted    = "post_deleted"
+)
+
+type LiveHandler struct {
+	Broker    *stream.Broker
+	PostsRepo posts.PostRepo
+	BlockRepo blocks.BlockRepo
+	Sessions  *session.SessionsManager
+	Upgrader  websocket.Upgrader
+	Logger    *zap.SugaredLogger
+}
+
+type LiveMessage struct {
+	Type             string         `json:"type"`
+	EventID          uint64         `json:"eventId"`
+	PostID           string         `json:"postId"`
+	Comment          *posts.Comment `json:"comment,omitempty"`
+	CommentID        string         `json:"commentId,omitempty"`
+	Score            int64          `json:"score"`
+	UpvotePercentage uint8          `json:"upvotePercentage"`
+}
+
+func NewLiveMessage(event stream.Event) (LiveMessage, error) {
+	update := stream.PostUpdate{}
+	err := json.Unmarshal(event.Data, &update)
+	if err != nil {
+		return LiveMessage{}, err
+	}
+	msg := LiveMessage{
+		EventID:          event.ID,
+		PostID:           update.Post.ID,
+		CommentID:        update.CommentID,
+		Score:            update.Post.Score,
+		UpvotePercentage: update.Post.UpvotePercentage,
+	}
+	switch event.Type {
+	case stream.EventCommentAdded:
+		msg.Type = LiveCommentAdded
+		for i := range update.Post.Comments {
+			if update.Post.Comments[i].ID == update.CommentID {
+				msg.Comment = &update.Post.Comments[i]
+				break
+			}
+		}
+	case stream.EventCommentDeleted:
+		msg.Type = LiveCommentDeleted
+	case stream.EventVoted:
+		msg.Type = LiveScore
+	case stream.EventPostDeleted:
+		msg.Type = LivePostDeleted
+	default:
+		msg.Type = event.Type
+	}
+	return msg, nil
+}
+
+// authorize accepts the session from the Authorization header or, since
+// browsers can't set headers on WebSocket and EventSource requests, from the
+// token parameter
+func authorize(sm *session.SessionsManager, r *http.Request) (*session.Session, error) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err == nil {
+		return sess, nil
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return nil, session.ErrNoAuth
+	}
+	return sm.CheckToken(r.Context(), token)
+}
+
+func (lh *LiveHandler) Thread(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID, ok := vars[ParamPostID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
+		return
+	}
+	sess, err := authorize(lh.Sessions, r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	_, err = lh.PostsRepo.GetByID(r.Context(), postID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	conn, err := lh.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		lh.Logger.Errorf("Upgrade live thread of post with ID: %v error: %v", postID, err)
+		return
+	}
+	defer conn.Close()
+
+	sub, _ := lh.Broker.Subscribe(stream.PostTopic(postID), 0)
+	defer lh.Broker.Unsubscribe(sub)
+	lh.Logger.Infof("Open live thread of post with ID: %v for user with ID: %v", postID, sess.UserID)
+
+	closed := make(chan struct{})
+	go func() {
+		// the client sends nothing, reading is needed to process control frames
+		defer close(closed)
+		conn.SetReadLimit(512)
+		_ = conn.SetReadDeadline(time.Now().Add(livePongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(livePongWait))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(livePingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			lh.Logger.Infof("Close live thread of post with ID: %v for user with ID: %v", postID, sess.UserID)
+			return
+		case <-lh.Broker.Done():
+			_ = conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			_ = conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+			return
+		case event, ok := <-sub.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if !ok {
+				lh.Logger.Infof("Drop slow client of live thread of post with ID: %v", postID)
+				_ = conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+				return
+			}
+			msg, err := NewLiveMessage(event)
+			if err != nil {
+				lh.Logger.Errorf("Decode event %v error: %v", event.ID, err)
+				continue
+			}
+			if msg.Comment != nil {
+				isBlocked, err := lh.BlockRepo.IsBlocked(sess.Login, msg.Comment.Author.Username)
+				if err != nil || isBlocked {
+					continue
+				}
+			}
+			if err = conn.WriteJSON(msg); err != nil {
+				return
+			}
+			if msg.Type == LivePostDeleted {
+				_ = conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, "post deleted"))
+				return
+			}
+		case <-ping.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/stream"
+	"myredditclone/pkg/user"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const liveSubscribers = 200
+
+type liveServer struct {
+	*httptest.Server
+	broker *stream.Broker
+	blocks *blocks.BlockMemoryRepository
+	sm     *session.SessionsManager
+	post   posts.Post
+}
+
+func newLiveServer(t *testing.T) *liveServer {
+	t.Helper()
+	repo := posts.NewPostMemoryRepository()
+	post := posts.Post{Title: "live", Category: "music", Type: "text", Text: "x"}
+	posts.AddDefaultFieldsPost(&post, session.Session{UserID: 1, Login: "author"})
+	if _, err := repo.Add(context.Background(), &post); err != nil {
+		t.Fatal(err)
+	}
+	ls := &liveServer{
+		broker: stream.NewBroker(),
+		blocks: blocks.NewBlockMemoryRepository(),
+		sm:     session.NewSessionManager(),
+		post:   post,
+	}
+	lh := &LiveHandler{
+		Broker:    ls.broker,
+		PostsRepo: repo,
+		BlockRepo: ls.blocks,
+		Sessions:  ls.sm,
+		Logger:    zap.NewNop().Sugar(),
+	}
+	r := mux.NewRouter()
+	r.HandleFunc("/api/post/{"+ParamPostID+"}/live", lh.Thread)
+	ls.Server = httptest.NewServer(r)
+	t.Cleanup(ls.Close)
+	return ls
+}
+
+// dial opens the live thread of the post as a new user
+func (ls *liveServer) dial(t *testing.T, login string) (*websocket.Conn, error) {
+	sess, err := ls.sm.Create(nil, 2, login)
+	if err != nil {
+		return nil, err
+	}
+	token, err := session.CreateNewToken(user.User{ID: 2, Login: login}, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	url := "ws" + strings.TrimPrefix(ls.URL, "http") + "/api/post/" + ls.post.ID + "/live?token=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	return conn, err
+}
+
+// waitSubscribers waits for the handlers to subscribe after the upgrade
+func (ls *liveServer) waitSubscribers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ls.broker.Subscribers(stream.PostTopic(ls.post.ID)) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d clients subscribed", ls.broker.Subscribers(stream.PostTopic(ls.post.ID)), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (ls *liveServer) comment(author, body string) error {
+	post := ls.post
+	comm := posts.Comment{ID: body, Body: body, Author: posts.Author{Username: author}}
+	post.Comments = append(post.Comments, comm)
+	return ls.broker.Handle(events.CommentAdded{Post: post, Comment: comm})
+}
+
+func TestLiveThreadConcurrentSubscribers(t *testing.T) {
+	ls := newLiveServer(t)
+	conns := make([]*websocket.Conn, liveSubscribers)
+	errs := make(chan error, liveSubscribers)
+	wg := sync.WaitGroup{}
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := ls.dial(t, "viewer")
+			if err != nil {
+				errs <- err
+				return
+			}
+			conns[i] = conn
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	for _, conn := range conns {
+		defer conn.Close()
+	}
+	ls.waitSubscribers(t, liveSubscribers)
+
+	post := ls.post
+	post.Score = 5
+	if err := ls.broker.Handle(events.Voted{Post: post, Vote: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.comment("someone", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.broker.Handle(events.PostDeleted{Post: post}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{LiveScore, LiveCommentAdded, LivePostDeleted}
+	errs = make(chan error, liveSubscribers)
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn *websocket.Conn) {
+			defer wg.Done()
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var lastID uint64
+			for _, typ := range want {
+				msg := LiveMessage{}
+				if err := conn.ReadJSON(&msg); err != nil {
+					errs <- err
+					return
+				}
+				if msg.Type != typ || msg.EventID <= lastID {
+					errs <- errors.New("got " + msg.Type + ", want " + typ + " in order")
+					return
+				}
+				lastID = msg.EventID
+			}
+			_, _, err := conn.ReadMessage()
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				errs <- errors.New("no normal close after the post deletion: " + err.Error())
+			}
+		}(conn)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestLiveThreadSkipsBlockedAuthors(t *testing.T) {
+	ls := newLiveServer(t)
+	if err := ls.blocks.Block("viewer", "troll"); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := ls.dial(t, "viewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ls.waitSubscribers(t, 1)
+
+	if err = ls.comment("troll", "blocked"); err != nil {
+		t.Fatal(err)
+	}
+	if err = ls.comment("someone", "visible"); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg := LiveMessage{}
+	if err = conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Comment == nil || msg.Comment.Body != "visible" {
+		t.Fatalf("got %+v, want the comment of the not blocked author", msg)
+	}
+}
+
+func TestLiveThreadRequiresAuth(t *testing.T) {
+	ls := newLiveServer(t)
+	url := "ws" + strings.TrimPrefix(ls.URL, "http") + "/api/post/" + ls.post.ID + "/live"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("connected without a token")
+	}
+	if resp == nil || resp.StatusCode != 401 {
+		t.Fatalf("got %v, want 401", resp)
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"myredditclone/pkg/notifications"
+	"myredditclone/pkg/session"
+	"net/http"
+)
+
+type NotificationHandler struct {
+	NotificationsRepo notifications.NotificationRepo
+	Logger            *zap.SugaredLogger
+}
+
+type NotificationsResponse struct {
+	Unread        int                          `json:"unread"`
+	Notifications []notifications.Notification `json:"notifications"`
+}
+
+func (nh *NotificationHandler) List(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	elems, err := nh.NotificationsRepo.GetByRecipient(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	unread, err := nh.NotificationsRepo.UnreadCount(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if r.URL.Query().Get("unread") == "true" {
+		needElems := make([]notifications.Notification, 0, unread)
+		for _, v := range elems {
+			if !v.Read {
+				needElems = append(needElems, v)
+			}
+		}
+		elems = needElems
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, NotificationsResponse{
+		Unread:        unread,
+		Notifications: elems,
+	})
+	nh.Logger.Infof("Viewed notifications of user with ID: %v", sess.UserID)
+}
+
+func (nh *NotificationHandler) UnreadCount(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	unread, err := nh.NotificationsRepo.UnreadCount(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]int{"unread": unread})
+}
+
+func (nh *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	notificationID, ok := vars[ParamNotificationID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't NOTIFICATION_ID")
+		return
+	}
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	err = nh.NotificationsRepo.MarkRead(sess.Login, notificationID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	unread, err := nh.NotificationsRepo.UnreadCount(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]int{"unread": unread})
+	nh.Logger.Infof("Mark notification with ID: %v as read for user with ID: %v", notificationID, sess.UserID)
+}
+
+func (nh *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	marked, err := nh.NotificationsRepo.MarkAllRead(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]int{"marked": marked, "unread": 0})
+	nh.Logger.Infof("Mark all notifications as read for user with ID: %v", sess.UserID)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"myredditclone/pkg/oauth"
+	"myredditclone/pkg/session"
+	"net/http"
+)
+
+type OAuthHandler struct {
+	Server *oauth.Server
+	Logger *zap.SugaredLogger
+}
+
+func (oh *OAuthHandler) AddApp(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	ad := &struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirectUris"`
+		Confidential bool     `json:"confidential"`
+	}{}
+	if !readJSON(w, r, ad) {
+		return
+	}
+	app, secret, err := oh.Server.RegisterApp(sess.Login, ad.Name, ad.RedirectURIs, ad.Confidential)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	resp := map[string]interface{}{"app": app}
+	if secret != "" {
+		resp["clientSecret"] = secret
+	}
+	w.WriteHeader(http.StatusCreated)
+	MarshalAndWrite(w, resp)
+	oh.Logger.Infof("Registered OAuth app with client ID: %v by user with ID: %v", app.ClientID, sess.UserID)
+}
+
+func (oh *OAuthHandler) ListApps(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	apps, err := oh.Server.Apps.GetByOwner(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, apps)
+}
+
+func (oh *OAuthHandler) DeleteApp(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	clientID, ok := mux.Vars(r)[ParamClientID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't CLIENT_ID")
+		return
+	}
+	err = oh.Server.DeleteApp(sess.Login, clientID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"message": "success"})
+	oh.Logger.Infof("Deleted OAuth app with client ID: %v", clientID)
+}
+
+func authRequest(r *http.Request) oauth.AuthRequest {
+	return oauth.AuthRequest{
+		ResponseType:        r.FormValue("response_type"),
+		ClientID:            r.FormValue("client_id"),
+		RedirectURI:         r.FormValue("redirect_uri"),
+		Scope:               r.FormValue("scope"),
+		State:               r.FormValue("state"),
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+	}
+}
+
+// Consent describes the authorization request for the consent screen
+func (oh *OAuthHandler) Consent(w http.ResponseWriter, r *http.Request) {
+	req := authRequest(r)
+	app, err := oh.Server.ValidateClient(req)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	scopes, err := oh.Server.ValidateRequest(req)
+	if err != nil {
+		oauthError(w, err)
+		return
+	}
+	described := make(map[string]string, len(scopes))
+	for _, scope := range scopes {
+		described[scope] = oauth.ScopeDescriptions[scope]
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{
+		"clientId": app.ClientID,
+		"name":     app.Name,
+		"owner":    app.Owner,
+		"scopes":   described,
+	})
+}
+
+// Authorize is the user's answer on the consent screen, the client is sent
+// where to redirect the user, as XHR can't follow the redirect itself
+func (oh *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	req := authRequest(r)
+	var redirect string
+	if r.FormValue("approve") == "true" {
+		redirect, err = oh.Server.Approve(sess.Login, req)
+	} else {
+		redirect, err = oh.Server.Deny(req)
+	}
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"redirectUri": redirect})
+	oh.Logger.Infof("User with ID: %v answered OAuth request of client ID: %v", sess.UserID, req.ClientID)
+}
+
+// client authenticates the app by HTTP Basic or by the form fields
+func (oh *OAuthHandler) client(r *http.Request) (oauth.App, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	return oh.Server.AuthenticateClient(clientID, secret)
+}
+
+// oauthError writes the errors of the OAuth endpoints as RFC 6749 requires
+func oauthError(w http.ResponseWriter, err error) {
+	oerr := &oauth.Error{}
+	if !errors.As(err, &oerr) {
+		oerr = &oauth.Error{Code: "server_error"}
+	}
+	status := http.StatusBadRequest
+	switch oerr.Code {
+	case "invalid_client":
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	case "server_error":
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	resp, _ := json.Marshal(oerr)
+	_, _ = w.Write(resp)
+}
+
+func (oh *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	app, err := oh.client(r)
+	if err != nil {
+		oauthError(w, err)
+		return
+	}
+	var resp oauth.TokenResponse
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		resp, err = oh.Server.ExchangeCode(app, r.PostFormValue("code"), r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier"))
+	case "refresh_token":
+		resp, err = oh.Server.Refresh(app, r.PostFormValue("refresh_token"))
+	default:
+		err = &oauth.Error{Code: "unsupported_grant_type"}
+	}
+	if err != nil {
+		oauthError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, resp)
+	oh.Logger.Infof("Issued OAuth tokens for client ID: %v", app.ClientID)
+}
+
+func (oh *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	app, err := oh.client(r)
+	if err != nil {
+		oauthError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, oh.Server.Introspect(r.Context(), app, r.PostFormValue("token")))
+}
+
+func (oh *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	app, err := oh.client(r)
+	if err != nil {
+		oauthError(w, err)
+		return
+	}
+	oh.Server.Revoke(app, r.PostFormValue("token"))
+	w.WriteHeader(http.StatusOK)
+}
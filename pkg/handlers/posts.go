@@ -2,23 +2,32 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
-	"github.com/asaskevich/govalidator"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 	"io"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/hidden"
 	"myredditclone/pkg/posts"
+	"myredditclone/pkg/saved"
 	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
 	"net/http"
-	"sort"
-	"strconv"
-	"strings"
-	"time"
 )
 
+// PostResponse is the post as the current user sees it
+type PostResponse struct {
+	posts.Post
+	Saved bool `json:"saved"`
+}
+
 type PostHandler struct {
-	PostsRepo posts.PostRepo
-	Logger    *zap.SugaredLogger
+	Service    *posts.PostService
+	SavedRepo  saved.SavedRepo
+	HiddenRepo hidden.HiddenRepo
+	BlockRepo  blocks.BlockRepo
+	UserRepo   user.UserRepo
+	Logger     *zap.SugaredLogger
 }
 
 func MarshalAndWrite(w http.ResponseWriter, data interface{}) {
@@ -33,55 +42,100 @@ func MarshalAndWrite(w http.ResponseWriter, data interface{}) {
 	}
 }
 
-func SortSlicePosts(elems []posts.Post) []posts.Post {
-	sort.Slice(elems, func(i, j int) bool {
-		if elems[i].Score == elems[j].Score {
-			return elems[i].Created < elems[j].Created
-		}
-		return elems[i].Score > elems[j].Score
-	})
-	return elems
-}
-
-func (ph *PostHandler) List(w http.ResponseWriter, r *http.Request) {
-	elems, err := ph.PostsRepo.GetAll()
+// personalize adds to the posts what depends on the current user
+func (ph *PostHandler) personalize(r *http.Request, elems []posts.Post) ([]PostResponse, error) {
+	resp := make([]PostResponse, len(elems))
+	for i := range elems {
+		resp[i].Post = elems[i]
+	}
+	sess, err := session.SessionFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "List error: DB err - Get all", http.StatusInternalServerError)
-		return
+		return resp, nil
 	}
-	elems = SortSlicePosts(elems)
-	for i := range elems {
-		elems[i].Votes = posts.MapToSlice(elems[i].VotesFromDB)
+	for i := range resp {
+		resp[i].Saved, err = ph.SavedRepo.IsSaved(sess.Login, resp[i].ID, "")
+		if err != nil {
+			return nil, err
+		}
+		resp[i].Comments, err = withoutBlocked(ph.BlockRepo, sess.Login, resp[i].Comments)
+		if err != nil {
+			return nil, err
+		}
 	}
-	w.WriteHeader(http.StatusOK)
-	MarshalAndWrite(w, elems)
+	return resp, nil
 }
 
-func (ph *PostHandler) Validate(post posts.Post) (param, value string, err error) {
-	if post.URL != "" && post.Text != "" {
-		return "urlAndText", post.URL + post.Text, fmt.Errorf("data was obtained simultaneously with two types of posts - containing links and text")
+// withoutBlocked drops the comments of the users blocked by the user with login
+func withoutBlocked(blockRepo blocks.BlockRepo, login string, elems []posts.Comment) ([]posts.Comment, error) {
+	comments := make([]posts.Comment, 0, len(elems))
+	for _, comm := range elems {
+		isBlocked, err := blockRepo.IsBlocked(login, comm.Author.Username)
+		if err != nil {
+			return nil, err
+		}
+		if !isBlocked {
+			comments = append(comments, comm)
+		}
 	}
-	if post.URL == "" && post.Text == "" {
-		return "noUrlAndNoText", "", fmt.Errorf("it was not specified what type of data came in")
+	return comments, nil
+}
+
+// listing drops the posts the current user doesn't want to see in listings,
+// orders them by the user's preferences and personalizes the rest
+func (ph *PostHandler) listing(r *http.Request, elems []posts.Post) ([]PostResponse, error) {
+	prefs := user.DefaultPreferences()
+	sess, err := session.SessionFromContext(r.Context())
+	if err == nil {
+		usr, err := ph.UserRepo.GetByLogin(r.Context(), sess.Login)
+		if err != nil {
+			return nil, err
+		}
+		prefs = usr.Preferences
 	}
-	if post.URL != "" {
-		isURL := govalidator.IsURL(post.URL)
-		if !isURL {
-			return "URL", post.URL, fmt.Errorf("URL is not valid")
+	needElems := make([]posts.Post, 0, len(elems))
+	for _, v := range elems {
+		if v.NSFW && !prefs.ShowNSFW {
+			continue
 		}
+		if sess != nil {
+			isHidden, err := ph.HiddenRepo.IsHidden(sess.Login, v.ID)
+			if err != nil {
+				return nil, err
+			}
+			isBlocked, err := ph.BlockRepo.IsBlocked(sess.Login, v.Author.Username)
+			if err != nil {
+				return nil, err
+			}
+			if isHidden || isBlocked {
+				continue
+			}
+		}
+		needElems = append(needElems, v)
 	}
-	return "", "", nil
+	return ph.personalize(r, posts.Sort(r.Context(), needElems, prefs.DefaultSort))
 }
 
-func (ph *PostHandler) AddDefaultFieldsPost(post *posts.Post, sess *session.Session) {
-	post.Score = 1
-	post.UpvoteNum = 1
-	post.Author.ID = strconv.FormatUint(sess.UserID, 10)
-	post.Author.Username = sess.Login
-	post.VotesFromDB = make(map[string]posts.Vote)
-	post.VotesFromDB[post.Author.ID] = posts.Vote{User: post.Author.ID, Vote: 1}
-	post.UpvotePercentage = 100
-	post.Created = time.Now().Format("2006-01-02T15:04:05.000")
+func (ph *PostHandler) personalizePost(r *http.Request, post posts.Post) (PostResponse, error) {
+	resp, err := ph.personalize(r, []posts.Post{post})
+	if err != nil {
+		return PostResponse{}, err
+	}
+	return resp[0], nil
+}
+
+func (ph *PostHandler) List(w http.ResponseWriter, r *http.Request) {
+	elems, err := ph.Service.List(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	resp, err := ph.listing(r, elems)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, resp)
 }
 
 func (ph *PostHandler) Add(w http.ResponseWriter, r *http.Request) {
@@ -89,76 +143,67 @@ func (ph *PostHandler) Add(w http.ResponseWriter, r *http.Request) {
 	bytes, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
-		http.Error(w, `Bad request`, http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Bad request")
 		return
 	}
 	err = json.Unmarshal(bytes, post)
 	if err != nil {
-		http.Error(w, `Bad form`, http.StatusBadRequest)
-		return
-	}
-	param, value, err := ph.Validate(*post)
-	if err != nil {
-		authErrResp(w, param, value, err)
+		jsonError(w, http.StatusBadRequest, "Bad form")
 		return
 	}
 	sess, err := session.SessionFromContext(r.Context())
 	if err != nil {
-		http.Error(w, `Session error`, http.StatusBadRequest)
+		WriteError(w, err)
 		return
 	}
-	ph.AddDefaultFieldsPost(post, sess)
-	lastID, err := ph.PostsRepo.Add(post)
+	lastID, err := ph.Service.CreatePost(r.Context(), post, *sess)
 	if err != nil {
-		http.Error(w, `Add error: DB err - Add`, http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
-	post.Votes = posts.MapToSlice(post.VotesFromDB)
 	w.WriteHeader(http.StatusOK)
-	MarshalAndWrite(w, post)
+	MarshalAndWrite(w, PostResponse{Post: *post})
 	ph.Logger.Infof("Add new post, LastInsertPostId: %v", lastID)
 }
 
 func (ph *PostHandler) ListPost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	postID, ok := vars["POST_ID"]
+	postID, ok := vars[ParamPostID]
 	if !ok {
-		http.Error(w, "Request URL hasn't POST_ID", http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
 		return
 	}
-	post, err := ph.PostsRepo.GetByID(postID)
+	post, err := ph.Service.View(r.Context(), postID)
 	if err != nil {
-		http.Error(w, `ListPost error: DB err - GetByID`, http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
-	post.Views++
-	err = ph.PostsRepo.Update(post)
+	resp, err := ph.personalizePost(r, post)
 	if err != nil {
-		http.Error(w, `ListPost error: DB err - Update`, http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
-	post.Votes = posts.MapToSlice(post.VotesFromDB)
 	w.WriteHeader(http.StatusOK)
-	MarshalAndWrite(w, post)
+	MarshalAndWrite(w, resp)
 	ph.Logger.Infof("View post with ID: %v", post.ID)
 }
 
 func (ph *PostHandler) AddComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	postID, ok := vars["POST_ID"]
+	postID, ok := vars[ParamPostID]
 	if !ok {
-		http.Error(w, "Request URL hasn't POST_ID", http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
 		return
 	}
 	sess, err := session.SessionFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "You aren't authorize", http.StatusBadRequest)
+		WriteError(w, err)
 		return
 	}
 	bytes, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
-		http.Error(w, `Bad request`, http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Bad request")
 		return
 	}
 	comments := make(map[string]string)
@@ -167,136 +212,131 @@ func (ph *PostHandler) AddComment(w http.ResponseWriter, r *http.Request) {
 
 	var newComment string
 	if newComment, ok = comments["comment"]; !ok {
-		http.Error(w, `Comment is absent`, http.StatusBadRequest)
+		WriteError(w, apperrors.Validation("comment", "", "Comment is absent"))
 		return
 	}
 
-	post, err := ph.PostsRepo.AddComment(postID, newComment, *sess)
+	post, err := ph.Service.AddComment(r.Context(), postID, comments["parentId"], newComment, *sess)
 	if err != nil {
-		http.Error(w, `AddComment error: DB err - AddComment`, http.StatusInternalServerError)
+		WriteError(w, err)
+		return
+	}
+	resp, err := ph.personalizePost(r, post)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	MarshalAndWrite(w, post)
+	MarshalAndWrite(w, resp)
 	ph.Logger.Infof("Insert new comment with body: %x, at post with ID: %v", newComment, postID)
 }
 
 func (ph *PostHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	requestVars := mux.Vars(r)
-	postID, ok := requestVars["POST_ID"]
+	postID, ok := requestVars[ParamPostID]
 	if !ok {
-		http.Error(w, "Request URL hasn't POST_ID", http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
 		return
 	}
-	commID, ok := requestVars["COMMENT_ID"]
+	commID, ok := requestVars[ParamCommentID]
 	if !ok {
-		http.Error(w, "Request URL hasn't COMMENT_ID", http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't COMMENT_ID")
 		return
 	}
 	sess, err := session.SessionFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "You aren't authorize", http.StatusBadRequest)
+		WriteError(w, err)
 		return
 	}
-	post, err := ph.PostsRepo.DeleteComment(postID, commID, *sess)
+	post, err := ph.Service.DeleteComment(r.Context(), postID, commID, *sess)
 	if err != nil {
-		http.Error(w, `DeleteComment error: DB err - DeleteComment`, http.StatusInternalServerError)
+		WriteError(w, err)
+		return
+	}
+	resp, err := ph.personalizePost(r, post)
+	if err != nil {
+		WriteError(w, err)
+		return
 	}
 	w.WriteHeader(http.StatusOK)
-	MarshalAndWrite(w, post)
+	MarshalAndWrite(w, resp)
 	ph.Logger.Infof("Delete comment with ID: %v, at post with ID^ %v", commID, postID)
 }
 
 func (ph *PostHandler) Vote(w http.ResponseWriter, r *http.Request) {
-	_, strVote, _ := strings.Cut(r.URL.Path, "/api/post/")
-	_, strVote, _ = strings.Cut(strVote, "/")
 	requestVars := mux.Vars(r)
-	postID, ok := requestVars["POST_ID"]
+	postID, ok := requestVars[ParamPostID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
+		return
+	}
+	strVote, ok := requestVars[ParamVote]
 	if !ok {
-		http.Error(w, "Request URL hasn't POST_ID", http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't VOTE")
 		return
 	}
 	sess, err := session.SessionFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "You aren't authorize", http.StatusBadRequest)
+		WriteError(w, err)
 		return
 	}
-
-	userIDStr := strconv.FormatUint(sess.UserID, 10)
-
-	var newVote int8
-	switch strVote {
-	case "upvote":
-		newVote = 1
-	case "downvote":
-		newVote = -1
-	case "unvote":
-		newVote = 0
-	default:
-		http.Error(w, `The vote type wasn't sent`, http.StatusBadRequest)
-		return
-	}
-	post, err := ph.PostsRepo.Vote(postID, userIDStr, newVote)
+	newVote, err := posts.ParseVote(strVote)
 	if err != nil {
-		http.Error(w, `Vote error: DB err - Vote`, http.StatusInternalServerError)
+		WriteError(w, err)
+		return
+	}
+	post, err := ph.Service.Vote(r.Context(), postID, *sess, newVote)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	resp, err := ph.personalizePost(r, post)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	MarshalAndWrite(w, post)
+	MarshalAndWrite(w, resp)
 	ph.Logger.Infof("Add new reaction: %v at post with ID: %v for user with ID: %v", strVote, post.ID, sess.UserID)
 }
 
 func (ph *PostHandler) GetAllAtTheCategory(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	category, ok := vars["CATEGORY_NAME"]
+	category, ok := vars[ParamCategory]
 	if !ok {
-		http.Error(w, "Request URL hasn't CATEGORY_NAME", http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't CATEGORY_NAME")
 		return
 	}
-	elems, err := ph.PostsRepo.GetAll()
+	elems, err := ph.Service.ListByCategory(r.Context(), category)
 	if err != nil {
-		http.Error(w, `GetAllAtTheCategory: DB err - GetAll. `, http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
-	needElems := make([]posts.Post, 0)
-	for _, v := range elems {
-		if v.Category == category {
-			needElems = append(needElems, v)
-		}
-	}
-	for i := range needElems {
-		needElems[i].Votes = posts.MapToSlice(needElems[i].VotesFromDB)
+	resp, err := ph.listing(r, elems)
+	if err != nil {
+		WriteError(w, err)
+		return
 	}
-	needElems = SortSlicePosts(needElems)
 	w.WriteHeader(http.StatusOK)
-	MarshalAndWrite(w, needElems)
+	MarshalAndWrite(w, resp)
 	ph.Logger.Infof("Viewed all posts at category: %v", category)
 }
 
 func (ph *PostHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	postID, ok := vars["POST_ID"]
+	postID, ok := vars[ParamPostID]
 	if !ok {
-		http.Error(w, "Request URL hasn't POST_ID", http.StatusBadRequest)
-		return
-	}
-	post, err := ph.PostsRepo.GetByID(postID)
-	if err != nil {
-		http.Error(w, `Delete error: DB err - GetByID`, http.StatusInternalServerError)
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
 		return
 	}
 	sess, err := session.SessionFromContext(r.Context())
 	if err != nil {
-		http.Error(w, "Session err", http.StatusBadRequest)
+		WriteError(w, err)
 		return
 	}
-	if strconv.FormatUint(sess.UserID, 10) != post.Author.ID {
-		http.Error(w, "The post was not deleted by its creator", http.StatusBadRequest)
-		return
-	}
-	err = ph.PostsRepo.Delete(postID)
+	err = ph.Service.DeletePost(r.Context(), postID, *sess)
 	if err != nil {
-		http.Error(w, `Delete error: DB err - Delete`, http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
 	w.Header().Set("Content-type", "application/json")
@@ -319,28 +359,22 @@ func (ph *PostHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 func (ph *PostHandler) GetAllAtUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	userLogin, ok := vars["USER_LOGIN"]
+	userLogin, ok := vars[ParamUserLogin]
 	if !ok {
-		http.Error(w, "Request URL hasn't USER_LOGIN", http.StatusBadRequest)
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
 		return
 	}
-
-	elems, err := ph.PostsRepo.GetAll()
+	elems, err := ph.Service.ListByAuthor(r.Context(), userLogin)
 	if err != nil {
-		http.Error(w, `GetAllAtUser error: DB err - GetAll`, http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
-	needElems := make([]posts.Post, 0)
-	for _, v := range elems {
-		if v.Author.Username == userLogin {
-			needElems = append(needElems, v)
-		}
-	}
-	for i := range needElems {
-		needElems[i].Votes = posts.MapToSlice(needElems[i].VotesFromDB)
+	resp, err := ph.listing(r, elems)
+	if err != nil {
+		WriteError(w, err)
+		return
 	}
-	needElems = SortSlicePosts(needElems)
 	w.WriteHeader(http.StatusOK)
-	MarshalAndWrite(w, needElems)
+	MarshalAndWrite(w, resp)
 	ph.Logger.Infof("Viewed all user's posts with Login: %v", userLogin)
 }
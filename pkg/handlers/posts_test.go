@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"myredditclone/pkg/posts"
+)
+
+func TestListPostNotFound(t *testing.T) {
+	api := newTestAPI(t)
+	rec := api.do(http.MethodGet, "/api/post/999", "", "")
+	assertError(t, rec, http.StatusNotFound, posts.ErrRecordNotFound.Error())
+}
+
+func TestUnvoteWithoutVote(t *testing.T) {
+	api := newTestAPI(t)
+	post := api.post(t, api.login(t, "alice"))
+	bob := api.login(t, "bob")
+
+	rec := api.do(http.MethodGet, "/api/post/"+post.ID+"/unvote", bob, "")
+	assertError(t, rec, http.StatusNotFound, posts.ErrNoVote.Error())
+
+	got, err := api.Posts.Get(context.Background(), post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Score != post.Score {
+		t.Errorf("score = %v after the failed unvote, want %v", got.Score, post.Score)
+	}
+}
+
+func TestAddCommentUnauthenticated(t *testing.T) {
+	api := newTestAPI(t)
+	post := api.post(t, api.login(t, "alice"))
+
+	for name, token := range map[string]string{"no token": "", "bad token": "garbage"} {
+		t.Run(name, func(t *testing.T) {
+			rec := api.do(http.MethodPost, "/api/post/"+post.ID, token, `{"comment":"hi"}`)
+			assertError(t, rec, http.StatusUnauthorized, "")
+			if rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("no WWW-Authenticate challenge")
+			}
+		})
+	}
+	got, err := api.Posts.Get(context.Background(), post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Comments) != 0 {
+		t.Errorf("comments = %+v, want none", got.Comments)
+	}
+}
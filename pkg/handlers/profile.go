@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/profile"
+	"myredditclone/pkg/user"
+	"net/http"
+	"time"
+)
+
+type ProfileHandler struct {
+	UserRepo  user.UserRepo
+	KarmaRepo profile.KarmaRepo
+	Service   *posts.PostService
+	Logger    *zap.SugaredLogger
+}
+
+func (ph *ProfileHandler) About(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userLogin, ok := vars[ParamUserLogin]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
+		return
+	}
+	usr, err := ph.UserRepo.GetByLogin(r.Context(), userLogin)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	karma, err := ph.KarmaRepo.Get(userLogin)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, profile.Build(usr, karma, time.Now()))
+	ph.Logger.Infof("Viewed profile of user with Login: %v", userLogin)
+}
+
+func (ph *ProfileHandler) Comments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userLogin, ok := vars[ParamUserLogin]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
+		return
+	}
+	_, err := ph.UserRepo.GetByLogin(r.Context(), userLogin)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	comments, err := ph.Service.ListCommentsByAuthor(r.Context(), userLogin)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, comments)
+	ph.Logger.Infof("Viewed all user's comments with Login: %v", userLogin)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/profile"
+)
+
+func TestProfileComments(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.login(t, "alice")
+	bob := api.login(t, "bob")
+	first := api.post(t, alice)
+	second := api.post(t, alice)
+	want := map[string]string{
+		api.comment(t, bob, first.ID, "one").ID:    first.ID,
+		api.comment(t, bob, second.ID, "two").ID:   second.ID,
+		api.comment(t, bob, second.ID, "three").ID: second.ID,
+	}
+	api.comment(t, alice, first.ID, "not bob's")
+
+	// the listing is public
+	var got []posts.UserComment
+	api.ok(t, http.MethodGet, "/api/user/bob/comments", "", "", &got)
+	if len(got) != len(want) {
+		t.Fatalf("comments = %+v, want %v", got, len(want))
+	}
+	for i, comm := range got {
+		if comm.Author.Username != "bob" || want[comm.ID] != comm.PostID || comm.PostTitle != "hello" || comm.Category != "music" {
+			t.Errorf("comment %v = %+v", i, comm)
+		}
+		if i > 0 && comm.Created > got[i-1].Created {
+			t.Errorf("comment %v is newer than the previous one", i)
+		}
+	}
+
+	api.ok(t, http.MethodGet, testUserPath+"/comments", "", "", &got)
+	if len(got) != 1 || got[0].Body != "not bob's" {
+		t.Errorf("comments of alice = %+v", got)
+	}
+	assertError(t, api.do(http.MethodGet, "/api/user/mallory/comments", "", ""), http.StatusNotFound, "")
+}
+
+func TestProfileAbout(t *testing.T) {
+	api := newTestAPI(t)
+	api.login(t, "alice")
+	if err := api.Karma.AddPost("alice", 120); err != nil {
+		t.Fatal(err)
+	}
+	var got profile.Profile
+	api.ok(t, http.MethodGet, testUserPath+"/about", "", "", &got)
+	if got.Username != "alice" || got.PostKarma != 120 || got.TotalKarma != 120 {
+		t.Errorf("profile = %+v", got)
+	}
+	if len(got.Trophies) != 1 || got.Trophies[0].Name != "100 Karma" {
+		t.Errorf("trophies = %+v, want 100 Karma", got.Trophies)
+	}
+	assertError(t, api.do(http.MethodGet, "/api/user/mallory/about", "", ""), http.StatusNotFound, "")
+}
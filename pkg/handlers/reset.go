@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/mail"
+	"myredditclone/pkg/middleware"
+	"myredditclone/pkg/reset"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+	"net/http"
+	"strings"
+)
+
+type ResetHandler struct {
+	UserRepo  user.UserRepo
+	ResetRepo reset.ResetRepo
+	Mailer    mail.Sender //should be a mail.Queue, or the time of the response tells the account exists
+	Sessions  *session.SessionsManager
+	Limiter   *middleware.RateLimiter //of the requests per client IP and per account, nil is unlimited
+	Logger    *zap.SugaredLogger
+	BaseURL   string //the scheme and the host the users reach the server at
+}
+
+// Request mails a reset link to the user found by the login or the email.
+// The response is the same whether the user exists or not
+func (rh *ResetHandler) Request(w http.ResponseWriter, r *http.Request) {
+	rd := &struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}{}
+	if !readJSON(w, r, rd) {
+		return
+	}
+	if rd.Username == "" && rd.Email == "" {
+		WriteError(w, apperrors.Validation("username", "", "username or email is required"))
+		return
+	}
+	keys := []string{"ip:" + middleware.ClientIP(r)}
+	if rd.Username != "" {
+		keys = append(keys, "login:"+rd.Username)
+	}
+	if rd.Email != "" {
+		keys = append(keys, "email:"+strings.ToLower(rd.Email))
+	}
+	if !rh.allow(w, keys...) {
+		return
+	}
+	var (
+		usr user.User
+		err error
+	)
+	if rd.Username != "" {
+		usr, err = rh.UserRepo.GetByLogin(r.Context(), rd.Username)
+	} else {
+		usr, err = rh.UserRepo.GetByEmail(r.Context(), rd.Email)
+	}
+	if err == nil && usr.Email != "" {
+		err = rh.send(usr)
+		if err != nil {
+			rh.Logger.Errorf("Failed to send reset email to user with ID: %v: %v", usr.ID, err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{
+		"message": "If the account has an email, a reset link was sent to it",
+	})
+}
+
+// allow limits the requests by the client and by the account asked for,
+// so nobody floods an inbox with the reset emails
+func (rh *ResetHandler) allow(w http.ResponseWriter, keys ...string) bool {
+	if rh.Limiter == nil {
+		return true
+	}
+	for _, key := range keys {
+		if delay, ok := rh.Limiter.Allow(key); !ok {
+			middleware.RetryAfter(w, delay)
+			return false
+		}
+	}
+	return true
+}
+
+func (rh *ResetHandler) send(usr user.User) error {
+	token, err := rh.ResetRepo.Create(usr.Login, reset.TokenTTL)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/password/reset?token=%s", strings.TrimSuffix(rh.BaseURL, "/"), token)
+	return rh.Mailer.Send(mail.Message{
+		To:      usr.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. "+
+			"To set a new one, open the link below or send the token to /api/password/reset/confirm:\n\n%s\n\n"+
+			"token: %s\n\nThe link is valid for %v. If it wasn't you, just ignore this email.\n",
+			usr.Login, link, token, reset.TokenTTL),
+	})
+}
+
+// Confirm sets the new password and logs the user out everywhere
+func (rh *ResetHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	cd := &struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{}
+	if !readJSON(w, r, cd) {
+		return
+	}
+	if cd.Password == "" {
+		WriteError(w, apperrors.Validation("password", "", "password is required"))
+		return
+	}
+	login, err := rh.ResetRepo.Consume(cd.Token)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	usr, err := rh.UserRepo.GetByLogin(r.Context(), login)
+	if err != nil {
+		WriteError(w, reset.ErrBadToken)
+		return
+	}
+	err = rh.UserRepo.ResetPassword(r.Context(), login, cd.Password)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	rh.Sessions.DestroyByUser(usr.ID, "")
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"message": "password changed"})
+	rh.Logger.Infof("Reset password for user with ID: %v", usr.ID)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"myredditclone/pkg/mail"
+	"myredditclone/pkg/middleware"
+	"myredditclone/pkg/reset"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+	"myredditclone/pkg/verify"
+)
+
+type mailbox struct {
+	sent []mail.Message
+	mu   sync.Mutex
+}
+
+func (m *mailbox) Send(msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// The links in emails must not follow the Host header, or an attacker
+// asking a reset for the victim gets the token sent to their own host
+func TestEmailLinksUsePublicURL(t *testing.T) {
+	const public = "https://reddit.example.com"
+	ctx := context.Background()
+	users := user.NewUserRepository()
+	if _, err := users.Register(ctx, "alice", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetEmail(ctx, "alice", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	box := &mailbox{}
+	rh := ResetHandler{
+		UserRepo:  users,
+		ResetRepo: reset.NewResetMemoryRepository(),
+		Mailer:    box,
+		Sessions:  session.NewSessionManager(),
+		Logger:    zap.NewNop().Sugar(),
+		BaseURL:   public,
+	}
+	req := httptest.NewRequest(http.MethodPost, "http://evil.example.net/api/password/reset",
+		strings.NewReader(`{"username":"alice"}`))
+	req.Header.Set("X-Forwarded-Host", "evil.example.net")
+	rec := httptest.NewRecorder()
+	rh.Request(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset request: status %v: %s", rec.Code, rec.Body)
+	}
+
+	usr, err := users.GetByLogin(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := verify.NewVerifier(verify.NewVerifyMemoryRepository(), box, users, public+"/")
+	if err := verifier.Send(usr); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(box.sent) != 2 {
+		t.Fatalf("sent %v emails, want 2", len(box.sent))
+	}
+	for _, msg := range box.sent {
+		if strings.Contains(msg.Body, "evil") {
+			t.Errorf("%q links to the request host:\n%s", msg.Subject, msg.Body)
+		}
+	}
+	if !strings.Contains(box.sent[0].Body, public+"/password/reset?token=") {
+		t.Errorf("reset email has no link to %v:\n%s", public, box.sent[0].Body)
+	}
+	if !strings.Contains(box.sent[1].Body, public+"/api/email/verify?token=") {
+		t.Errorf("verification email has no link to %v:\n%s", public, box.sent[1].Body)
+	}
+}
+
+func newResetHandler(t *testing.T, mailer mail.Sender) *ResetHandler {
+	t.Helper()
+	users := user.NewUserRepository()
+	for _, login := range []string{"alice", "bob"} {
+		if _, err := users.Register(context.Background(), login, "password"); err != nil {
+			t.Fatal(err)
+		}
+		if err := users.SetEmail(context.Background(), login, login+"@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &ResetHandler{
+		UserRepo:  users,
+		ResetRepo: reset.NewResetMemoryRepository(),
+		Mailer:    mailer,
+		Sessions:  session.NewSessionManager(),
+		Logger:    zap.NewNop().Sugar(),
+		BaseURL:   "http://localhost",
+	}
+}
+
+func requestReset(rh *ResetHandler, ip, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/password/reset", strings.NewReader(body))
+	req.RemoteAddr = ip + ":1234"
+	rec := httptest.NewRecorder()
+	rh.Request(rec, req)
+	return rec
+}
+
+// stuckMailer doesn't send until released, like a slow mail server
+type stuckMailer struct {
+	mailbox
+	release chan struct{}
+}
+
+func (m *stuckMailer) Send(msg mail.Message) error {
+	<-m.release
+	return m.mailbox.Send(msg)
+}
+
+// The response for an existing account must not wait for the mail server,
+// or its time tells the account exists
+func TestResetRequestDoesNotWaitForMail(t *testing.T) {
+	stuck := &stuckMailer{release: make(chan struct{})}
+	queue := mail.NewQueue(stuck, 8, zap.NewNop().Sugar())
+	rh := newResetHandler(t, queue)
+
+	responses := map[string]string{}
+	for _, login := range []string{"alice", "nobody"} {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- requestReset(rh, "192.0.2.1", `{"username":"`+login+`"}`) }()
+		select {
+		case rec := <-done:
+			if rec.Code != http.StatusOK {
+				t.Fatalf("reset of %v: status %v: %s", login, rec.Code, rec.Body)
+			}
+			responses[login] = rec.Body.String()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("reset of %v waits for the mail server", login)
+		}
+	}
+	if responses["alice"] != responses["nobody"] {
+		t.Errorf("responses differ: %q and %q", responses["alice"], responses["nobody"])
+	}
+
+	close(stuck.release)
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(stuck.sent) != 1 || stuck.sent[0].To != "alice@example.com" {
+		t.Errorf("sent %+v, want the email to alice", stuck.sent)
+	}
+}
+
+func TestResetRequestLimited(t *testing.T) {
+	box := &mailbox{}
+	rh := newResetHandler(t, box)
+	rh.Limiter = middleware.NewRateLimiter(1/time.Hour.Seconds(), 2)
+
+	cases := []struct {
+		ip, body string
+		status   int
+	}{
+		{"192.0.2.1", `{"username":"alice"}`, http.StatusOK},
+		{"192.0.2.2", `{"username":"alice"}`, http.StatusOK},
+		// another IP doesn't help with the same account
+		{"192.0.2.3", `{"username":"alice"}`, http.StatusTooManyRequests},
+		{"192.0.2.1", `{"email":"BOB@example.com"}`, http.StatusOK},
+		{"192.0.2.4", `{"email":"bob@example.com"}`, http.StatusOK},
+		{"192.0.2.5", `{"email":"bob@example.com"}`, http.StatusTooManyRequests},
+		// nor another account from the same IP
+		{"192.0.2.1", `{"username":"nobody"}`, http.StatusTooManyRequests},
+	}
+	for _, c := range cases {
+		rec := requestReset(rh, c.ip, c.body)
+		if rec.Code != c.status {
+			t.Errorf("%v from %v: status %v, want %v: %s", c.body, c.ip, rec.Code, c.status, rec.Body)
+			continue
+		}
+		if c.status == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%v from %v: no Retry-After", c.body, c.ip)
+		}
+	}
+	if len(box.sent) != 4 {
+		t.Errorf("sent %v emails, want 4", len(box.sent))
+	}
+}
@@ -5,37 +5,218 @@ import (
 	"go.uber.org/zap"
 	"myredditclone/pkg/middleware"
 	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
 	"net/http"
+	"path/filepath"
+	"strings"
 )
 
-func GenerateRoutes(uh UserHandler, ph PostHandler) *mux.Router {
+// Path parameters with the patterns of their values, a request with a value
+// not matching the pattern never reaches the handler
+const (
+	ParamPostID         = "POST_ID"
+	ParamCommentID      = "COMMENT_ID"
+	ParamVote           = "VOTE"
+	ParamCategory       = "CATEGORY_NAME"
+	ParamUserLogin      = "USER_LOGIN"
+	ParamNotificationID = "NOTIFICATION_ID"
+	ParamWebhookID      = "WEBHOOK_ID"
+	ParamTokenID        = "TOKEN_ID"
+	ParamClientID       = "CLIENT_ID"
+
+	postIDPattern         = "{" + ParamPostID + ":[0-9]+}"
+	commentIDPattern      = "{" + ParamCommentID + ":[0-9a-f]{32}}"
+	votePattern           = "{" + ParamVote + ":upvote|downvote|unvote}"
+	categoryPattern       = "{" + ParamCategory + "}"
+	userLoginPattern      = "{" + ParamUserLogin + "}"
+	notificationIDPattern = "{" + ParamNotificationID + ":[0-9a-f]{32}}"
+	webhookIDPattern      = "{" + ParamWebhookID + ":[0-9a-f]{32}}"
+	tokenIDPattern        = "{" + ParamTokenID + ":[0-9a-f]{32}}"
+	clientIDPattern       = "{" + ParamClientID + ":[0-9a-f]{32}}"
+)
+
+// Handlers are all the handlers served by the API
+type Handlers struct {
+	User         UserHandler
+	Post         PostHandler
+	Notification NotificationHandler
+	Stream       StreamHandler
+	Live         LiveHandler
+	Webhook      WebhookHandler
+	Saved        SavedHandler
+	Hidden       HiddenHandler
+	Block        BlockHandler
+	Profile      ProfileHandler
+	Account      AccountHandler
+	Reset        ResetHandler
+	TwoFactor    TwoFactorHandler
+	Token        TokenHandler
+	OAuth        OAuthHandler
+}
+
+func GenerateRoutes(h Handlers, staticDir string) *mux.Router {
+	// read, post, vote and moderate are what personal access tokens can be
+	// allowed to, account needs a login with the password. Whatever changes
+	// the state, even saving a post or marking a notification read, needs
+	// more than read
+	auth := func(scope string, handler http.HandlerFunc) http.Handler {
+		return middleware.RequireAuth(middleware.RequireScope(scope)(handler))
+	}
+	read := func(handler http.HandlerFunc) http.Handler { return auth(session.ScopeRead, handler) }
+	write := func(handler http.HandlerFunc) http.Handler { return auth(session.ScopePost, handler) }
+	vote := func(handler http.HandlerFunc) http.Handler { return auth(session.ScopeVote, handler) }
+	account := func(handler http.HandlerFunc) http.Handler { return auth(session.ScopeAccount, handler) }
+	role := func(handler http.HandlerFunc, roles ...string) http.Handler {
+		return middleware.RequireRole(roles...)(middleware.RequireScope(session.ScopeModerate)(handler))
+	}
+
+	// the API routes are named after their handlers
+	index := filepath.Join(staticDir, "html", "index.html")
 	r := mux.NewRouter()
-	r.Handle("/", http.FileServer(http.Dir("/static/html/")))
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static/"))))
-
-	r.HandleFunc("/api/register", uh.Register).Methods("POST")
-	r.HandleFunc("/api/login", uh.Login).Methods("POST")
-	r.HandleFunc("/api/posts/", ph.List).Methods("GET")
-	r.HandleFunc("/api/posts", ph.Add).Methods("POST")
-	r.HandleFunc("/api/posts/{CATEGORY_NAME}", ph.GetAllAtTheCategory).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}", ph.ListPost).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}", ph.AddComment).Methods("POST")
-	r.HandleFunc("/api/post/{POST_ID}/{COMMENT_ID}", ph.DeleteComment).Methods("DELETE")
-	r.HandleFunc("/api/post/{POST_ID}/upvote", ph.Vote).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}/downvote", ph.Vote).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}/unvote", ph.Vote).Methods("GET")
-	r.HandleFunc("/api/post/{POST_ID}", ph.Delete).Methods("DELETE")
-	r.HandleFunc("/api/user/{USER_LOGIN}", ph.GetAllAtUser).Methods("GET")
+	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, index)
+	})
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
+
+	api := r.PathPrefix("/api").Subrouter()
+	// mux loses the method mismatch behind the routes of the subrouters,
+	// so the not found handler tells 405 from 404 itself
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowed := allowedMethods(api, r); len(allowed) != 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			jsonError(w, http.StatusMethodNotAllowed, "The method is not allowed for the path")
+			return
+		}
+		jsonError(w, http.StatusNotFound, "Unknown API method")
+	})
+	api.HandleFunc("/register", h.User.Register).Methods("POST").Name("User.Register")
+	api.HandleFunc("/login", h.User.Login).Methods("POST").Name("User.Login")
+	api.HandleFunc("/login/2fa", h.User.LoginTwoFactor).Methods("POST").Name("User.LoginTwoFactor")
+	api.HandleFunc("/login/sso", h.User.SSOLogin).Methods("GET").Name("User.SSOLogin")
+	api.HandleFunc("/login/sso/callback", h.User.SSOCallback).Methods("GET").Name("User.SSOCallback")
+	api.HandleFunc("/password/reset", h.Reset.Request).Methods("POST").Name("Reset.Request")
+	api.HandleFunc("/password/reset/confirm", h.Reset.Confirm).Methods("POST").Name("Reset.Confirm")
+	api.HandleFunc("/email/verify", h.Account.VerifyEmail).Methods("GET").Name("Account.VerifyEmail")
+
+	api.HandleFunc("/posts/", h.Post.List).Methods("GET").Name("Post.List")
+	api.Handle("/posts", write(h.Post.Add)).Methods("POST").Name("Post.Add")
+	api.HandleFunc("/posts/stream", h.Stream.PostsStream).Methods("GET").Name("Stream.PostsStream")
+	api.HandleFunc("/posts/"+categoryPattern, h.Post.GetAllAtTheCategory).Methods("GET").Name("Post.GetAllAtTheCategory")
+
+	post := api.PathPrefix("/post/" + postIDPattern).Subrouter()
+	post.HandleFunc("", h.Post.ListPost).Methods("GET").Name("Post.ListPost")
+	post.Handle("", write(h.Post.AddComment)).Methods("POST").Name("Post.AddComment")
+	post.Handle("", write(h.Post.Delete)).Methods("DELETE").Name("Post.Delete")
+	post.Handle("/"+votePattern, vote(h.Post.Vote)).Methods("GET").Name("Post.Vote")
+	post.HandleFunc("/stream", h.Stream.PostStream).Methods("GET").Name("Stream.PostStream")
+	post.HandleFunc("/live", h.Live.Thread).Methods("GET").Name("Live.Thread")
+	post.Handle("/save", write(h.Saved.Save)).Methods("POST").Name("Saved.Save")
+	post.Handle("/unsave", write(h.Saved.Unsave)).Methods("POST").Name("Saved.Unsave")
+	post.Handle("/hide", write(h.Hidden.Hide)).Methods("POST").Name("Hidden.Hide")
+	post.Handle("/unhide", write(h.Hidden.Unhide)).Methods("POST").Name("Hidden.Unhide")
+	post.Handle("/"+commentIDPattern, write(h.Post.DeleteComment)).Methods("DELETE").Name("Post.DeleteComment")
+	post.Handle("/"+commentIDPattern+"/save", write(h.Saved.Save)).Methods("POST").Name("Saved.Save")
+	post.Handle("/"+commentIDPattern+"/unsave", write(h.Saved.Unsave)).Methods("POST").Name("Saved.Unsave")
+
+	usr := api.PathPrefix("/user/" + userLoginPattern).Subrouter()
+	usr.HandleFunc("", h.Post.GetAllAtUser).Methods("GET").Name("Post.GetAllAtUser")
+	usr.Handle("", account(h.Account.Delete)).Methods("DELETE").Name("Account.Delete")
+	usr.Handle("/password", account(h.Account.ChangePassword)).Methods("PUT").Name("Account.ChangePassword")
+	usr.Handle("/email", account(h.Account.SetEmail)).Methods("PUT").Name("Account.SetEmail")
+	usr.Handle("/email/verify", account(h.Account.ResendVerification)).Methods("POST").Name("Account.ResendVerification")
+	usr.Handle("/2fa", account(h.TwoFactor.Enroll)).Methods("POST").Name("TwoFactor.Enroll")
+	usr.Handle("/2fa", account(h.TwoFactor.Disable)).Methods("DELETE").Name("TwoFactor.Disable")
+	usr.Handle("/2fa/confirm", account(h.TwoFactor.Confirm)).Methods("POST").Name("TwoFactor.Confirm")
+	usr.Handle("/2fa/recovery", account(h.TwoFactor.RecoveryCodes)).Methods("POST").Name("TwoFactor.RecoveryCodes")
+	usr.Handle("/preferences", account(h.Account.Preferences)).Methods("GET").Name("Account.Preferences")
+	usr.Handle("/preferences", account(h.Account.SetPreferences)).Methods("PUT").Name("Account.SetPreferences")
+	usr.Handle("/roles", role(h.User.SetRoles, user.RoleAdmin)).Methods("PUT").Name("User.SetRoles")
+	usr.Handle("/saved", read(h.Saved.List)).Methods("GET").Name("Saved.List")
+	usr.HandleFunc("/about", h.Profile.About).Methods("GET").Name("Profile.About")
+	usr.HandleFunc("/comments", h.Profile.Comments).Methods("GET").Name("Profile.Comments")
+	usr.Handle("/hidden", read(h.Hidden.List)).Methods("GET").Name("Hidden.List")
+	usr.Handle("/blocked", account(h.Block.List)).Methods("GET").Name("Block.List")
+	usr.Handle("/block", account(h.Block.Block)).Methods("POST").Name("Block.Block")
+	usr.Handle("/unblock", account(h.Block.Unblock)).Methods("POST").Name("Block.Unblock")
+	usr.Handle("/tokens", account(h.Token.List)).Methods("GET").Name("Token.List")
+	usr.Handle("/tokens", account(h.Token.Add)).Methods("POST").Name("Token.Add")
+	usr.Handle("/tokens/"+tokenIDPattern, account(h.Token.Delete)).Methods("DELETE").Name("Token.Delete")
+
+	notifications := api.PathPrefix("/notifications").Subrouter()
+	notifications.Handle("", read(h.Notification.List)).Methods("GET").Name("Notification.List")
+	// EventSource can't send the Authorization header, the handler takes the token parameter too
+	notifications.HandleFunc("/stream", h.Stream.NotificationStream).Methods("GET").Name("Stream.NotificationStream")
+	notifications.Handle("/unread", read(h.Notification.UnreadCount)).Methods("GET").Name("Notification.UnreadCount")
+	notifications.Handle("/read", write(h.Notification.MarkAllRead)).Methods("POST").Name("Notification.MarkAllRead")
+	notifications.Handle("/"+notificationIDPattern+"/read", write(h.Notification.MarkRead)).Methods("POST").Name("Notification.MarkRead")
+
+	webhooks := api.PathPrefix("/webhooks").Subrouter()
+	webhooks.Handle("", account(h.Webhook.List)).Methods("GET").Name("Webhook.List")
+	webhooks.Handle("", account(h.Webhook.Add)).Methods("POST").Name("Webhook.Add")
+	webhooks.Handle("/deadletters", account(h.Webhook.DeadLetters)).Methods("GET").Name("Webhook.DeadLetters")
+	webhooks.Handle("/"+webhookIDPattern, account(h.Webhook.Delete)).Methods("DELETE").Name("Webhook.Delete")
+	webhooks.Handle("/"+webhookIDPattern+"/deliveries", account(h.Webhook.Deliveries)).Methods("GET").Name("Webhook.Deliveries")
+
+	oauth := api.PathPrefix("/oauth").Subrouter()
+	oauth.Handle("/apps", account(h.OAuth.ListApps)).Methods("GET").Name("OAuth.ListApps")
+	oauth.Handle("/apps", account(h.OAuth.AddApp)).Methods("POST").Name("OAuth.AddApp")
+	oauth.Handle("/apps/"+clientIDPattern, account(h.OAuth.DeleteApp)).Methods("DELETE").Name("OAuth.DeleteApp")
+	oauth.HandleFunc("/authorize", h.OAuth.Consent).Methods("GET").Name("OAuth.Consent")
+	oauth.Handle("/authorize", account(h.OAuth.Authorize)).Methods("POST").Name("OAuth.Authorize")
+	oauth.HandleFunc("/token", h.OAuth.Token).Methods("POST").Name("OAuth.Token")
+	oauth.HandleFunc("/introspect", h.OAuth.Introspect).Methods("POST").Name("OAuth.Introspect")
+	oauth.HandleFunc("/revoke", h.OAuth.Revoke).Methods("POST").Name("OAuth.Revoke")
+
 	r.NotFoundHandler = http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			http.ServeFile(w, r, "static/html/index.html")
+			http.ServeFile(w, r, index)
 		})
 	return r
 }
 
-func PostProcess(r *mux.Router, sm *session.SessionsManager, logger *zap.SugaredLogger) http.Handler {
+// allowedMethods lists the methods the router has routes for at the path of the request
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	allowed := make([]string, 0)
+	methods := []string{http.MethodDelete, http.MethodGet, http.MethodPatch, http.MethodPost, http.MethodPut}
+	for _, method := range methods {
+		req := r.Clone(r.Context())
+		req.Method = method
+		var match mux.RouteMatch
+		if router.Match(req, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// PostProcess adds the common middlewares, the extra ones run first
+func PostProcess(r *mux.Router, sm *session.SessionsManager, logger *zap.SugaredLogger, extra ...mux.MiddlewareFunc) http.Handler {
+	r.Use(extra...)
 	r.Use(middleware.Auth(sm))
 	r.Use(middleware.AccessLog(logger))
 	r.Use(middleware.Panic)
 	return r
 }
+
+// WithProbes serves the probes of the load balancer and the metrics before
+// the app, so they skip the rate limit, the access log and the SPA fallback
+func WithProbes(h HealthHandler, metrics http.Handler, app http.Handler) http.Handler {
+	probes := http.NewServeMux()
+	// the paths are taken for any method, the others must not reach the SPA
+	get := func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				jsonError(w, http.StatusMethodNotAllowed, "Probes accept only GET")
+				return
+			}
+			handler(w, r)
+		}
+	}
+	probes.HandleFunc("/healthz", get(h.Healthz))
+	probes.HandleFunc("/readyz", get(h.Readyz))
+	probes.HandleFunc("/version", get(h.Version))
+	probes.Handle("/metrics", get(metrics.ServeHTTP))
+	probes.Handle("/", app)
+	return probes
+}
@@ -0,0 +1,540 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pquerna/otp/totp"
+	"myredditclone/pkg/notifications"
+	"myredditclone/pkg/oauth"
+	"myredditclone/pkg/oidc"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/stream"
+	"myredditclone/pkg/user"
+	"myredditclone/pkg/webhooks"
+)
+
+const (
+	testPostID     = "42"
+	testHexID      = "0123456789abcdef0123456789abcdef"
+	testUserLogin  = "alice"
+	testCategory   = "music"
+	testPostPath   = "/api/post/" + testPostID
+	testUserPath   = "/api/user/" + testUserLogin
+	testHexSegment = "/" + testHexID
+)
+
+// routeFixture is the data the requests of the route cases work on
+type routeFixture struct {
+	alice, bob, zed, dan, erin, frank string //tokens of the sessions
+
+	first, second, third posts.Post //of alice, of bob in funny, of bob hidden by alice
+	comment              posts.Comment
+	notification         string
+	webhook              string
+	token                string
+	app                  oauth.App
+	appSecret            string
+	challenge            string //of frank, who has two-factor authentication
+	erinCode             string //confirms the enrollment of erin
+	bobRecovery          []string
+	frankRecovery        []string
+	provider             string //URL of the SSO provider
+}
+
+// routeCase is a request with what only its handler answers
+type routeCase struct {
+	handler string
+	method  string
+	path    string
+	token   string
+	body    string
+	form    url.Values
+	stream  bool //the request ends right after the response starts
+	status  int
+	want    string //in the response body
+	check   func(t *testing.T, rec *httptest.ResponseRecorder)
+}
+
+func routeCases(f routeFixture) []routeCase {
+	postPath := "/api/post/" + f.first.ID
+	commentPath := postPath + "/" + f.comment.ID
+	second := "/api/post/" + f.second.ID
+	third := "/api/post/" + f.third.ID
+	const redirect = "https://app.example.com/callback"
+	authorization := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {f.app.ClientID},
+		"redirect_uri":          {redirect},
+		"scope":                 {session.ScopeRead},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGrSstw-cM"},
+		"code_challenge_method": {"S256"},
+	}
+	approval := url.Values{"approve": {"true"}}
+	for k, v := range authorization {
+		approval[k] = v
+	}
+	client := url.Values{"client_id": {f.app.ClientID}, "client_secret": {f.appSecret}}
+	withClient := func(extra url.Values) url.Values {
+		form := url.Values{}
+		for _, values := range []url.Values{client, extra} {
+			for k, v := range values {
+				form[k] = v
+			}
+		}
+		return form
+	}
+
+	return []routeCase{
+		{handler: "User.Register", method: "POST", path: "/api/register",
+			body: `{"username":"carol","password":"password"}`, status: 200, want: `"token"`},
+		{handler: "User.Login", method: "POST", path: "/api/login",
+			body: `{"username":"dan","password":"password"}`, status: 200, want: `"token"`},
+		{handler: "User.LoginTwoFactor", method: "POST", path: "/api/login/2fa",
+			body: `{"challenge":"` + f.challenge + `","code":"` + first(f.frankRecovery) + `"}`, status: 200, want: `"token"`},
+		{handler: "User.SSOLogin", method: "GET", path: "/api/login/sso", status: 302,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if to := rec.Header().Get("Location"); !strings.HasPrefix(to, f.provider+"/authorize?") {
+					t.Errorf("redirected to %q, want the provider", to)
+				}
+			}},
+		{handler: "User.SSOCallback", method: "GET", path: "/api/login/sso/callback?error=access_denied",
+			status: 401, want: "identity provider refused: access_denied"},
+		{handler: "Reset.Request", method: "POST", path: "/api/password/reset",
+			body: `{"username":"alice"}`, status: 200, want: "a reset link was sent"},
+		{handler: "Reset.Confirm", method: "POST", path: "/api/password/reset/confirm",
+			body: `{"token":"unknown","password":"secret"}`, status: 422, want: "The reset token is invalid"},
+		{handler: "Account.VerifyEmail", method: "GET", path: "/api/email/verify?token=unknown",
+			status: 422, want: "The verification token is invalid"},
+
+		{handler: "Post.List", method: "GET", path: "/api/posts/", status: 200, want: `"title":"second"`},
+		{handler: "Post.Add", method: "POST", path: "/api/posts", token: f.alice,
+			body: `{"category":"music","type":"text","title":"added","text":"body"}`, status: 200, want: `"title":"added"`},
+		{handler: "Stream.PostsStream", method: "GET", path: "/api/posts/stream", stream: true,
+			status: 200, want: "event: posts-marker"},
+		{handler: "Post.GetAllAtTheCategory", method: "GET", path: "/api/posts/funny", status: 200,
+			check: titles("second")},
+
+		{handler: "Post.ListPost", method: "GET", path: postPath, status: 200,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				var post posts.Post
+				if err := json.Unmarshal(rec.Body.Bytes(), &post); err != nil || post.ID != f.first.ID {
+					t.Errorf("body %s isn't the post %v: %v", rec.Body, f.first.ID, err)
+				}
+			}},
+		{handler: "Post.AddComment", method: "POST", path: postPath, token: f.alice,
+			body: `{"comment":"added comment"}`, status: 200, want: `"body":"added comment"`},
+		{handler: "Post.Vote", method: "GET", path: postPath + "/upvote", token: f.bob, status: 200, want: `"score":2`},
+		{handler: "Post.Vote", method: "GET", path: postPath + "/downvote", token: f.bob, status: 200, want: `"score":0`},
+		{handler: "Post.Vote", method: "GET", path: postPath + "/unvote", token: f.bob, status: 200, want: `"score":1`},
+		{handler: "Stream.PostStream", method: "GET", path: postPath + "/stream", stream: true,
+			status: 200, want: "event: post-marker"},
+		// the upgrade fails on the recorder, but only the websocket handler tries it
+		{handler: "Live.Thread", method: "GET", path: postPath + "/live", token: f.alice, status: 400,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if rec.Header().Get("Sec-Websocket-Version") == "" {
+					t.Errorf("no websocket handshake: %v %s", rec.Header(), rec.Body)
+				}
+			}},
+		{handler: "Saved.Save", method: "POST", path: second + "/save", token: f.alice,
+			status: 200, want: `{"commentId":"","postId":"` + f.second.ID + `","saved":true}`},
+		{handler: "Saved.Unsave", method: "POST", path: second + "/unsave", token: f.alice,
+			status: 200, want: `{"commentId":"","postId":"` + f.second.ID + `","saved":false}`},
+		{handler: "Saved.Save", method: "POST", path: commentPath + "/save", token: f.alice,
+			status: 200, want: `{"commentId":"` + f.comment.ID + `","postId":"` + f.first.ID + `","saved":true}`},
+		{handler: "Saved.Unsave", method: "POST", path: commentPath + "/unsave", token: f.alice,
+			status: 200, want: `{"commentId":"` + f.comment.ID + `","postId":"` + f.first.ID + `","saved":false}`},
+		{handler: "Hidden.Hide", method: "POST", path: second + "/hide", token: f.alice,
+			status: 200, want: `{"hidden":true,"postId":"` + f.second.ID + `"}`},
+		{handler: "Hidden.List", method: "GET", path: "/api/user/alice/hidden", token: f.alice,
+			status: 200, check: titles("second", "third")},
+		{handler: "Hidden.Unhide", method: "POST", path: third + "/unhide", token: f.alice,
+			status: 200, want: `{"hidden":false,"postId":"` + f.third.ID + `"}`},
+
+		{handler: "Post.GetAllAtUser", method: "GET", path: "/api/user/bob", status: 200, check: titles("second", "third")},
+		{handler: "Profile.About", method: "GET", path: "/api/user/alice/about", status: 200, want: `"username":"alice"`},
+		{handler: "Profile.Comments", method: "GET", path: "/api/user/bob/comments", status: 200, want: `"body":"of bob"`},
+		{handler: "Saved.List", method: "GET", path: "/api/user/alice/saved", token: f.alice,
+			status: 200, want: `"title":"first"`},
+		{handler: "Post.DeleteComment", method: "DELETE", path: commentPath, token: f.bob, status: 200,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if strings.Contains(rec.Body.String(), "of bob") {
+					t.Errorf("the comment is still there: %s", rec.Body)
+				}
+			}},
+		{handler: "Post.Delete", method: "DELETE", path: second, token: f.bob, status: 200, want: `"message":"success"`},
+		{handler: "User.SetRoles", method: "PUT", path: "/api/user/bob/roles", token: f.alice,
+			body: `{"roles":["moderator"]}`, status: 200, want: `{"roles":["moderator"],"username":"bob"}`},
+
+		{handler: "Account.SetEmail", method: "PUT", path: "/api/user/zed/email", token: f.zed,
+			body: `{"email":"zed@example.com"}`, status: 200, want: `{"email":"zed@example.com","verified":false}`},
+		{handler: "Account.ResendVerification", method: "POST", path: "/api/user/zed/email/verify", token: f.zed,
+			status: 200, want: "verification email sent"},
+		{handler: "Account.Preferences", method: "GET", path: "/api/user/zed/preferences", token: f.zed,
+			status: 200, want: `"defaultSort":"top"`},
+		{handler: "Account.SetPreferences", method: "PUT", path: "/api/user/zed/preferences", token: f.zed,
+			body: `{"defaultSort":"new"}`, status: 200, want: `"defaultSort":"new"`},
+		{handler: "Account.ChangePassword", method: "PUT", path: "/api/user/zed/password", token: f.zed,
+			body: `{"oldPassword":"password","newPassword":"secret"}`, status: 200, want: "password changed"},
+		{handler: "Account.Delete", method: "DELETE", path: "/api/user/zed", token: f.zed,
+			body: `{"password":"secret"}`, status: 200, want: `"message":"success"`},
+
+		{handler: "TwoFactor.Enroll", method: "POST", path: "/api/user/dan/2fa", token: f.dan, status: 200, want: `"uri":"otpauth://`},
+		{handler: "TwoFactor.Confirm", method: "POST", path: "/api/user/erin/2fa/confirm", token: f.erin,
+			body: `{"code":"` + f.erinCode + `"}`, status: 200, want: `"enabled":true`},
+		{handler: "TwoFactor.RecoveryCodes", method: "POST", path: "/api/user/bob/2fa/recovery", token: f.bob,
+			body: `{"code":"` + first(f.bobRecovery) + `"}`, status: 200, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if !strings.HasPrefix(rec.Body.String(), `{"recoveryCodes":[`) {
+					t.Errorf("body %s has no new codes", rec.Body)
+				}
+			}},
+		{handler: "TwoFactor.Disable", method: "DELETE", path: "/api/user/frank/2fa", token: f.frank,
+			body: `{"code":"` + last(f.frankRecovery) + `"}`, status: 200, want: `{"enabled":false}`},
+
+		{handler: "Token.List", method: "GET", path: "/api/user/alice/tokens", token: f.alice, status: 200, want: `"name":"listed"`},
+		{handler: "Token.Add", method: "POST", path: "/api/user/alice/tokens", token: f.alice,
+			body: `{"name":"added","scopes":["read"]}`, status: 201, want: `"name":"added"`},
+		{handler: "Token.Delete", method: "DELETE", path: "/api/user/alice/tokens/" + f.token, token: f.alice,
+			status: 200, want: `"message":"success"`},
+
+		{handler: "Notification.List", method: "GET", path: "/api/notifications", token: f.alice,
+			status: 200, want: `"body":"listed"`},
+		{handler: "Stream.NotificationStream", method: "GET", path: "/api/notifications/stream", token: f.alice,
+			stream: true, status: 200, want: "event: user-marker"},
+		{handler: "Notification.UnreadCount", method: "GET", path: "/api/notifications/unread", token: f.alice,
+			status: 200, want: `{"unread":1}`},
+		{handler: "Notification.MarkAllRead", method: "POST", path: "/api/notifications/read", token: f.alice,
+			status: 200, want: `{"marked":1,"unread":0}`},
+		{handler: "Notification.MarkRead", method: "POST", path: "/api/notifications/" + f.notification + "/read",
+			token: f.alice, status: 200, want: `{"unread":0}`},
+
+		{handler: "Webhook.List", method: "GET", path: "/api/webhooks", token: f.alice,
+			status: 200, want: "https://hooks.example.com/listed"},
+		{handler: "Webhook.Add", method: "POST", path: "/api/webhooks", token: f.alice,
+			body: `{"url":"https://hooks.example.com/added"}`, status: 200, want: "https://hooks.example.com/added"},
+		{handler: "Webhook.DeadLetters", method: "GET", path: "/api/webhooks/deadletters", token: f.alice,
+			status: 200, check: statuses(webhooks.StatusDead)},
+		{handler: "Webhook.Deliveries", method: "GET", path: "/api/webhooks/" + f.webhook + "/deliveries", token: f.alice,
+			status: 200, check: statuses(webhooks.StatusDelivered, webhooks.StatusDead)},
+		{handler: "Webhook.Delete", method: "DELETE", path: "/api/webhooks/" + f.webhook, token: f.alice,
+			status: 200, want: `{"message":"success"}`},
+
+		{handler: "OAuth.ListApps", method: "GET", path: "/api/oauth/apps", token: f.alice, status: 200, want: `"name":"listed"`},
+		{handler: "OAuth.AddApp", method: "POST", path: "/api/oauth/apps", token: f.alice,
+			body: `{"name":"added","redirectUris":["` + redirect + `"]}`, status: 201, want: `"name":"added"`},
+		{handler: "OAuth.Consent", method: "GET", path: "/api/oauth/authorize?" + authorization.Encode(),
+			status: 200, want: `"scopes":{"read":`},
+		{handler: "OAuth.Authorize", method: "POST", path: "/api/oauth/authorize", token: f.alice, form: approval,
+			status: 200, want: `{"redirectUri":"` + redirect + `?code=`},
+		{handler: "OAuth.Token", method: "POST", path: "/api/oauth/token", form: withClient(url.Values{"grant_type": {"password"}}),
+			status: 400, want: "unsupported_grant_type"},
+		{handler: "OAuth.Introspect", method: "POST", path: "/api/oauth/introspect", form: withClient(url.Values{"token": {"unknown"}}),
+			status: 200, want: `"active":false`},
+		{handler: "OAuth.Revoke", method: "POST", path: "/api/oauth/revoke", form: withClient(url.Values{"token": {"unknown"}}),
+			status: 200, check: func(t *testing.T, rec *httptest.ResponseRecorder) {
+				if rec.Body.Len() != 0 {
+					t.Errorf("body %s, want none", rec.Body)
+				}
+			}},
+		{handler: "OAuth.DeleteApp", method: "DELETE", path: "/api/oauth/apps/" + f.app.ClientID, token: f.alice,
+			status: 200, want: `"message":"success"`},
+
+		// blocking bob hides him from alice, so it goes last
+		{handler: "Block.List", method: "GET", path: "/api/user/alice/blocked", token: f.alice, status: 200, want: "mallory"},
+		{handler: "Block.Block", method: "POST", path: "/api/user/bob/block", token: f.alice,
+			status: 200, want: `{"blocked":true,"username":"bob"}`},
+		{handler: "Block.Unblock", method: "POST", path: "/api/user/mallory/unblock", token: f.alice,
+			status: 200, want: `{"blocked":false,"username":"mallory"}`},
+	}
+}
+
+func first(codes []string) string {
+	if len(codes) == 0 {
+		return ""
+	}
+	return codes[0]
+}
+
+func last(codes []string) string {
+	if len(codes) == 0 {
+		return ""
+	}
+	return codes[len(codes)-1]
+}
+
+// titles checks the response lists exactly the posts with the titles
+func titles(want ...string) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		var elems []posts.Post
+		if err := json.Unmarshal(rec.Body.Bytes(), &elems); err != nil {
+			t.Fatalf("body %s: %v", rec.Body, err)
+		}
+		got := make([]string, 0, len(elems))
+		for _, post := range elems {
+			got = append(got, post.Title)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("titles %v, want %v", got, want)
+		}
+	}
+}
+
+// statuses checks the response lists the deliveries with the statuses
+func statuses(want ...string) func(t *testing.T, rec *httptest.ResponseRecorder) {
+	return func(t *testing.T, rec *httptest.ResponseRecorder) {
+		var elems []webhooks.Delivery
+		if err := json.Unmarshal(rec.Body.Bytes(), &elems); err != nil {
+			t.Fatalf("body %s: %v", rec.Body, err)
+		}
+		got := make([]string, 0, len(elems))
+		for _, d := range elems {
+			got = append(got, d.Status)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("statuses %v, want %v", got, want)
+		}
+	}
+}
+
+func newRouteFixture(t *testing.T, api *testAPI, provider string) routeFixture {
+	t.Helper()
+	ctx := context.Background()
+	f := routeFixture{provider: provider}
+	f.bob = api.login(t, "bob")
+	f.zed = api.login(t, "zed")
+	f.dan = api.login(t, "dan")
+	f.erin = api.login(t, "erin")
+	f.frank = api.login(t, "frank")
+	api.login(t, "mallory")
+	admin, err := api.Users.Register(ctx, "alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = api.Users.SetRoles(ctx, "alice", user.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err = api.Users.SetEmail(ctx, "alice", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	admin.Roles = []string{user.RoleAdmin}
+	f.alice = api.session(t, admin)
+
+	newPost := func(token, title, category string) posts.Post {
+		var post posts.Post
+		api.ok(t, http.MethodPost, "/api/posts", token,
+			`{"category":"`+category+`","type":"text","title":"`+title+`","text":"body"}`, &post)
+		return post
+	}
+	f.first = newPost(f.alice, "first", "music")
+	f.second = newPost(f.bob, "second", "funny")
+	f.third = newPost(f.bob, "third", "music")
+	f.comment = api.comment(t, f.bob, f.first.ID, "of bob")
+	steps := []error{
+		api.Saved.Save("alice", f.first.ID, ""),
+		api.Hidden.Hide("alice", f.third.ID),
+		api.Blocks.Block("alice", "mallory"),
+	}
+
+	notification := &notifications.Notification{Type: "reply", Recipient: "alice", PostID: f.first.ID, Body: "listed"}
+	steps = append(steps, api.Notifications.Add(notification))
+	f.notification = notification.ID
+
+	hook := &webhooks.Webhook{Owner: "alice", URL: "https://hooks.example.com/listed"}
+	steps = append(steps, api.Webhooks.Add(hook))
+	f.webhook = hook.ID
+	for i, status := range []string{webhooks.StatusDelivered, webhooks.StatusDead} {
+		steps = append(steps, api.Webhooks.SaveDelivery(webhooks.Delivery{
+			ID:        fmt.Sprintf("%032x", i),
+			WebhookID: hook.ID,
+			Status:    status,
+		}))
+	}
+
+	token, _, err := api.Tokens.Create("alice", "listed", []string{session.ScopeRead})
+	steps = append(steps, err)
+	f.token = token.ID
+	f.app, f.appSecret, err = api.OAuth.RegisterApp("alice", "listed", []string{"https://app.example.com/callback"}, true)
+	steps = append(steps, err)
+
+	// the stream events are replayed after the first one
+	for _, event := range [][2]string{
+		{"unknown", "skipped"},
+		{stream.PostsTopic, "posts-marker"},
+		{stream.PostTopic(f.first.ID), "post-marker"},
+		{stream.UserTopic("alice"), "user-marker"},
+	} {
+		steps = append(steps, api.Broker.Publish(event[0], event[1], nil))
+	}
+
+	f.bobRecovery = enrollTwoFactor(t, api.TwoFactor, "bob")
+	f.frankRecovery = enrollTwoFactor(t, api.TwoFactor, "frank")
+	f.challenge, err = api.TwoFactor.StartChallenge("frank")
+	steps = append(steps, err)
+	secret, _, err := api.TwoFactor.Enroll("erin")
+	steps = append(steps, err)
+	f.erinCode, err = totp.GenerateCode(secret, time.Now())
+	steps = append(steps, err)
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// TestRoutes sends every route a request only its handler answers the way
+// the case expects
+func TestRoutes(t *testing.T) {
+	p := newStubProvider(t, "subject")
+	api := newTestAPI(t, func(h *Handlers) {
+		h.User.SSO = oidc.NewRelyingParty(oidc.Config{
+			Issuer:      p.URL,
+			ClientID:    ssoClientID,
+			RedirectURL: "http://localhost/api/login/sso/callback",
+		})
+	})
+	f := newRouteFixture(t, api, p.URL)
+	for _, c := range routeCases(f) {
+		t.Run(c.handler, func(t *testing.T) {
+			rec := serveRouteCase(api, c)
+			if rec.Code != c.status {
+				t.Fatalf("%v %v: status %v, want %v: %s", c.method, c.path, rec.Code, c.status, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), c.want) {
+				t.Errorf("%v %v: body %s, want %s", c.method, c.path, rec.Body, c.want)
+			}
+			if c.check != nil {
+				c.check(t, rec)
+			}
+		})
+	}
+}
+
+func serveRouteCase(api *testAPI, c routeCase) *httptest.ResponseRecorder {
+	var body io.Reader = strings.NewReader(c.body)
+	contentType := "application/json"
+	if c.form != nil {
+		body = strings.NewReader(c.form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	}
+	req := httptest.NewRequest(c.method, c.path, body)
+	req.Header.Set("Content-Type", contentType)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.stream {
+		req.Header.Set("Last-Event-ID", "1")
+		ctx, cancel := context.WithCancel(req.Context())
+		cancel()
+		req = req.WithContext(ctx)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestRoutesCovered keeps the cases above complete
+func TestRoutesCovered(t *testing.T) {
+	r := GenerateRoutes(Handlers{}, t.TempDir())
+	tested := map[string]bool{}
+	for _, c := range routeCases(routeFixture{}) {
+		tested[c.handler] = true
+	}
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if name := route.GetName(); name != "" && !tested[name] {
+			tmpl, _ := route.GetPathTemplate()
+			t.Errorf("route %v of %v is not tested", tmpl, name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoutesMethodNotAllowed(t *testing.T) {
+	r := GenerateRoutes(Handlers{}, t.TempDir())
+	cases := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{"DELETE", "/api/register", "POST"},
+		{"PUT", "/api/login", "POST"},
+		{"GET", "/api/posts", "POST"},
+		{"PATCH", testPostPath, "DELETE, GET, POST"},
+		{"POST", testPostPath + "/upvote", "GET"},
+		{"GET", testPostPath + "/save", "POST"},
+		{"POST", testUserPath + "/roles", "PUT"},
+		{"PUT", "/api/webhooks", "GET, POST"},
+		{"GET", "/api/oauth/token", "POST"},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%v %v: status %v, want 405", c.method, c.path, rec.Code)
+			continue
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%v %v: Content-Type %q, want JSON", c.method, c.path, ct)
+		}
+		if allow := rec.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("%v %v: Allow %q, want %q", c.method, c.path, allow, c.allow)
+		}
+	}
+}
+
+func TestRoutesUnknownPath(t *testing.T) {
+	r := GenerateRoutes(Handlers{}, t.TempDir())
+	cases := []string{
+		"/api/unknown",
+		"/api/post/abc",
+		"/api/post/" + testPostID + "/sideways",
+		"/api/webhooks/not-hex",
+	}
+	for _, path := range cases {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %v: status %v, want 404", path, rec.Code)
+		}
+	}
+}
+
+// TestRoutesReadScope keeps the read-only tokens from changing anything
+func TestRoutesReadScope(t *testing.T) {
+	r := GenerateRoutes(Handlers{}, t.TempDir())
+	readOnly := &session.Session{UserID: 1, Login: testUserLogin, Scopes: []string{session.ScopeRead}}
+	cases := []string{
+		testPostPath + "/save",
+		testPostPath + "/unsave",
+		testPostPath + "/hide",
+		testPostPath + "/unhide",
+		testPostPath + testHexSegment + "/save",
+		testPostPath + testHexSegment + "/unsave",
+		"/api/notifications/read",
+		"/api/notifications" + testHexSegment + "/read",
+	}
+	for _, path := range cases {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req = req.WithContext(session.ContextWithSession(req.Context(), readOnly))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("POST %v with a read token: status %v, want 403", path, rec.Code)
+		}
+	}
+}
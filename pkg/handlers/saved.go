@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/saved"
+	"myredditclone/pkg/session"
+	"net/http"
+)
+
+var (
+	ErrNotOwner = apperrors.New(apperrors.ErrForbidden, "This page is visible only to its owner")
+)
+
+type SavedHandler struct {
+	Service   *posts.PostService
+	SavedRepo saved.SavedRepo
+	BlockRepo blocks.BlockRepo
+	Logger    *zap.SugaredLogger
+}
+
+type SavedComment struct {
+	PostID  string        `json:"postId"`
+	Comment posts.Comment `json:"comment"`
+	Saved   string        `json:"saved"`
+}
+
+type SavedResponse struct {
+	Posts    []PostResponse `json:"posts"`
+	Comments []SavedComment `json:"comments"`
+}
+
+// toggle saves or unsaves the post or the comment from the URL
+func (sh *SavedHandler) toggle(w http.ResponseWriter, r *http.Request, save bool) {
+	vars := mux.Vars(r)
+	postID, ok := vars[ParamPostID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
+		return
+	}
+	commentID := vars[ParamCommentID]
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if commentID != "" {
+		_, err = sh.Service.GetComment(r.Context(), postID, commentID)
+	} else {
+		_, err = sh.Service.Get(r.Context(), postID)
+	}
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if save {
+		err = sh.SavedRepo.Save(sess.Login, postID, commentID)
+	} else {
+		err = sh.SavedRepo.Unsave(sess.Login, postID, commentID)
+	}
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"postId": postID, "commentId": commentID, "saved": save})
+	sh.Logger.Infof("Set saved %v for post with ID: %v, comment with ID: %v for user with ID: %v", save, postID, commentID, sess.UserID)
+}
+
+func (sh *SavedHandler) Save(w http.ResponseWriter, r *http.Request) {
+	sh.toggle(w, r, true)
+}
+
+func (sh *SavedHandler) Unsave(w http.ResponseWriter, r *http.Request) {
+	sh.toggle(w, r, false)
+}
+
+func (sh *SavedHandler) List(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userLogin, ok := vars[ParamUserLogin]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
+		return
+	}
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if sess.Login != userLogin {
+		WriteError(w, ErrNotOwner)
+		return
+	}
+	items, err := sh.SavedRepo.GetByUser(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	resp := SavedResponse{
+		Posts:    make([]PostResponse, 0),
+		Comments: make([]SavedComment, 0),
+	}
+	for _, item := range items {
+		if item.CommentID == "" {
+			post, err := sh.Service.Get(r.Context(), item.PostID)
+			if apperrors.Kind(err) == apperrors.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+			post.Comments, err = withoutBlocked(sh.BlockRepo, sess.Login, post.Comments)
+			if err != nil {
+				WriteError(w, err)
+				return
+			}
+			resp.Posts = append(resp.Posts, PostResponse{Post: post, Saved: true})
+			continue
+		}
+		comm, err := sh.Service.GetComment(r.Context(), item.PostID, item.CommentID)
+		if apperrors.Kind(err) == apperrors.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		// the comment saved before its author was blocked is hidden too
+		isBlocked, err := sh.BlockRepo.IsBlocked(sess.Login, comm.Author.Username)
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+		if isBlocked {
+			continue
+		}
+		resp.Comments = append(resp.Comments, SavedComment{
+			PostID:  item.PostID,
+			Comment: comm,
+			Saved:   item.Saved,
+		})
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, resp)
+	sh.Logger.Infof("Viewed saved items of user with ID: %v", sess.UserID)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/saved"
+)
+
+// assertSaved checks the post as the user with the token sees it
+func (api *testAPI) assertSaved(t *testing.T, postID, token string, want bool) {
+	t.Helper()
+	var post PostResponse
+	api.ok(t, http.MethodGet, "/api/post/"+postID, token, "", &post)
+	if post.Saved != want {
+		t.Errorf("post %v saved = %v, want %v", postID, post.Saved, want)
+	}
+	var elems []PostResponse
+	api.ok(t, http.MethodGet, "/api/posts/", token, "", &elems)
+	for _, elem := range elems {
+		if elem.ID == postID && elem.Saved != want {
+			t.Errorf("post %v in the listing saved = %v, want %v", postID, elem.Saved, want)
+		}
+	}
+}
+
+func TestSavePost(t *testing.T) {
+	api := newTestAPI(t)
+	bob := api.login(t, "bob")
+	post := api.post(t, bob)
+	alice := api.login(t, "alice")
+	path := "/api/post/" + post.ID
+
+	api.ok(t, http.MethodPost, path+"/save", alice, "", nil)
+	api.assertSaved(t, post.ID, alice, true)
+	api.assertSaved(t, post.ID, bob, false)
+	api.assertSaved(t, post.ID, "", false)
+	// saving twice is fine
+	api.ok(t, http.MethodPost, path+"/save", alice, "", nil)
+
+	api.ok(t, http.MethodPost, path+"/unsave", alice, "", nil)
+	api.assertSaved(t, post.ID, alice, false)
+	rec := api.do(http.MethodPost, path+"/unsave", alice, "")
+	assertError(t, rec, http.StatusNotFound, saved.ErrNotSaved.Error())
+
+	rec = api.do(http.MethodPost, "/api/post/999/save", alice, "")
+	assertError(t, rec, http.StatusNotFound, posts.ErrRecordNotFound.Error())
+	rec = api.do(http.MethodPost, path+"/save", "", "")
+	assertError(t, rec, http.StatusUnauthorized, "")
+}
+
+func TestSaveComment(t *testing.T) {
+	api := newTestAPI(t)
+	bob := api.login(t, "bob")
+	post := api.post(t, bob)
+	comm := api.comment(t, bob, post.ID, "worth keeping")
+	alice := api.login(t, "alice")
+	path := "/api/post/" + post.ID + "/" + comm.ID
+
+	api.ok(t, http.MethodPost, path+"/save", alice, "", nil)
+	var resp SavedResponse
+	api.ok(t, http.MethodGet, "/api/user/alice/saved", alice, "", &resp)
+	if len(resp.Posts) != 0 || len(resp.Comments) != 1 {
+		t.Fatalf("saved %+v, want the comment only", resp)
+	}
+	if got := resp.Comments[0]; got.PostID != post.ID || got.Comment.ID != comm.ID || got.Saved == "" {
+		t.Errorf("saved comment = %+v, want %v of post %v", got, comm.ID, post.ID)
+	}
+	// saving the comment doesn't save its post
+	api.assertSaved(t, post.ID, alice, false)
+
+	api.ok(t, http.MethodPost, path+"/unsave", alice, "", nil)
+	api.ok(t, http.MethodGet, "/api/user/alice/saved", alice, "", &resp)
+	if len(resp.Comments) != 0 {
+		t.Errorf("saved comments = %+v after unsave, want none", resp.Comments)
+	}
+
+	rec := api.do(http.MethodPost, "/api/post/"+post.ID+"/"+testHexID+"/save", alice, "")
+	assertError(t, rec, http.StatusNotFound, posts.ErrNoComment.Error())
+}
+
+func TestSavedList(t *testing.T) {
+	api := newTestAPI(t)
+	bob := api.login(t, "bob")
+	kept := api.post(t, bob)
+	deleted := api.post(t, bob)
+	comm := api.comment(t, bob, kept.ID, "worth keeping")
+	alice := api.login(t, "alice")
+	for _, path := range []string{
+		"/api/post/" + kept.ID + "/save",
+		"/api/post/" + deleted.ID + "/save",
+		"/api/post/" + kept.ID + "/" + comm.ID + "/save",
+	} {
+		api.ok(t, http.MethodPost, path, alice, "", nil)
+	}
+	api.ok(t, http.MethodDelete, "/api/post/"+deleted.ID, bob, "", nil)
+
+	var resp SavedResponse
+	api.ok(t, http.MethodGet, "/api/user/alice/saved", alice, "", &resp)
+	if len(resp.Posts) != 1 || resp.Posts[0].ID != kept.ID || !resp.Posts[0].Saved {
+		t.Errorf("saved posts = %+v, want only %v, saved", resp.Posts, kept.ID)
+	}
+	if len(resp.Comments) != 1 || resp.Comments[0].Comment.ID != comm.ID {
+		t.Errorf("saved comments = %+v, want %v", resp.Comments, comm.ID)
+	}
+
+	rec := api.do(http.MethodGet, "/api/user/alice/saved", bob, "")
+	assertError(t, rec, http.StatusForbidden, ErrNotOwner.Error())
+	rec = api.do(http.MethodGet, "/api/user/alice/saved", "", "")
+	assertError(t, rec, http.StatusUnauthorized, "")
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"go.uber.org/zap"
+	"myredditclone/pkg/oidc"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/twofactor"
+	"myredditclone/pkg/user"
+)
+
+const ssoClientID = "redditclone"
+
+// stubProvider is an OpenID provider issuing an ID token of the subject
+// for any code
+type stubProvider struct {
+	*httptest.Server
+	key     *rsa.PrivateKey
+	subject string
+	nonce   string
+	mu      sync.Mutex
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newStubProvider(t *testing.T, subject string) *stubProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &stubProvider{key: key, subject: subject}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   b64(key.N.Bytes()),
+				"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     p.idToken(t),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *stubProvider) idToken(t *testing.T) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	now := time.Now()
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":                p.URL,
+		"sub":                p.subject,
+		"aud":                ssoClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"nonce":              p.nonce,
+		"preferred_username": "alice",
+	})
+	signed := b64(header) + "." + b64(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Error(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func newSSOHandler(p *stubProvider) *UserHandler {
+	users := user.NewUserRepository()
+	return &UserHandler{
+		Logger:    zap.NewNop().Sugar(),
+		Sessions:  session.NewSessionManager(),
+		UserRepo:  users,
+		TwoFactor: twofactor.NewService(twofactor.NewTwoFactorMemoryRepository(), "test"),
+		Linker:    oidc.NewLinker(users),
+		SSO: oidc.NewRelyingParty(oidc.Config{
+			Issuer:       p.URL,
+			ClientID:     ssoClientID,
+			ClientSecret: "secret",
+			RedirectURL:  "http://localhost/api/login/sso/callback",
+		}),
+	}
+}
+
+// ssoLogin goes through the redirect to the provider and back
+func ssoLogin(t *testing.T, u *UserHandler, p *stubProvider) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	u.SSOLogin(rec, httptest.NewRequest(http.MethodGet, "/api/login/sso", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("SSO login: status %v: %s", rec.Code, rec.Body)
+	}
+	authURL, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL.String(), p.URL+"/authorize") {
+		t.Fatalf("redirected to %v", authURL)
+	}
+	query := authURL.Query()
+	p.mu.Lock()
+	p.nonce = query.Get("nonce")
+	p.mu.Unlock()
+
+	callback := "/api/login/sso/callback?" + url.Values{"state": {query.Get("state")}, "code": {"code"}}.Encode()
+	rec = httptest.NewRecorder()
+	u.SSOCallback(rec, httptest.NewRequest(http.MethodGet, callback, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SSO callback: status %v: %s", rec.Code, rec.Body)
+	}
+	resp := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("SSO callback body %q: %v", rec.Body, err)
+	}
+	return resp
+}
+
+func TestSSOCallbackLogsIn(t *testing.T) {
+	p := newStubProvider(t, "sub-1")
+	u := newSSOHandler(p)
+	resp := ssoLogin(t, u, p)
+	if resp["token"] == nil {
+		t.Fatalf("no token in %v", resp)
+	}
+	if _, err := u.UserRepo.GetByIdentity(context.Background(), p.URL, "sub-1"); err != nil {
+		t.Errorf("the account wasn't provisioned: %v", err)
+	}
+}
+
+func TestSSOCallbackRequiresTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	p := newStubProvider(t, "sub-1")
+	u := newSSOHandler(p)
+	if _, err := u.UserRepo.Register(ctx, "alice", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.UserRepo.LinkIdentity(ctx, "alice", p.URL, "sub-1"); err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := u.TwoFactor.Enroll("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, err := u.TwoFactor.Confirm("alice", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := ssoLogin(t, u, p)
+	if resp["token"] != nil {
+		t.Fatal("SSO login skipped two-factor authentication")
+	}
+	challenge, _ := resp["challenge"].(string)
+	if resp["twoFactorRequired"] != true || challenge == "" {
+		t.Fatalf("no challenge in %v", resp)
+	}
+
+	body, _ := json.Marshal(map[string]string{"challenge": challenge, "code": recovery[0]})
+	rec := httptest.NewRecorder()
+	u.LoginTwoFactor(rec, httptest.NewRequest(http.MethodPost, "/api/login/2fa", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"token"`) {
+		t.Errorf("second step: status %v: %s", rec.Code, rec.Body)
+	}
+}
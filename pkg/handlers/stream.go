@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/stream"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var ErrNoReadScope = apperrors.New(apperrors.ErrForbidden, "The token has no read scope")
+
+type StreamHandler struct {
+	Broker    *stream.Broker
+	PostsRepo posts.PostRepo
+	Sessions  *session.SessionsManager
+	Heartbeat time.Duration
+	Logger    *zap.SugaredLogger
+}
+
+func writeEvent(w http.ResponseWriter, event stream.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
+
+// serve sends the events of the topic to the client until it disconnects or
+// falls too far behind
+func (sh *StreamHandler) serve(w http.ResponseWriter, r *http.Request, topic string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	var lastEventID uint64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, "Bad Last-Event-ID")
+			return
+		}
+		lastEventID = id
+	}
+	sub, missed := sh.Broker.Subscribe(topic, lastEventID)
+	defer sh.Broker.Unsubscribe(sub)
+
+	// the stream outlives the write timeout of the server
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		sh.Logger.Errorf("Clear write deadline of stream %v error: %v", topic, err)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	_, err = fmt.Fprintf(w, "retry: %d\n\n", sh.Heartbeat.Milliseconds())
+	if err != nil {
+		return
+	}
+	for _, event := range missed {
+		if err = writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	sh.Logger.Infof("Open stream %v, replayed %v events", topic, len(missed))
+
+	heartbeat := time.NewTicker(sh.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			sh.Logger.Infof("Close stream %v", topic)
+			return
+		case <-sh.Broker.Done():
+			// the client reconnects to another instance with Last-Event-ID
+			sh.Logger.Infof("Close stream %v on shutdown", topic)
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				sh.Logger.Infof("Drop slow client of stream %v", topic)
+				return
+			}
+			if err = writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err = fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (sh *StreamHandler) PostStream(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	postID, ok := vars[ParamPostID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't POST_ID")
+		return
+	}
+	_, err := sh.PostsRepo.GetByID(r.Context(), postID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	sh.serve(w, r, stream.PostTopic(postID))
+}
+
+func (sh *StreamHandler) PostsStream(w http.ResponseWriter, r *http.Request) {
+	sh.serve(w, r, stream.PostsTopic)
+}
+
+func (sh *StreamHandler) NotificationStream(w http.ResponseWriter, r *http.Request) {
+	sess, err := authorize(sh.Sessions, r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if !sess.HasScope(session.ScopeRead) {
+		WriteError(w, ErrNoReadScope)
+		return
+	}
+	sh.serve(w, r, stream.UserTopic(sess.Login))
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/stream"
+)
+
+// openStream makes the stream request the way EventSource does, with the
+// token in the URL, and returns what was sent before the client went away
+func (api *testAPI) openStream(path, token, lastEventID string) *httptest.ResponseRecorder {
+	if token != "" {
+		path += "?token=" + token
+	}
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req.WithContext(ctx))
+	return rec
+}
+
+func TestNotificationStreamTokenParameter(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.login(t, "alice")
+	if err := api.Broker.Publish(stream.UserTopic("alice"), stream.EventNotification, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Broker.Publish(stream.UserTopic("alice"), stream.EventNotification, "second"); err != nil {
+		t.Fatal(err)
+	}
+	_, pat, err := api.Tokens.Create("alice", "ci", []string{session.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, voter, err := api.Tokens.Create("alice", "bot", []string{session.ScopeVote})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{alice, pat} {
+		rec := api.openStream("/api/notifications/stream", token, "1")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `data: "second"`) {
+			t.Errorf("stream: %v %q, want the second notification", rec.Code, rec.Body.String())
+		}
+	}
+	rec := api.openStream("/api/notifications/stream", "", "1")
+	assertError(t, rec, http.StatusUnauthorized, "")
+	rec = api.openStream("/api/notifications/stream", "garbage", "1")
+	assertError(t, rec, http.StatusUnauthorized, "")
+	rec = api.openStream("/api/notifications/stream", voter, "1")
+	assertError(t, rec, http.StatusForbidden, ErrNoReadScope.Error())
+}
+
+func TestStreamResetsAfterLostEvents(t *testing.T) {
+	api := newTestAPI(t)
+	for i := 0; i < stream.DefaultHistorySize+2; i++ {
+		if err := api.Broker.Publish(stream.PostsTopic, stream.EventPostCreated, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := api.openStream("/api/posts/stream", "", "1")
+	body := rec.Body.String()
+	if !strings.Contains(body, "id: 130\nevent: reset\n") || strings.Contains(body, "event: "+stream.EventPostCreated) {
+		t.Errorf("stream from the trimmed event = %q, want the reset only", body)
+	}
+	rec = api.openStream("/api/posts/stream", "", "2")
+	body = rec.Body.String()
+	if strings.Contains(body, "event: reset") || strings.Count(body, "event: "+stream.EventPostCreated) != stream.DefaultHistorySize {
+		t.Errorf("stream from the first kept event = %q, want the whole history", body)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"myredditclone/pkg/tokens"
+	"net/http"
+)
+
+type TokenHandler struct {
+	Authenticator *tokens.Authenticator
+	TokensRepo    tokens.TokenRepo
+	Logger        *zap.SugaredLogger
+}
+
+// Add sends the token only once, it can't be shown again
+func (th *TokenHandler) Add(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	td := &struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}{}
+	if !readJSON(w, r, td) {
+		return
+	}
+	item, token, err := th.Authenticator.Create(sess.Login, td.Name, td.Scopes)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	MarshalAndWrite(w, map[string]interface{}{"token": token, "info": item})
+	th.Logger.Infof("Created access token with ID: %v for user with ID: %v", item.ID, sess.UserID)
+}
+
+func (th *TokenHandler) List(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	items, err := th.TokensRepo.GetByUser(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, items)
+}
+
+func (th *TokenHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	tokenID, ok := mux.Vars(r)[ParamTokenID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't TOKEN_ID")
+		return
+	}
+	err := th.TokensRepo.Delete(sess.Login, tokenID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"message": "success"})
+	th.Logger.Infof("Revoked access token with ID: %v of user with ID: %v", tokenID, sess.UserID)
+}
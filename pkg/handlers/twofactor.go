@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"go.uber.org/zap"
+	"myredditclone/pkg/twofactor"
+	"net/http"
+)
+
+type TwoFactorHandler struct {
+	Service *twofactor.Service
+	Logger  *zap.SugaredLogger
+}
+
+type codeData struct {
+	Code string `json:"code"`
+}
+
+// Enroll starts the setup, the secret is used only after Confirm
+func (th *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	secret, uri, err := th.Service.Enroll(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"secret": secret, "uri": uri})
+	th.Logger.Infof("Started two-factor enrollment for user with ID: %v", sess.UserID)
+}
+
+func (th *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	cd := &codeData{}
+	if !readJSON(w, r, cd) {
+		return
+	}
+	codes, err := th.Service.Confirm(sess.Login, cd.Code)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"enabled": true, "recoveryCodes": codes})
+	th.Logger.Infof("Enabled two-factor authentication for user with ID: %v", sess.UserID)
+}
+
+func (th *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	cd := &codeData{}
+	if !readJSON(w, r, cd) {
+		return
+	}
+	err := th.Service.Disable(sess.Login, cd.Code)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"enabled": false})
+	th.Logger.Infof("Disabled two-factor authentication for user with ID: %v", sess.UserID)
+}
+
+func (th *TwoFactorHandler) RecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	sess, ok := ownerSession(w, r)
+	if !ok {
+		return
+	}
+	cd := &codeData{}
+	if !readJSON(w, r, cd) {
+		return
+	}
+	codes, err := th.Service.RegenerateRecoveryCodes(sess.Login, cd.Code)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"recoveryCodes": codes})
+	th.Logger.Infof("Regenerated recovery codes for user with ID: %v", sess.UserID)
+}
@@ -1,34 +1,51 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 	"io"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/oidc"
 	"myredditclone/pkg/session"
+	"myredditclone/pkg/twofactor"
 	"myredditclone/pkg/user"
+	"myredditclone/pkg/verify"
 	"net/http"
 )
 
 type UserHandler struct {
-	Logger   *zap.SugaredLogger
-	Sessions *session.SessionsManager
-	UserRepo user.UserRepo
+	Logger    *zap.SugaredLogger
+	Sessions  *session.SessionsManager
+	UserRepo  user.UserRepo
+	Verifier  *verify.Verifier
+	TwoFactor *twofactor.Service
+	SSO       *oidc.RelyingParty //nil when SSO isn't configured
+	Linker    *oidc.Linker
+	Bus       *events.Bus //publishes the logins, if set
 }
 
 type LoginData struct {
 	Username string
 	Password string
+	Email    string //optional, only on registration
 }
 
 func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Content-Type") != "application/json" {
 		jsonError(w, http.StatusBadRequest, "unknown payload")
+		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
 		jsonError(w, http.StatusBadRequest, "cant read request body")
+		return
 	}
 	ld := &LoginData{}
 	err = json.Unmarshal(body, ld)
@@ -38,24 +55,135 @@ func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	usr, err := u.UserRepo.Authorize(ld.Username, ld.Password)
+	usr, err := u.UserRepo.Authorize(r.Context(), ld.Username, ld.Password)
+	if errors.Is(err, user.ErrNoUser) || errors.Is(err, user.ErrBadPass) {
+		err = user.ErrBadCredentials
+	}
+	if err != nil {
+		WriteError(w, apperrors.WithField(err, "username", ld.Username))
+		return
+	}
+	if u.challenge(w, usr) {
+		return
+	}
+	u.logIn(w, usr)
+}
+
+// challenge sends the second login step instead of the token to the users
+// with two-factor authentication, it reports whether it did
+func (u *UserHandler) challenge(w http.ResponseWriter, usr user.User) bool {
+	if !u.TwoFactor.Enabled(usr.Login) {
+		return false
+	}
+	challenge, err := u.TwoFactor.StartChallenge(usr.Login)
+	if err != nil {
+		WriteError(w, err)
+		return true
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{
+		"twoFactorRequired": true,
+		"challenge":         challenge,
+	})
+	u.Logger.Infof("Sent two-factor challenge for user with ID: %v", usr.ID)
+	return true
+}
+
+// LoginTwoFactor is the second login step for the users with two-factor authentication
+func (u *UserHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	cd := &struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}{}
+	if !readJSON(w, r, cd) {
+		return
+	}
+	login, err := u.TwoFactor.CompleteChallenge(cd.Challenge, cd.Code)
 	if err != nil {
-		if err != nil { // формируем ошибку при регистрации
-			authErrResp(w, "username", ld.Username, err)
+		WriteError(w, err)
+		return
+	}
+	usr, err := u.UserRepo.GetByLogin(r.Context(), login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	u.logIn(w, usr)
+}
+
+// SSOLogin sends the user to log in at the identity provider
+func (u *UserHandler) SSOLogin(w http.ResponseWriter, r *http.Request) {
+	if u.SSO == nil {
+		jsonError(w, http.StatusNotFound, "SSO isn't configured")
+		return
+	}
+	authURL, err := u.SSO.AuthURL(r.Context())
+	if err != nil {
+		u.Logger.Errorf("Failed to start SSO login: %v", err)
+		jsonError(w, http.StatusBadGateway, "identity provider is unavailable")
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// SSOCallback logs in the user the provider vouched for, creating
+// or linking the account on the first login. The users with two-factor
+// authentication get the challenge like on the login with the password
+func (u *UserHandler) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	if u.SSO == nil {
+		jsonError(w, http.StatusNotFound, "SSO isn't configured")
+		return
+	}
+	if providerErr := r.FormValue("error"); providerErr != "" {
+		WriteError(w, apperrors.New(apperrors.ErrUnauthenticated, "identity provider refused: "+providerErr))
+		return
+	}
+	claims, err := u.SSO.Exchange(r.Context(), r.FormValue("state"), r.FormValue("code"))
+	if err != nil {
+		if apperrors.Kind(err) == nil {
+			u.Logger.Errorf("Failed to finish SSO login: %v", err)
+			jsonError(w, http.StatusBadGateway, "identity provider is unavailable")
 			return
 		}
+		WriteError(w, err)
+		return
 	}
-
-	sess, err := u.Sessions.Create(w, usr.ID, usr.Login)
+	usr, err := u.Linker.Resolve(r.Context(), claims)
 	if err != nil {
-		http.Error(w, `Session isn't create`+err.Error(), http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
+	u.Logger.Infof("SSO login of user with ID: %v as %v at %v", usr.ID, claims.Subject, claims.Issuer)
+	if u.challenge(w, usr) {
+		return
+	}
+	u.logIn(w, usr)
+}
+
+// logIn creates the session and sends its token
+// logIn is where every way to log in ends: with the password, the second
+// factor or SSO
+func (u *UserHandler) logIn(w http.ResponseWriter, usr user.User) {
+	if !u.startSession(w, usr) {
+		return
+	}
+	if u.Bus != nil {
+		u.Bus.Publish(events.LoggedIn{User: usr})
+	}
+}
+
+// startSession sends the token of a new session, it reports whether it did
+func (u *UserHandler) startSession(w http.ResponseWriter, usr user.User) bool {
+	sess, err := u.Sessions.Create(w, usr.ID, usr.Login, usr.Roles...)
+	if err != nil {
+		WriteError(w, err)
+		return false
+	}
 	u.Logger.Infof("Successfully created session for user with ID %v", sess.UserID)
-	token, err := session.CreateNewToken(usr)
+	token, err := session.CreateNewToken(usr, sess.ID)
 	if err != nil {
-		jsonError(w, http.StatusInternalServerError, err.Error())
-		return
+		WriteError(w, err)
+		return false
 	}
 	resp, err := json.Marshal(map[string]interface{}{
 		"token":       token,
@@ -63,17 +191,20 @@ func (u *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 	CheckMarshalError(w, err, resp)
 	u.Logger.Infof("Send token on client for user with id: %v ", sess.UserID)
+	return true
 }
 
 func (u *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Content-Type") != "application/json" {
 		jsonError(w, http.StatusBadRequest, "unknown payload")
+		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
 		jsonError(w, http.StatusBadRequest, "cant read request body")
+		return
 	}
 	ld := &LoginData{}
 	err = json.Unmarshal(body, ld)
@@ -83,39 +214,43 @@ func (u *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	usr, err := u.UserRepo.Register(ld.Username, ld.Password)
-	if err != nil {
-		if err != nil { // формируем ошибку при регистрации
-			authErrResp(w, "username", ld.Username, err)
+	if ld.Email != "" {
+		err = u.checkEmail(r.Context(), ld.Email)
+		if err != nil {
+			WriteError(w, err)
 			return
 		}
 	}
-
-	sess, err := u.Sessions.Create(w, usr.ID, usr.Login)
+	usr, err := u.UserRepo.Register(r.Context(), ld.Username, ld.Password)
 	if err != nil {
-		http.Error(w, `Session isn't create`+err.Error(), http.StatusInternalServerError)
+		WriteError(w, apperrors.WithField(err, "username", ld.Username))
 		return
 	}
-	u.Logger.Infof("Successfully created session for user with ID %v", sess.UserID)
-	token, err := session.CreateNewToken(usr)
-	if err != nil {
-		jsonError(w, http.StatusInternalServerError, err.Error())
-		return
+	if ld.Email != "" {
+		err = u.UserRepo.SetEmail(r.Context(), usr.Login, ld.Email)
+		if err != nil {
+			WriteError(w, apperrors.WithField(err, "email", ld.Email))
+			return
+		}
+		usr.Email = ld.Email
+		err = u.Verifier.Send(usr)
+		if err != nil {
+			u.Logger.Errorf("Failed to send verification email to user with ID: %v: %v", usr.ID, err)
+		}
 	}
-	resp, err := json.Marshal(map[string]interface{}{
-		"token":       token,
-		"Status code": http.StatusFound,
-	})
-	CheckMarshalError(w, err, resp)
-	u.Logger.Infof("Send token on client for user with id: %v ", sess.UserID)
+	u.startSession(w, usr)
 }
 
-func jsonError(w http.ResponseWriter, status int, msg string) {
-	resp, err := json.Marshal(map[string]interface{}{
-		"status": status,
-		"error":  msg,
-	})
-	CheckMarshalError(w, err, resp)
+// checkEmail validates the email and checks it isn't used by anybody
+func (u *UserHandler) checkEmail(ctx context.Context, email string) error {
+	if !govalidator.IsEmail(email) {
+		return apperrors.Validation("email", email, "email is not valid")
+	}
+	_, err := u.UserRepo.GetByEmail(ctx, email)
+	if err == nil {
+		return apperrors.WithField(user.ErrExistMail, "email", email)
+	}
+	return nil
 }
 
 func CheckMarshalError(w http.ResponseWriter, err error, resp []byte) {
@@ -130,22 +265,39 @@ func CheckMarshalError(w http.ResponseWriter, err error, resp []byte) {
 	}
 }
 
-func authErrResp(w http.ResponseWriter, param string, value string, err error) {
-	var (
-		resp  []byte
-		error error
-	)
-	w.WriteHeader(http.StatusUnprocessableEntity)
-	errors := make([]map[string]string, 0)
-	errors = append(errors, map[string]string{
-		"location": "body",
-		"param":    param,
-		"value":    value,
-		"msg":      err.Error(),
-	})
-	resp, error = json.Marshal(map[string][]map[string]string{
-		"errors": errors,
-	})
-
-	CheckMarshalError(w, error, resp)
+func (u *UserHandler) SetRoles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userLogin, ok := vars[ParamUserLogin]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't USER_LOGIN")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "cant read request body")
+		return
+	}
+	rd := &struct {
+		Roles []string `json:"roles"`
+	}{}
+	err = json.Unmarshal(body, rd)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "cant unpack payload")
+		return
+	}
+	for _, role := range rd.Roles {
+		if role != user.RoleModerator && role != user.RoleAdmin {
+			WriteError(w, apperrors.Validation("roles", role, "unknown role"))
+			return
+		}
+	}
+	err = u.UserRepo.SetRoles(r.Context(), userLogin, rd.Roles...)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]interface{}{"username": userLogin, "roles": rd.Roles})
+	u.Logger.Infof("Set roles %v for user with Login: %v", rd.Roles, userLogin)
 }
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/metrics"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/twofactor"
+	"myredditclone/pkg/user"
+)
+
+// The login must not tell an unknown login from a wrong password
+func TestLoginBadCredentials(t *testing.T) {
+	users := user.NewUserRepository()
+	if _, err := users.Register(context.Background(), "alice", "password"); err != nil {
+		t.Fatal(err)
+	}
+	u := &UserHandler{
+		Logger:    zap.NewNop().Sugar(),
+		Sessions:  session.NewSessionManager(),
+		UserRepo:  users,
+		TwoFactor: twofactor.NewService(twofactor.NewTwoFactorMemoryRepository(), "test"),
+	}
+	login := func(username, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/login",
+			strings.NewReader(`{"username":"`+username+`","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		u.Login(rec, req)
+		return rec
+	}
+
+	unknown := login("mallory", "password")
+	wrong := login("alice", "wrong")
+	for name, rec := range map[string]*httptest.ResponseRecorder{"unknown login": unknown, "wrong password": wrong} {
+		resp := assertError(t, rec, http.StatusUnauthorized, user.ErrBadCredentials.Error())
+		if len(resp.Errors) != 1 || resp.Errors[0].Param != "username" {
+			t.Errorf("%v: errors = %+v, want the username", name, resp.Errors)
+		}
+	}
+	unknownBody := strings.Replace(unknown.Body.String(), "mallory", "alice", 1)
+	if unknownBody != wrong.Body.String() {
+		t.Errorf("responses differ:\n%s\n%s", unknown.Body, wrong.Body)
+	}
+
+	if rec := login("alice", "password"); rec.Code != http.StatusOK {
+		t.Errorf("right password: status %v: %s", rec.Code, rec.Body)
+	}
+}
+
+func postJSON(handler http.HandlerFunc, path string, v interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(v)
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// enrollTwoFactor turns on two-factor authentication and returns the recovery codes
+func enrollTwoFactor(t *testing.T, service *twofactor.Service, login string) []string {
+	t.Helper()
+	secret, _, err := service.Enroll(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery, err := service.Confirm(login, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recovery
+}
+
+// passwordStep logs in with the password expecting the two-factor challenge
+func passwordStep(t *testing.T, u *UserHandler, login string) string {
+	t.Helper()
+	rec := postJSON(u.Login, "/api/login", LoginData{Username: login, Password: "password"})
+	resp := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("password step: status %v: %s", rec.Code, rec.Body)
+	}
+	challenge, _ := resp["challenge"].(string)
+	if challenge == "" {
+		t.Fatalf("password step: no challenge in %s", rec.Body)
+	}
+	return challenge
+}
+
+// LoggedIn is published once the user gets the token, whichever way
+func TestLoggedInEvents(t *testing.T) {
+	p := newStubProvider(t, "sub-1")
+	u := newSSOHandler(p)
+	u.Bus = events.NewBus(zap.NewNop().Sugar())
+	var logins []string
+	u.Bus.Subscribe("test", events.On(func(e events.LoggedIn) error {
+		logins = append(logins, e.User.Login)
+		return nil
+	}))
+	expect := func(step string, want ...string) {
+		t.Helper()
+		if strings.Join(logins, ",") != strings.Join(want, ",") {
+			t.Fatalf("%v: logins = %v, want %v", step, logins, want)
+		}
+	}
+
+	for _, login := range []string{"alice", "bob"} {
+		rec := postJSON(u.Register, "/api/register", LoginData{Username: login, Password: "password"})
+		if rec.Code != http.StatusOK && rec.Code != http.StatusCreated {
+			t.Fatalf("register %v: status %v: %s", login, rec.Code, rec.Body)
+		}
+	}
+	expect("registration")
+
+	if rec := postJSON(u.Login, "/api/login", LoginData{Username: "alice", Password: "wrong"}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %v", rec.Code)
+	}
+	expect("wrong password")
+	if rec := postJSON(u.Login, "/api/login", LoginData{Username: "alice", Password: "password"}); rec.Code != http.StatusOK {
+		t.Fatalf("password login: status %v: %s", rec.Code, rec.Body)
+	}
+	expect("password login", "alice")
+
+	recovery := enrollTwoFactor(t, u.TwoFactor, "bob")
+	challenge := passwordStep(t, u, "bob")
+	expect("password step", "alice")
+	rec := postJSON(u.LoginTwoFactor, "/api/login/2fa", map[string]string{"challenge": challenge, "code": recovery[0]})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("second step: status %v: %s", rec.Code, rec.Body)
+	}
+	expect("second step", "alice", "bob")
+
+	ssoLogin(t, u, p)
+	expect("SSO login", "alice", "bob", "alice1")
+}
+
+// The login counter counts the logins, not the passwords checked
+func TestLoginMetrics(t *testing.T) {
+	p := newStubProvider(t, "sub-1")
+	u := newSSOHandler(p)
+	bus := events.NewBus(zap.NewNop().Sugar())
+	m := metrics.New()
+	bus.Subscribe("metrics", m.Handle)
+	u.Bus = bus
+	u.UserRepo = events.NewUserRepo(u.UserRepo, bus)
+	count := func() (float64, float64) {
+		return testutil.ToFloat64(m.Logins.WithLabelValues(metrics.ResultSuccess)),
+			testutil.ToFloat64(m.Logins.WithLabelValues(metrics.ResultFailure))
+	}
+	expect := func(step string, success, failure float64) {
+		t.Helper()
+		if gotSuccess, gotFailure := count(); gotSuccess != success || gotFailure != failure {
+			t.Fatalf("%v: logins %v, failed %v, want %v, %v", step, gotSuccess, gotFailure, success, failure)
+		}
+	}
+	for _, login := range []string{"alice", "bob"} {
+		if _, err := u.UserRepo.Register(context.Background(), login, "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	postJSON(u.Login, "/api/login", LoginData{Username: "alice", Password: "wrong"})
+	expect("wrong password", 0, 1)
+	postJSON(u.Login, "/api/login", LoginData{Username: "alice", Password: "password"})
+	expect("password login", 1, 1)
+
+	recovery := enrollTwoFactor(t, u.TwoFactor, "bob")
+	challenge := passwordStep(t, u, "bob")
+	expect("password step", 1, 1)
+	postJSON(u.LoginTwoFactor, "/api/login/2fa", map[string]string{"challenge": challenge, "code": recovery[0]})
+	expect("second step", 2, 1)
+
+	ssoLogin(t, u, p)
+	expect("SSO login", 3, 1)
+}
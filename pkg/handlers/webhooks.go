@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"io"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/webhooks"
+	"net/http"
+)
+
+type WebhookHandler struct {
+	WebhooksRepo webhooks.WebhookRepo
+	Logger       *zap.SugaredLogger
+}
+
+var knownWebhookEvents = map[string]struct{}{
+	webhooks.EventPostCreated:    {},
+	webhooks.EventPostDeleted:    {},
+	webhooks.EventCommentAdded:   {},
+	webhooks.EventCommentDeleted: {},
+	webhooks.EventVoted:          {},
+}
+
+func (wh *WebhookHandler) Validate(item webhooks.Webhook) error {
+	if !govalidator.IsURL(item.URL) {
+		return apperrors.Validation("url", item.URL, "URL is not valid")
+	}
+	if err := webhooks.CheckURL(item.URL); err != nil {
+		return apperrors.Validation("url", item.URL, err.Error())
+	}
+	for _, event := range item.Events {
+		if _, ok := knownWebhookEvents[event]; !ok {
+			return apperrors.Validation("events", event, "unknown event")
+		}
+	}
+	return nil
+}
+
+// ownWebhook returns the webhook from the URL if it belongs to the current user
+func (wh *WebhookHandler) ownWebhook(w http.ResponseWriter, r *http.Request) (webhooks.Webhook, *session.Session, bool) {
+	vars := mux.Vars(r)
+	webhookID, ok := vars[ParamWebhookID]
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Request URL hasn't WEBHOOK_ID")
+		return webhooks.Webhook{}, nil, false
+	}
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return webhooks.Webhook{}, nil, false
+	}
+	item, err := wh.WebhooksRepo.GetByID(webhookID)
+	if err == nil && item.Owner != sess.Login {
+		err = webhooks.ErrNoWebhook
+	}
+	if err != nil {
+		WriteError(w, err)
+		return webhooks.Webhook{}, nil, false
+	}
+	return item, sess, true
+}
+
+func (wh *WebhookHandler) Add(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	item := new(webhooks.Webhook)
+	bytes, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Bad request")
+		return
+	}
+	err = json.Unmarshal(bytes, item)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Bad form")
+		return
+	}
+	err = wh.Validate(*item)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	item.Owner = sess.Login
+	if item.Secret == "" {
+		item.Secret, err = webhooks.RandomID()
+		if err != nil {
+			WriteError(w, err)
+			return
+		}
+	}
+	err = wh.WebhooksRepo.Add(item)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, item)
+	wh.Logger.Infof("Add webhook with ID: %v for user with ID: %v", item.ID, sess.UserID)
+}
+
+func (wh *WebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	elems, err := wh.WebhooksRepo.GetByOwner(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	for i := range elems {
+		elems[i].Secret = ""
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, elems)
+}
+
+func (wh *WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	item, sess, ok := wh.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	err := wh.WebhooksRepo.Delete(item.ID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, map[string]string{"message": "success"})
+	wh.Logger.Infof("Delete webhook with ID: %v for user with ID: %v", item.ID, sess.UserID)
+}
+
+func (wh *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	item, _, ok := wh.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	elems, err := wh.WebhooksRepo.GetDeliveries(item.ID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, elems)
+}
+
+func (wh *WebhookHandler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	sess, err := session.SessionFromContext(r.Context())
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	elems, err := wh.WebhooksRepo.GetDeadLetters(sess.Login)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	MarshalAndWrite(w, elems)
+}
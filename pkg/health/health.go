@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Timeout limits every check so a hanging backend can't hang the probe
+const Timeout = 2 * time.Second
+
+// Pinger is a backend or a worker able to tell whether it serves requests
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Check is a named dependency of the server, the ones not implementing
+// Pinger have nothing to fail, like the in-memory repositories
+type Check struct {
+	Name   string
+	Target interface{}
+}
+
+type Result struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Run does the checks concurrently and reports whether all of them passed
+func Run(ctx context.Context, checks []Check) ([]Result, bool) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	results := make([]Result, len(checks))
+	wg := sync.WaitGroup{}
+	for i, check := range checks {
+		results[i] = Result{Name: check.Name, Status: StatusOK}
+		pinger, ok := check.Target.(Pinger)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(res *Result) {
+			defer wg.Done()
+			if err := pinger.Ping(ctx); err != nil {
+				res.Status = StatusFail
+				res.Error = err.Error()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	for _, res := range results {
+		if res.Status != StatusOK {
+			return results, false
+		}
+	}
+	return results, true
+}
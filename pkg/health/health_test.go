@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type pinger func(ctx context.Context) error
+
+func (p pinger) Ping(ctx context.Context) error {
+	return p(ctx)
+}
+
+func TestRun(t *testing.T) {
+	ok := pinger(func(ctx context.Context) error { return nil })
+	down := pinger(func(ctx context.Context) error { return errors.New("connection refused") })
+	hanging := pinger(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	results, passed := Run(context.Background(), []Check{
+		{Name: "memory", Target: struct{}{}},
+		{Name: "db", Target: ok},
+	})
+	if !passed || len(results) != 2 || results[0].Status != StatusOK || results[1].Status != StatusOK {
+		t.Errorf("results = %+v, passed = %v, want all ok", results, passed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	results, passed = Run(ctx, []Check{
+		{Name: "db", Target: ok},
+		{Name: "smtp", Target: down},
+		{Name: "broker", Target: hanging},
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("checks took %v, want the hanging one cut at the deadline", elapsed)
+	}
+	if passed {
+		t.Error("failing checks passed")
+	}
+	want := []Result{
+		{Name: "db", Status: StatusOK},
+		{Name: "smtp", Status: StatusFail, Error: "connection refused"},
+		{Name: "broker", Status: StatusFail, Error: context.DeadlineExceeded.Error()},
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %v = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package hidden
+
+type Item struct {
+	PostID string `json:"postId"`
+	Hidden string `json:"hidden"`
+}
+
+type HiddenRepo interface {
+	Hide(login, postID string) error
+	Unhide(login, postID string) error
+	IsHidden(login, postID string) (bool, error)
+	GetByUser(login string) ([]Item, error)
+	DeleteByUser(login string) error
+}
@@ -0,0 +1,82 @@
+package hidden
+
+import (
+	"myredditclone/pkg/apperrors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotHidden = apperrors.New(apperrors.ErrNotFound, "Current post isn't hidden")
+)
+
+var _ HiddenRepo = NewHiddenMemoryRepository()
+
+type HiddenMemoryRepository struct {
+	data map[string]map[string]Item
+	mu   sync.RWMutex
+}
+
+func NewHiddenMemoryRepository() *HiddenMemoryRepository {
+	return &HiddenMemoryRepository{
+		data: map[string]map[string]Item{},
+	}
+}
+
+// Hide is idempotent, hiding the post twice keeps the first date
+func (repo *HiddenMemoryRepository) Hide(login, postID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	items, ok := repo.data[login]
+	if !ok {
+		items = map[string]Item{}
+		repo.data[login] = items
+	}
+	if _, ok = items[postID]; ok {
+		return nil
+	}
+	items[postID] = Item{
+		PostID: postID,
+		Hidden: time.Now().Format("2006-01-02T15:04:05.000"),
+	}
+	return nil
+}
+
+func (repo *HiddenMemoryRepository) Unhide(login, postID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, ok := repo.data[login][postID]; !ok {
+		return ErrNotHidden
+	}
+	delete(repo.data[login], postID)
+	return nil
+}
+
+func (repo *HiddenMemoryRepository) IsHidden(login, postID string) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	_, ok := repo.data[login][postID]
+	return ok, nil
+}
+
+// GetByUser returns hidden posts, the last hidden first
+func (repo *HiddenMemoryRepository) GetByUser(login string) ([]Item, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	res := make([]Item, 0, len(repo.data[login]))
+	for _, item := range repo.data[login] {
+		res = append(res, item)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Hidden > res[j].Hidden
+	})
+	return res, nil
+}
+
+func (repo *HiddenMemoryRepository) DeleteByUser(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.data, login)
+	return nil
+}
@@ -0,0 +1,52 @@
+package hidden
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHide(t *testing.T) {
+	repo := NewHiddenMemoryRepository()
+	for _, postID := range []string{"1", "2"} {
+		if err := repo.Hide("alice", postID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, err := repo.GetByUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("hidden = %+v, want two posts", items)
+	}
+	// hiding twice keeps the first date
+	first := repo.data["alice"]["1"]
+	if err = repo.Hide("alice", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if again := repo.data["alice"]["1"]; again != first {
+		t.Errorf("hidden = %+v after hiding again, want %+v kept", again, first)
+	}
+
+	for login, want := range map[string]bool{"alice": true, "bob": false} {
+		if got, _ := repo.IsHidden(login, "1"); got != want {
+			t.Errorf("post hidden for %v = %v, want %v", login, got, want)
+		}
+	}
+	if err = repo.Unhide("alice", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.IsHidden("alice", "1"); got {
+		t.Error("unhidden post is hidden")
+	}
+	if err = repo.Unhide("alice", "1"); !errors.Is(err, ErrNotHidden) {
+		t.Errorf("unhide twice: error = %v, want ErrNotHidden", err)
+	}
+
+	if err = repo.DeleteByUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ = repo.GetByUser("alice"); len(items) != 0 {
+		t.Errorf("hidden = %+v after the user is deleted, want none", items)
+	}
+}
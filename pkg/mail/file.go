@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var _ Sender = &FileSender{}
+
+// FileSender writes the messages to a file instead of sending them,
+// so the flows with emails can be checked offline
+type FileSender struct {
+	From string
+	Path string //stdout when empty
+	mu   sync.Mutex
+}
+
+func NewFileSender(from, path string) *FileSender {
+	return &FileSender{
+		From: from,
+		Path: path,
+	}
+}
+
+func (s *FileSender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var w io.Writer = os.Stdout
+	if s.Path != "" {
+		f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	_, err := io.WriteString(w, "Date: "+time.Now().Format(time.RFC1123Z)+"\r\n")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(format(s.From, msg), "\r\n\r\n"...))
+	return err
+}
@@ -0,0 +1,11 @@
+package mail
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(msg Message) error
+}
@@ -0,0 +1,78 @@
+package mail
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"io"
+	"sync"
+)
+
+var (
+	ErrQueueFull   = errors.New("mail queue is full")
+	ErrQueueClosed = errors.New("mail queue is closed")
+)
+
+var _ Sender = &Queue{}
+
+// Queue sends the messages in the background, so the requests neither wait
+// for the mail server nor tell by their time whether an email was sent
+type Queue struct {
+	sender   Sender
+	messages chan Message
+	done     chan struct{}
+	closed   bool
+	mu       sync.RWMutex
+	Logger   *zap.SugaredLogger
+}
+
+func NewQueue(sender Sender, size int, logger *zap.SugaredLogger) *Queue {
+	q := &Queue{
+		sender:   sender,
+		messages: make(chan Message, size),
+		done:     make(chan struct{}),
+		Logger:   logger,
+	}
+	go q.run()
+	return q
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for msg := range q.messages {
+		if err := q.sender.Send(msg); err != nil {
+			q.Logger.Errorf("Failed to send email %q: %v", msg.Subject, err)
+		}
+	}
+}
+
+// Send only queues the message, the errors of sending are logged
+func (q *Queue) Send(msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close sends the queued messages and closes the sender, if it's a closer
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.messages)
+	q.mu.Unlock()
+	<-q.done
+	if closer, ok := q.sender.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// gate holds the messages until it's opened
+type gate struct {
+	taken  chan struct{}
+	open   chan struct{}
+	sent   []Message
+	closed bool
+	mu     sync.Mutex
+}
+
+func (g *gate) Send(msg Message) error {
+	g.taken <- struct{}{}
+	<-g.open
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sent = append(g.sent, msg)
+	if msg.To == "" {
+		return errors.New("no recipient")
+	}
+	return nil
+}
+
+func (g *gate) Close() error {
+	g.closed = true
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	g := &gate{taken: make(chan struct{}, 3), open: make(chan struct{})}
+	q := NewQueue(g, 2, zap.NewNop().Sugar())
+	// the worker is stuck with the first message, the next two fill the queue
+	if err := q.Send(Message{To: "alice@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	<-g.taken
+	for _, to := range []string{"", "bob@example.com"} {
+		if err := q.Send(Message{To: to}); err != nil {
+			t.Fatalf("send to %q: %v", to, err)
+		}
+	}
+	if err := q.Send(Message{To: "carol@example.com"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("send to the full queue: error = %v, want ErrQueueFull", err)
+	}
+
+	// the failed message doesn't stop the rest
+	close(g.open)
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.sent) != 3 {
+		t.Fatalf("sent %v messages, want 3", len(g.sent))
+	}
+	for i, to := range []string{"alice@example.com", "", "bob@example.com"} {
+		if g.sent[i].To != to {
+			t.Errorf("message %v to %q, want %q", i, g.sent[i].To, to)
+		}
+	}
+	if !g.closed {
+		t.Error("the sender isn't closed")
+	}
+	if err := q.Send(Message{To: "alice@example.com"}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("send after close: error = %v, want ErrQueueClosed", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+}
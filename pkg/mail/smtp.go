@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+var _ Sender = &SMTPSender{}
+
+type SMTPSender struct {
+	Addr string //host:port
+	From string
+	Auth smtp.Auth
+}
+
+// NewSMTPSender uses PLAIN auth when the username is set
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	sender := &SMTPSender{
+		Addr: addr,
+		From: from,
+	}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		sender.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return sender
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{msg.To}, format(s.From, msg))
+}
+
+func format(from string, msg Message) []byte {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "From: %s\r\n", from)
+	fmt.Fprintf(b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"myredditclone/pkg/events"
+)
+
+const (
+	VoteUp   = "up"
+	VoteDown = "down"
+	VoteNone = "unvote"
+)
+
+// Handle counts the domain events, it's a synchronous subscriber of the bus
+func (m *Metrics) Handle(event events.Event) error {
+	switch e := event.(type) {
+	case events.PostCreated:
+		m.PostsCreated.Inc()
+	case events.CommentAdded:
+		m.Comments.Inc()
+	case events.Voted:
+		direction := VoteNone
+		if e.Vote > 0 {
+			direction = VoteUp
+		} else if e.Vote < 0 {
+			direction = VoteDown
+		}
+		m.Votes.WithLabelValues(direction).Inc()
+	case events.LoggedIn:
+		m.Logins.WithLabelValues(ResultSuccess).Inc()
+	case events.LoginFailed:
+		m.Logins.WithLabelValues(ResultFailure).Inc()
+	}
+	return nil
+}
+
+// Dropped counts the events an asynchronous subscriber of the bus lost
+func (m *Metrics) Dropped(subscriber string, event events.Event) {
+	m.EventsDropped.WithLabelValues(subscriber).Inc()
+}
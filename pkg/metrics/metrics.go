@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+const namespace = "redditclone"
+
+// RouteUnmatched labels the requests no route took, like the SPA fallback,
+// so a scan of random paths can't blow up the number of series
+const RouteUnmatched = "unmatched"
+
+type Metrics struct {
+	Registry        *prometheus.Registry
+	Requests        *prometheus.CounterVec
+	RequestDuration *prometheus.HistogramVec
+	PostsCreated    prometheus.Counter
+	Comments        prometheus.Counter
+	Votes           *prometheus.CounterVec
+	Logins          *prometheus.CounterVec
+	EventsDropped   *prometheus.CounterVec
+	RepoDuration    *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "code"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latencies by route template, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		PostsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "posts_created_total",
+			Help:      "Posts created.",
+		}),
+		Comments: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "comments_total",
+			Help:      "Comments added.",
+		}),
+		Votes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "votes_total",
+			Help:      "Votes by direction: up, down or unvote.",
+		}, []string{"direction"}),
+		Logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Password checks on login by result: success or failure.",
+		}, []string{"result"}),
+		EventsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_dropped_total",
+			Help:      "Domain events dropped because the queue of an asynchronous subscriber was full.",
+		}, []string{"subscriber"}),
+		RepoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_operation_duration_seconds",
+			Help:      "Repository operation latencies by repository, operation and result.",
+			Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+		}, []string{"repository", "operation", "result"}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.Requests,
+		m.RequestDuration,
+		m.PostsCreated,
+		m.Comments,
+		m.Votes,
+		m.Logins,
+		m.EventsDropped,
+		m.RepoDuration,
+	)
+	// the series exist from the start, so rate() sees the first increment
+	for _, direction := range []string{VoteUp, VoteDown, VoteNone} {
+		m.Votes.WithLabelValues(direction)
+	}
+	m.Logins.WithLabelValues(ResultSuccess)
+	m.Logins.WithLabelValues(ResultFailure)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// ObserveRepo records the latency of the operation started at start
+func (m *Metrics) ObserveRepo(repo, op string, start time.Time, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultFailure
+	}
+	m.RepoDuration.WithLabelValues(repo, op, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,202 @@
+package metrics
+
+import (
+	"context"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+	"time"
+)
+
+var (
+	_ posts.PostRepo = &PostRepo{}
+	_ user.UserRepo  = &UserRepo{}
+)
+
+// PostRepo records the latency of every operation of the wrapped repository
+type PostRepo struct {
+	Repo    posts.PostRepo
+	Metrics *Metrics
+}
+
+func NewPostRepo(repo posts.PostRepo, m *Metrics) *PostRepo {
+	return &PostRepo{
+		Repo:    repo,
+		Metrics: m,
+	}
+}
+
+func (repo *PostRepo) observe(op string, start time.Time, err error) {
+	repo.Metrics.ObserveRepo("posts", op, start, err)
+}
+
+func (repo *PostRepo) GetAll(ctx context.Context) ([]posts.Post, error) {
+	start := time.Now()
+	elems, err := repo.Repo.GetAll(ctx)
+	repo.observe("GetAll", start, err)
+	return elems, err
+}
+
+func (repo *PostRepo) GetByID(ctx context.Context, id string) (posts.Post, error) {
+	start := time.Now()
+	post, err := repo.Repo.GetByID(ctx, id)
+	repo.observe("GetByID", start, err)
+	return post, err
+}
+
+func (repo *PostRepo) Add(ctx context.Context, item *posts.Post) (uint64, error) {
+	start := time.Now()
+	lastID, err := repo.Repo.Add(ctx, item)
+	repo.observe("Add", start, err)
+	return lastID, err
+}
+
+func (repo *PostRepo) AddComment(ctx context.Context, postID, parentID, newCom string, sess session.Session) (posts.Post, error) {
+	start := time.Now()
+	post, err := repo.Repo.AddComment(ctx, postID, parentID, newCom, sess)
+	repo.observe("AddComment", start, err)
+	return post, err
+}
+
+func (repo *PostRepo) DeleteComment(ctx context.Context, postID, commID string, sess session.Session) (posts.Post, error) {
+	start := time.Now()
+	post, err := repo.Repo.DeleteComment(ctx, postID, commID, sess)
+	repo.observe("DeleteComment", start, err)
+	return post, err
+}
+
+func (repo *PostRepo) Vote(ctx context.Context, postID, userID string, newVote int8) (posts.Post, error) {
+	start := time.Now()
+	post, err := repo.Repo.Vote(ctx, postID, userID, newVote)
+	repo.observe("Vote", start, err)
+	return post, err
+}
+
+func (repo *PostRepo) Update(ctx context.Context, newItem posts.Post) error {
+	start := time.Now()
+	err := repo.Repo.Update(ctx, newItem)
+	repo.observe("Update", start, err)
+	return err
+}
+
+func (repo *PostRepo) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := repo.Repo.Delete(ctx, id)
+	repo.observe("Delete", start, err)
+	return err
+}
+
+func (repo *PostRepo) AnonymizeAuthor(ctx context.Context, login string) error {
+	start := time.Now()
+	err := repo.Repo.AnonymizeAuthor(ctx, login)
+	repo.observe("AnonymizeAuthor", start, err)
+	return err
+}
+
+// UserRepo records the latency of every operation of the wrapped repository
+type UserRepo struct {
+	Repo    user.UserRepo
+	Metrics *Metrics
+}
+
+func NewUserRepo(repo user.UserRepo, m *Metrics) *UserRepo {
+	return &UserRepo{
+		Repo:    repo,
+		Metrics: m,
+	}
+}
+
+func (repo *UserRepo) observe(op string, start time.Time, err error) {
+	repo.Metrics.ObserveRepo("users", op, start, err)
+}
+
+func (repo *UserRepo) Authorize(ctx context.Context, login, pass string) (user.User, error) {
+	start := time.Now()
+	usr, err := repo.Repo.Authorize(ctx, login, pass)
+	repo.observe("Authorize", start, err)
+	return usr, err
+}
+
+func (repo *UserRepo) Register(ctx context.Context, login, pass string) (user.User, error) {
+	start := time.Now()
+	usr, err := repo.Repo.Register(ctx, login, pass)
+	repo.observe("Register", start, err)
+	return usr, err
+}
+
+func (repo *UserRepo) GetByLogin(ctx context.Context, login string) (user.User, error) {
+	start := time.Now()
+	usr, err := repo.Repo.GetByLogin(ctx, login)
+	repo.observe("GetByLogin", start, err)
+	return usr, err
+}
+
+func (repo *UserRepo) GetByEmail(ctx context.Context, email string) (user.User, error) {
+	start := time.Now()
+	usr, err := repo.Repo.GetByEmail(ctx, email)
+	repo.observe("GetByEmail", start, err)
+	return usr, err
+}
+
+func (repo *UserRepo) GetByIdentity(ctx context.Context, issuer, subject string) (user.User, error) {
+	start := time.Now()
+	usr, err := repo.Repo.GetByIdentity(ctx, issuer, subject)
+	repo.observe("GetByIdentity", start, err)
+	return usr, err
+}
+
+func (repo *UserRepo) LinkIdentity(ctx context.Context, login, issuer, subject string) error {
+	start := time.Now()
+	err := repo.Repo.LinkIdentity(ctx, login, issuer, subject)
+	repo.observe("LinkIdentity", start, err)
+	return err
+}
+
+func (repo *UserRepo) SetEmail(ctx context.Context, login, email string) error {
+	start := time.Now()
+	err := repo.Repo.SetEmail(ctx, login, email)
+	repo.observe("SetEmail", start, err)
+	return err
+}
+
+func (repo *UserRepo) SetEmailVerified(ctx context.Context, login, email string) error {
+	start := time.Now()
+	err := repo.Repo.SetEmailVerified(ctx, login, email)
+	repo.observe("SetEmailVerified", start, err)
+	return err
+}
+
+func (repo *UserRepo) SetRoles(ctx context.Context, login string, roles ...string) error {
+	start := time.Now()
+	err := repo.Repo.SetRoles(ctx, login, roles...)
+	repo.observe("SetRoles", start, err)
+	return err
+}
+
+func (repo *UserRepo) ChangePassword(ctx context.Context, login, oldPass, newPass string) error {
+	start := time.Now()
+	err := repo.Repo.ChangePassword(ctx, login, oldPass, newPass)
+	repo.observe("ChangePassword", start, err)
+	return err
+}
+
+func (repo *UserRepo) ResetPassword(ctx context.Context, login, newPass string) error {
+	start := time.Now()
+	err := repo.Repo.ResetPassword(ctx, login, newPass)
+	repo.observe("ResetPassword", start, err)
+	return err
+}
+
+func (repo *UserRepo) SetPreferences(ctx context.Context, login string, prefs user.Preferences) error {
+	start := time.Now()
+	err := repo.Repo.SetPreferences(ctx, login, prefs)
+	repo.observe("SetPreferences", start, err)
+	return err
+}
+
+func (repo *UserRepo) Delete(ctx context.Context, login, pass string) (user.User, error) {
+	start := time.Now()
+	usr, err := repo.Repo.Delete(ctx, login, pass)
+	repo.observe("Delete", start, err)
+	return usr, err
+}
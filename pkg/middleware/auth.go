@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"encoding/json"
 	"myredditclone/pkg/session"
 	"net/http"
 )
@@ -18,3 +19,75 @@ func Auth(sm *session.SessionsManager) func(next http.Handler) http.Handler {
 		})
 	}
 }
+
+func authError(w http.ResponseWriter, status int, msg string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	resp, err := json.Marshal(map[string]interface{}{
+		"status":  status,
+		"error":   http.StatusText(status),
+		"message": msg,
+	})
+	if err != nil {
+		return
+	}
+	_, _ = w.Write(resp)
+}
+
+// RequireAuth lets through only the requests with the session found by Auth
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := session.SessionFromContext(r.Context())
+		if err != nil {
+			authError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireScope lets through only the sessions allowed to use the scope,
+// so personal access tokens reach only what they were created for
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess, err := session.SessionFromContext(r.Context())
+			if err != nil {
+				authError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			if !sess.HasScope(scope) {
+				msg := "The token has no " + scope + " scope"
+				if scope == session.ScopeAccount {
+					msg = "Access tokens can't be used for account settings"
+				}
+				authError(w, http.StatusForbidden, msg)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole lets through only the authorized users having any of the roles
+func RequireRole(roles ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess, err := session.SessionFromContext(r.Context())
+			if err != nil {
+				authError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			for _, role := range roles {
+				if sess.HasRole(role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			authError(w, http.StatusForbidden, "Not enough rights")
+		})
+	}
+}
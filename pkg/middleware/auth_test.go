@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"myredditclone/pkg/session"
+)
+
+func TestRequireScope(t *testing.T) {
+	handler := RequireScope(session.ScopePost)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	account := RequireScope(session.ScopeAccount)(handler)
+	withScopes := func(scopes ...string) *session.Session {
+		sess := session.NewSession(1, "alice")
+		sess.Scopes = scopes
+		return sess
+	}
+	cases := []struct {
+		name    string
+		handler http.Handler
+		sess    *session.Session
+		status  int
+		message string
+	}{
+		{"login", handler, session.NewSession(1, "alice"), http.StatusNoContent, ""},
+		{"token with the scope", handler, withScopes(session.ScopeRead, session.ScopePost), http.StatusNoContent, ""},
+		{"token without the scope", handler, withScopes(session.ScopeRead), http.StatusForbidden, "The token has no post scope"},
+		{"token for the account", account, withScopes(session.TokenScopes...), http.StatusForbidden, "Access tokens can't be used for account settings"},
+		{"no session", handler, nil, http.StatusUnauthorized, session.ErrNoAuth.Error()},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+			if c.sess != nil {
+				req = req.WithContext(session.ContextWithSession(req.Context(), c.sess))
+			}
+			rec := httptest.NewRecorder()
+			c.handler.ServeHTTP(rec, req)
+			if rec.Code != c.status {
+				t.Fatalf("status = %v, want %v", rec.Code, c.status)
+			}
+			if c.message == "" {
+				return
+			}
+			var resp struct {
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Message != c.message {
+				t.Errorf("message = %q, want %q", resp.Message, c.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"github.com/gorilla/mux"
+	"myredditclone/pkg/metrics"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type routeKey struct{}
+
+// statusWriter remembers the status code of the response. It passes
+// Flusher and Hijacker through for the event streams and the websockets,
+// and Unwrap for http.ResponseController
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && w.status == 0 {
+		// the upgrade response is written to the connection directly
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Metrics records the requests by the route template set by Route,
+// it has to wrap the router to see the unmatched requests too
+func Metrics(m *metrics.Metrics) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r, route := withRoute(r)
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			code := strconv.Itoa(sw.status)
+			m.Requests.WithLabelValues(*route, r.Method, code).Inc()
+			m.RequestDuration.WithLabelValues(*route, r.Method, code).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// withRoute adds the route template holder to the request, Metrics and
+// Tracing wrapping each other share the one of the outer
+func withRoute(r *http.Request) (*http.Request, *string) {
+	if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+		return r, route
+	}
+	route := metrics.RouteUnmatched
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)), &route
+}
+
+// Route passes the template of the matched route to Metrics and Tracing,
+// it runs inside the router, which knows the route
+func Route(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			*route = routeTemplate(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeTemplate is the path template of the route mux matched, without the
+// patterns of the variables
+func routeTemplate(r *http.Request) string {
+	current := mux.CurrentRoute(r)
+	if current == nil {
+		return metrics.RouteUnmatched
+	}
+	tmpl, err := current.GetPathTemplate()
+	if err != nil {
+		return metrics.RouteUnmatched
+	}
+	return stripPatterns(tmpl)
+}
+
+// stripPatterns turns /post/{POST_ID:[0-9]+} into /post/{POST_ID}
+func stripPatterns(tmpl string) string {
+	out := make([]byte, 0, len(tmpl))
+	depth := 0
+	skip := false
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch {
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				skip = false
+			}
+		case c == ':' && depth == 1:
+			skip = true
+		}
+		if !skip {
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"myredditclone/pkg/metrics"
+)
+
+// Metrics and Tracing wrap the router the way main does, so the requests no
+// route took are counted too
+func TestMetricsRoute(t *testing.T) {
+	m := metrics.New()
+	r := mux.NewRouter()
+	r.Use(Route)
+	r.HandleFunc("/api/post/{POST_ID:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	handler := Metrics(m)(Tracing(r))
+	for _, c := range []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/api/post/1"},
+		{http.MethodGet, "/api/post/2"},
+		{http.MethodGet, "/api/unknown"},
+		{http.MethodDelete, "/api/post/1"},
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(c.method, c.path, nil))
+	}
+	for _, c := range []struct {
+		route, method, code string
+		want                float64
+	}{
+		{"/api/post/{POST_ID}", http.MethodGet, "200", 2},
+		{metrics.RouteUnmatched, http.MethodGet, "404", 1},
+		{metrics.RouteUnmatched, http.MethodDelete, "405", 1},
+	} {
+		if got := testutil.ToFloat64(m.Requests.WithLabelValues(c.route, c.method, c.code)); got != c.want {
+			t.Errorf("requests %v %v %v = %v, want %v", c.method, c.route, c.code, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"golang.org/x/time/rate"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter keeps a token bucket per client IP
+type RateLimiter struct {
+	rps     rate.Limit
+	burst   int
+	clients map[string]*client
+	swept   time.Time
+	mu      sync.Mutex
+}
+
+type client struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+// idleTTL is how long the bucket of a quiet client is kept
+const idleTTL = 10 * time.Minute
+
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rps:     rate.Limit(rps),
+		burst:   burst,
+		clients: make(map[string]*client),
+	}
+}
+
+func (rl *RateLimiter) reserve(ip string, now time.Time) *rate.Reservation {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if now.Sub(rl.swept) > idleTTL {
+		for key, c := range rl.clients {
+			if now.Sub(c.seen) > idleTTL {
+				delete(rl.clients, key)
+			}
+		}
+		rl.swept = now
+	}
+	c, ok := rl.clients[ip]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(rl.rps, rl.burst)}
+		rl.clients[ip] = c
+	}
+	c.seen = now
+	return c.limiter.ReserveN(now, 1)
+}
+
+// Allow takes a request of the client with the key, which is the client IP
+// for Limit, out of its requests it returns how long to wait
+func (rl *RateLimiter) Allow(key string) (time.Duration, bool) {
+	now := time.Now()
+	res := rl.reserve(key, now)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+// ClientIP is the address the request came from
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// RetryAfter tells the client out of its requests when to come back
+func RetryAfter(w http.ResponseWriter, delay time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	authError(w, http.StatusTooManyRequests, "Too many requests, try again later")
+}
+
+// Limit answers 429 to the clients out of their requests
+func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay, ok := rl.Allow(ClientIP(r)); !ok {
+			RetryAfter(w, delay)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,18 @@
+package notifications
+
+import "myredditclone/pkg/events"
+
+// Handle is the domain events subscriber of the notifier
+func (n *Notifier) Handle(event events.Event) error {
+	switch e := event.(type) {
+	case events.PostCreated:
+		return n.PostCreated(e.Post)
+	case events.CommentAdded:
+		return n.CommentAdded(e.Post, e.Comment)
+	case events.Voted:
+		return n.PostVoted(e.Post)
+	case events.PostDeleted:
+		return n.PostDeleted(e.Post)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package notifications
+
+const (
+	TypePostReply      = "post_reply"
+	TypeCommentReply   = "comment_reply"
+	TypeMention        = "mention"
+	TypeScoreMilestone = "score_milestone"
+)
+
+type Actor struct {
+	Username string `json:"username"`
+	ID       string `json:"id"`
+}
+
+type Notification struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Recipient string `json:"-"` //Login of the user
+	Actor     *Actor `json:"actor,omitempty"`
+	PostID    string `json:"postId"`
+	CommentID string `json:"commentId,omitempty"`
+	Body      string `json:"body,omitempty"`
+	Score     int64  `json:"score,omitempty"`
+	Read      bool   `json:"read"`
+	Created   string `json:"created"`
+}
+
+type NotificationRepo interface {
+	Add(item *Notification) error
+	GetByRecipient(login string) ([]Notification, error)
+	UnreadCount(login string) (int, error)
+	MarkRead(login, id string) error
+	MarkAllRead(login string) (int, error)
+	DeleteByRecipient(login string) error
+}
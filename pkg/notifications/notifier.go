@@ -0,0 +1,221 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/mail"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/user"
+	"regexp"
+	"sync"
+)
+
+var (
+	mentionRegexp   = regexp.MustCompile(`(?:^|[^\w/])u/([\w-]+)`)
+	ScoreMilestones = []int64{10, 50, 100, 500, 1000, 5000, 10000}
+)
+
+// Notifier turns activity on posts into notifications for the interested users
+type Notifier struct {
+	Repo   NotificationRepo
+	Blocks blocks.BlockRepo
+	// Users and Mailer send the notifications to the email from the
+	// preferences of the recipient, if set
+	Users  user.UserRepo
+	Mailer mail.Sender
+
+	// reached keeps the highest milestone already announced for every post
+	reached map[string]int64
+	mu      sync.Mutex
+}
+
+func NewNotifier(repo NotificationRepo, blockRepo blocks.BlockRepo) *Notifier {
+	return &Notifier{
+		Repo:    repo,
+		Blocks:  blockRepo,
+		reached: map[string]int64{},
+	}
+}
+
+func ParseMentions(body string) []string {
+	logins := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, match := range mentionRegexp.FindAllStringSubmatch(body, -1) {
+		if _, ok := seen[match[1]]; ok {
+			continue
+		}
+		seen[match[1]] = struct{}{}
+		logins = append(logins, match[1])
+	}
+	return logins
+}
+
+// notify skips the own actions of the recipient and the actions of the users
+// blocked by the recipient
+func (n *Notifier) notify(item Notification) error {
+	if item.Actor != nil {
+		if item.Actor.Username == item.Recipient {
+			return nil
+		}
+		isBlocked, err := n.Blocks.IsBlocked(item.Recipient, item.Actor.Username)
+		if err != nil {
+			return err
+		}
+		if isBlocked {
+			return nil
+		}
+	}
+	err := n.Repo.Add(&item)
+	if err != nil {
+		return err
+	}
+	return n.email(item)
+}
+
+func subject(item Notification) string {
+	switch item.Type {
+	case TypePostReply:
+		return fmt.Sprintf("u/%s replied to your post", item.Actor.Username)
+	case TypeCommentReply:
+		return fmt.Sprintf("u/%s replied to your comment", item.Actor.Username)
+	case TypeMention:
+		return fmt.Sprintf("u/%s mentioned you", item.Actor.Username)
+	case TypeScoreMilestone:
+		return fmt.Sprintf("Your post reached %d points", item.Score)
+	}
+	return "New notification"
+}
+
+// email sends the notification to the email the recipient wants them at
+func (n *Notifier) email(item Notification) error {
+	if n.Users == nil || n.Mailer == nil {
+		return nil
+	}
+	usr, err := n.Users.GetByLogin(context.Background(), item.Recipient)
+	if errors.Is(err, user.ErrNoUser) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if usr.Preferences.NotifyEmail == "" {
+		return nil
+	}
+	body := fmt.Sprintf("Hi %s,\n\n%s", usr.Login, subject(item))
+	if item.Body != "" {
+		body += ":\n\n" + item.Body
+	}
+	body += "\n\nYou get this email because of the notification email in your preferences.\n"
+	return n.Mailer.Send(mail.Message{
+		To:      usr.Preferences.NotifyEmail,
+		Subject: subject(item),
+		Body:    body,
+	})
+}
+
+// exists tells whether there is the user to notify, without the users
+// repository every login is taken as is
+func (n *Notifier) exists(login string) (bool, error) {
+	if n.Users == nil {
+		return true, nil
+	}
+	_, err := n.Users.GetByLogin(context.Background(), login)
+	if errors.Is(err, user.ErrNoUser) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (n *Notifier) mentions(author posts.Author, postID, commentID, body string, skip map[string]struct{}) error {
+	for _, login := range ParseMentions(body) {
+		if _, ok := skip[login]; ok {
+			continue
+		}
+		ok, err := n.exists(login)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		err = n.notify(Notification{
+			Type:      TypeMention,
+			Recipient: login,
+			Actor:     &Actor{Username: author.Username, ID: author.ID},
+			PostID:    postID,
+			CommentID: commentID,
+			Body:      body,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PostCreated notifies users mentioned in the title or text of the new post
+func (n *Notifier) PostCreated(post posts.Post) error {
+	return n.mentions(post.Author, post.ID, "", post.Title+"\n"+post.Text, nil)
+}
+
+// CommentAdded notifies the author of the post (or of the parent comment)
+// about the reply and every user mentioned in it
+func (n *Notifier) CommentAdded(post posts.Post, comm posts.Comment) error {
+	actor := &Actor{Username: comm.Author.Username, ID: comm.Author.ID}
+	reply := Notification{
+		Type:      TypePostReply,
+		Recipient: post.Author.Username,
+		Actor:     actor,
+		PostID:    post.ID,
+		CommentID: comm.ID,
+		Body:      comm.Body,
+	}
+	if comm.ParentID != "" {
+		for _, parent := range post.Comments {
+			if parent.ID == comm.ParentID {
+				reply.Type = TypeCommentReply
+				reply.Recipient = parent.Author.Username
+				break
+			}
+		}
+	}
+	err := n.notify(reply)
+	if err != nil {
+		return err
+	}
+	return n.mentions(comm.Author, post.ID, comm.ID, comm.Body, map[string]struct{}{reply.Recipient: {}})
+}
+
+// PostVoted notifies the author once the post score reaches the next milestone
+func (n *Notifier) PostVoted(post posts.Post) error {
+	n.mu.Lock()
+	var milestone int64
+	for _, m := range ScoreMilestones {
+		if post.Score >= m && m > n.reached[post.ID] {
+			milestone = m
+		}
+	}
+	if milestone != 0 {
+		n.reached[post.ID] = milestone
+	}
+	n.mu.Unlock()
+	if milestone == 0 {
+		return nil
+	}
+	return n.notify(Notification{
+		Type:      TypeScoreMilestone,
+		Recipient: post.Author.Username,
+		PostID:    post.ID,
+		Score:     milestone,
+	})
+}
+
+// PostDeleted forgets the milestones of the deleted post
+func (n *Notifier) PostDeleted(post posts.Post) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.reached, post.ID)
+	return nil
+}
@@ -0,0 +1,182 @@
+package notifications
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/mail"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/user"
+)
+
+type mailbox struct {
+	sent []mail.Message
+	mu   sync.Mutex
+}
+
+func (m *mailbox) Send(msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func newTestNotifier(t *testing.T, logins ...string) (*Notifier, *NotificationMemoryRepository, *user.UserRepository) {
+	t.Helper()
+	users := user.NewUserRepository()
+	for _, login := range logins {
+		if _, err := users.Register(context.Background(), login, "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo := NewNotificationMemoryRepository()
+	n := NewNotifier(repo, blocks.NewBlockMemoryRepository())
+	n.Users = users
+	return n, repo, users
+}
+
+func TestNotifyEmail(t *testing.T) {
+	n, repo, users := newTestNotifier(t, "alice", "bob")
+	box := &mailbox{}
+	n.Mailer = box
+	prefs := user.DefaultPreferences()
+	prefs.NotifyEmail = "alice@example.com"
+	if err := users.SetPreferences(context.Background(), "alice", prefs); err != nil {
+		t.Fatal(err)
+	}
+	post := posts.Post{ID: "1", Author: posts.Author{Username: "alice", ID: "1"}}
+	reply := posts.Comment{ID: "c1", Author: posts.Author{Username: "bob", ID: "2"}, Body: "nice one"}
+	if err := n.CommentAdded(post, reply); err != nil {
+		t.Fatal(err)
+	}
+	// bob has no notification email
+	own := posts.Comment{ID: "c2", ParentID: "c1", Author: posts.Author{Username: "alice", ID: "1"}, Body: "thanks"}
+	post.Comments = []posts.Comment{reply}
+	if err := n.CommentAdded(post, own); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := repo.GetByRecipient("bob"); len(got) != 1 {
+		t.Errorf("notifications of bob = %+v, want the reply", got)
+	}
+	if len(box.sent) != 1 {
+		t.Fatalf("sent = %+v, want one email", box.sent)
+	}
+	msg := box.sent[0]
+	if msg.To != "alice@example.com" || msg.Subject != "u/bob replied to your post" || !strings.Contains(msg.Body, "nice one") {
+		t.Errorf("email = %+v", msg)
+	}
+}
+
+// received lists the notifications of the recipient as "type actor score"
+func received(t *testing.T, repo *NotificationMemoryRepository, login string) []string {
+	t.Helper()
+	items, err := repo.GetByRecipient(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(items))
+	for _, item := range items {
+		actor := ""
+		if item.Actor != nil {
+			actor = item.Actor.Username
+		}
+		got = append(got, strings.Join(strings.Fields(item.Type+" "+actor+" "+strconv.FormatInt(item.Score, 10)), " "))
+	}
+	sort.Strings(got)
+	return got
+}
+
+func assertReceived(t *testing.T, repo *NotificationMemoryRepository, login string, want ...string) {
+	t.Helper()
+	got := received(t, repo, login)
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("notifications of %v = %q, want %q", login, got, want)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	n, repo, _ := newTestNotifier(t, "alice", "bob", "carol")
+	alice := posts.Author{Username: "alice", ID: "1"}
+	post := posts.Post{
+		ID:     "1",
+		Author: alice,
+		Title:  "u/bob, u/ghost and me",
+		Text:   "u/alice thanks u/bob again, r/u/carol is not a mention",
+	}
+	if err := n.PostCreated(post); err != nil {
+		t.Fatal(err)
+	}
+	assertReceived(t, repo, "bob", TypeMention+" alice 0")
+	// the own and the broken mentions are skipped
+	assertReceived(t, repo, "alice")
+	assertReceived(t, repo, "ghost")
+	assertReceived(t, repo, "carol")
+
+	// the author of the post gets the reply, not the mention
+	comm := posts.Comment{ID: "c1", Author: posts.Author{Username: "carol", ID: "3"}, Body: "u/alice u/bob"}
+	if err := n.CommentAdded(post, comm); err != nil {
+		t.Fatal(err)
+	}
+	assertReceived(t, repo, "alice", TypePostReply+" carol 0")
+	assertReceived(t, repo, "bob", TypeMention+" alice 0", TypeMention+" carol 0")
+}
+
+func TestReplies(t *testing.T) {
+	n, repo, _ := newTestNotifier(t, "alice", "bob", "carol")
+	post := posts.Post{ID: "1", Author: posts.Author{Username: "alice", ID: "1"}}
+	first := posts.Comment{ID: "c1", Author: posts.Author{Username: "bob", ID: "2"}, Body: "first"}
+	if err := n.CommentAdded(post, first); err != nil {
+		t.Fatal(err)
+	}
+	post.Comments = []posts.Comment{first}
+	reply := posts.Comment{ID: "c2", ParentID: "c1", Author: posts.Author{Username: "carol", ID: "3"}, Body: "second"}
+	if err := n.CommentAdded(post, reply); err != nil {
+		t.Fatal(err)
+	}
+	assertReceived(t, repo, "alice", TypePostReply+" bob 0")
+	assertReceived(t, repo, "bob", TypeCommentReply+" carol 0")
+
+	// the blocked users reach nobody, the own replies aren't news
+	if err := n.Blocks.Block("alice", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	for _, comm := range []posts.Comment{
+		{ID: "c3", Author: posts.Author{Username: "carol", ID: "3"}, Body: "blocked"},
+		{ID: "c4", Author: posts.Author{Username: "alice", ID: "1"}, Body: "own"},
+	} {
+		if err := n.CommentAdded(post, comm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertReceived(t, repo, "alice", TypePostReply+" bob 0")
+}
+
+func TestMilestones(t *testing.T) {
+	n, repo, _ := newTestNotifier(t, "alice")
+	post := posts.Post{ID: "1", Author: posts.Author{Username: "alice", ID: "1"}}
+	for _, score := range []int64{9, 10, 12, 9, 11, 120} {
+		post.Score = score
+		if err := n.Handle(events.Voted{Post: post}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the jump over 50 announces 100 only
+	assertReceived(t, repo, "alice", TypeScoreMilestone+" 10", TypeScoreMilestone+" 100")
+
+	if err := n.Handle(events.PostDeleted{Post: post}); err != nil {
+		t.Fatal(err)
+	}
+	n.mu.Lock()
+	kept := len(n.reached)
+	n.mu.Unlock()
+	if kept != 0 {
+		t.Errorf("reached %v milestones after the post is deleted, want none", kept)
+	}
+}
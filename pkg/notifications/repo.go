@@ -0,0 +1,99 @@
+package notifications
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/apperrors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoNotification = apperrors.New(apperrors.ErrNotFound, "Current notification doesn't exist")
+)
+
+var _ NotificationRepo = NewNotificationMemoryRepository()
+
+type NotificationMemoryRepository struct {
+	data map[string][]Notification
+	mu   sync.RWMutex
+}
+
+func NewNotificationMemoryRepository() *NotificationMemoryRepository {
+	return &NotificationMemoryRepository{
+		data: map[string][]Notification{},
+	}
+}
+
+func (repo *NotificationMemoryRepository) Add(item *Notification) error {
+	randomID, err := uuid.GenerateRandomBytes(16)
+	if err != nil {
+		return err
+	}
+	item.ID = fmt.Sprintf("%x", randomID)
+	if item.Created == "" {
+		item.Created = time.Now().Format("2006-01-02T15:04:05.000")
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.data[item.Recipient] = append(repo.data[item.Recipient], *item)
+	return nil
+}
+
+// GetByRecipient returns user's notifications, the newest first
+func (repo *NotificationMemoryRepository) GetByRecipient(login string) ([]Notification, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	items := repo.data[login]
+	res := make([]Notification, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		res = append(res, items[i])
+	}
+	return res, nil
+}
+
+func (repo *NotificationMemoryRepository) UnreadCount(login string) (int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	count := 0
+	for _, item := range repo.data[login] {
+		if !item.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (repo *NotificationMemoryRepository) MarkRead(login, id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	items := repo.data[login]
+	for i := range items {
+		if items[i].ID == id {
+			items[i].Read = true
+			return nil
+		}
+	}
+	return ErrNoNotification
+}
+
+func (repo *NotificationMemoryRepository) MarkAllRead(login string) (int, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	marked := 0
+	items := repo.data[login]
+	for i := range items {
+		if !items[i].Read {
+			items[i].Read = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (repo *NotificationMemoryRepository) DeleteByRecipient(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.data, login)
+	return nil
+}
@@ -0,0 +1,101 @@
+package oauth
+
+import (
+	"myredditclone/pkg/session"
+	"time"
+)
+
+const (
+	AccessPrefix  = "rco_"
+	RefreshPrefix = "rcr_"
+	SecretPrefix  = "rcs_"
+
+	KindAccess  = "access_token"
+	KindRefresh = "refresh_token"
+
+	CodeTTL    = 10 * time.Minute
+	AccessTTL  = time.Hour
+	RefreshTTL = 30 * 24 * time.Hour
+)
+
+// ScopeDescriptions are shown to the user on the consent screen
+var ScopeDescriptions = map[string]string{
+	session.ScopeRead:     "Read your saved and hidden posts and your notifications",
+	session.ScopePost:     "Submit and delete posts and comments, save and hide posts, mark notifications read",
+	session.ScopeVote:     "Vote on posts",
+	session.ScopeModerate: "Use your moderator and admin rights",
+}
+
+// App is a registered third-party client. Public apps (e.g. mobile or SPA)
+// have no secret and rely on PKCE only
+type App struct {
+	ClientID     string   `json:"clientId"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirectUris"`
+	Confidential bool     `json:"confidential"`
+	Owner        string   `json:"owner"`
+	Created      string   `json:"created"`
+	SecretHash   string   `json:"-"`
+}
+
+// Code is the authorization code waiting to be exchanged
+type Code struct {
+	ClientID      string
+	RedirectURI   string
+	Login         string
+	Scopes        []string
+	CodeChallenge string
+	GrantID       string
+	Expires       time.Time
+	Used          bool
+}
+
+// Token is an issued access or refresh token, all the tokens of one
+// authorization share its grant ID
+type Token struct {
+	Hash     string
+	Kind     string
+	ClientID string
+	Login    string
+	Scopes   []string
+	GrantID  string
+	Issued   time.Time
+	Expires  time.Time
+	Rotated  bool //the refresh token was exchanged, it's kept to catch the reuse
+}
+
+type AppRepo interface {
+	Add(app *App) error
+	Get(clientID string) (App, error)
+	GetByOwner(login string) ([]App, error)
+	Delete(owner, clientID string) error
+}
+
+type GrantRepo interface {
+	SaveCode(hash string, code Code) error
+	// TakeCode marks the code used, returning it even if it was used before
+	TakeCode(hash string) (Code, error)
+	SaveToken(token Token) error
+	GetToken(hash string) (Token, error)
+	// TakeToken marks the token rotated, returning it even if it was rotated before
+	TakeToken(hash string) (Token, error)
+	DeleteToken(hash string) error
+	RevokeGrant(grantID string) error
+	RevokeClient(clientID string) error
+	// RevokeUser drops all the codes and tokens issued to the user's apps
+	RevokeUser(login string) error
+}
+
+// Error is an error of the OAuth endpoints in terms of RFC 6749
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) error {
+	return &Error{Code: code, Description: description}
+}
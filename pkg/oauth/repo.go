@@ -0,0 +1,202 @@
+package oauth
+
+import (
+	"myredditclone/pkg/apperrors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoApp   = apperrors.New(apperrors.ErrNotFound, "Current app doesn't exist")
+	ErrNoCode  = apperrors.New(apperrors.ErrNotFound, "Current code doesn't exist")
+	ErrNoToken = apperrors.New(apperrors.ErrNotFound, "Current token doesn't exist")
+)
+
+var (
+	_ AppRepo   = NewAppMemoryRepository()
+	_ GrantRepo = NewGrantMemoryRepository()
+)
+
+type AppMemoryRepository struct {
+	data map[string]App //by client ID
+	mu   sync.RWMutex
+}
+
+func NewAppMemoryRepository() *AppMemoryRepository {
+	return &AppMemoryRepository{
+		data: map[string]App{},
+	}
+}
+
+func (repo *AppMemoryRepository) Add(app *App) error {
+	app.Created = time.Now().Format("2006-01-02T15:04:05.000")
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.data[app.ClientID] = *app
+	return nil
+}
+
+func (repo *AppMemoryRepository) Get(clientID string) (App, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	app, ok := repo.data[clientID]
+	if !ok {
+		return App{}, ErrNoApp
+	}
+	return app, nil
+}
+
+func (repo *AppMemoryRepository) GetByOwner(login string) ([]App, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	res := make([]App, 0)
+	for _, app := range repo.data {
+		if app.Owner == login {
+			res = append(res, app)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Created > res[j].Created
+	})
+	return res, nil
+}
+
+func (repo *AppMemoryRepository) Delete(owner, clientID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	app, ok := repo.data[clientID]
+	if !ok || app.Owner != owner {
+		return ErrNoApp
+	}
+	delete(repo.data, clientID)
+	return nil
+}
+
+type GrantMemoryRepository struct {
+	codes  map[string]Code  //by code hash
+	tokens map[string]Token //by token hash
+	mu     sync.RWMutex
+}
+
+func NewGrantMemoryRepository() *GrantMemoryRepository {
+	return &GrantMemoryRepository{
+		codes:  map[string]Code{},
+		tokens: map[string]Token{},
+	}
+}
+
+func (repo *GrantMemoryRepository) SaveCode(hash string, code Code) error {
+	now := time.Now()
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for h, c := range repo.codes {
+		if now.After(c.Expires) {
+			delete(repo.codes, h)
+		}
+	}
+	repo.codes[hash] = code
+	return nil
+}
+
+func (repo *GrantMemoryRepository) TakeCode(hash string) (Code, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	code, ok := repo.codes[hash]
+	if !ok {
+		return Code{}, ErrNoCode
+	}
+	res := code
+	code.Used = true
+	repo.codes[hash] = code
+	return res, nil
+}
+
+func (repo *GrantMemoryRepository) SaveToken(token Token) error {
+	now := time.Now()
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for h, t := range repo.tokens {
+		if now.After(t.Expires) {
+			delete(repo.tokens, h)
+		}
+	}
+	repo.tokens[token.Hash] = token
+	return nil
+}
+
+func (repo *GrantMemoryRepository) GetToken(hash string) (Token, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	token, ok := repo.tokens[hash]
+	if !ok {
+		return Token{}, ErrNoToken
+	}
+	return token, nil
+}
+
+func (repo *GrantMemoryRepository) TakeToken(hash string) (Token, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	token, ok := repo.tokens[hash]
+	if !ok {
+		return Token{}, ErrNoToken
+	}
+	res := token
+	token.Rotated = true
+	repo.tokens[hash] = token
+	return res, nil
+}
+
+func (repo *GrantMemoryRepository) DeleteToken(hash string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, ok := repo.tokens[hash]; !ok {
+		return ErrNoToken
+	}
+	delete(repo.tokens, hash)
+	return nil
+}
+
+func (repo *GrantMemoryRepository) RevokeGrant(grantID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for h, token := range repo.tokens {
+		if token.GrantID == grantID {
+			delete(repo.tokens, h)
+		}
+	}
+	return nil
+}
+
+func (repo *GrantMemoryRepository) RevokeClient(clientID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for h, token := range repo.tokens {
+		if token.ClientID == clientID {
+			delete(repo.tokens, h)
+		}
+	}
+	for h, code := range repo.codes {
+		if code.ClientID == clientID {
+			delete(repo.codes, h)
+		}
+	}
+	return nil
+}
+
+func (repo *GrantMemoryRepository) RevokeUser(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for h, token := range repo.tokens {
+		if token.Login == login {
+			delete(repo.tokens, h)
+		}
+	}
+	for h, code := range repo.codes {
+		if code.Login == login {
+			delete(repo.codes, h)
+		}
+	}
+	return nil
+}
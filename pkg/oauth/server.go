@@ -0,0 +1,409 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var _ session.TokenAuthenticator = &Server{}
+
+var ErrBadClient = apperrors.Validation("client_id", "", "Unknown client or redirect URI")
+
+// AuthRequest are the parameters of the authorization endpoint
+type AuthRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string //space separated
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// Introspection is the response of RFC 7662, only Active is set for unknown tokens
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+}
+
+// Server is the authorization server: it lets the users grant the apps
+// scoped access, and authenticates the issued tokens for SessionsManager
+type Server struct {
+	Apps   AppRepo
+	Grants GrantRepo
+	Users  user.UserRepo
+}
+
+func NewServer(apps AppRepo, grants GrantRepo, users user.UserRepo) *Server {
+	return &Server{
+		Apps:   apps,
+		Grants: grants,
+		Users:  users,
+	}
+}
+
+func hash(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+func randomHex(n int) (string, error) {
+	b, err := uuid.GenerateRandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
+
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Fragment != "" || u.Host == "" {
+		return false
+	}
+	if u.Scheme == "https" {
+		return true
+	}
+	//plain http only for the apps in development
+	return u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1")
+}
+
+// RegisterApp returns the client secret of confidential apps, it is shown only once
+func (s *Server) RegisterApp(owner, name string, redirectURIs []string, confidential bool) (App, string, error) {
+	if name == "" {
+		return App{}, "", apperrors.Validation("name", "", "name is required")
+	}
+	if len(redirectURIs) == 0 {
+		return App{}, "", apperrors.Validation("redirectUris", "", "at least one redirect URI is required")
+	}
+	for _, uri := range redirectURIs {
+		if !govalidator.IsURL(uri) || !validRedirectURI(uri) {
+			return App{}, "", apperrors.Validation("redirectUris", uri, "redirect URI must be an https URL without fragment")
+		}
+	}
+	clientID, err := randomHex(16)
+	if err != nil {
+		return App{}, "", err
+	}
+	app := App{
+		ClientID:     clientID,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Confidential: confidential,
+		Owner:        owner,
+	}
+	secret := ""
+	if confidential {
+		secret, err = randomHex(32)
+		if err != nil {
+			return App{}, "", err
+		}
+		secret = SecretPrefix + secret
+		app.SecretHash = hash(secret)
+	}
+	err = s.Apps.Add(&app)
+	if err != nil {
+		return App{}, "", err
+	}
+	return app, secret, nil
+}
+
+// DeleteApp revokes everything issued to the app as well
+func (s *Server) DeleteApp(owner, clientID string) error {
+	err := s.Apps.Delete(owner, clientID)
+	if err != nil {
+		return err
+	}
+	return s.Grants.RevokeClient(clientID)
+}
+
+// RevokeUser logs the user out of all the apps
+func (s *Server) RevokeUser(login string) error {
+	return s.Grants.RevokeUser(login)
+}
+
+// DeleteUser revokes the user's grants and deletes the apps the user registered
+func (s *Server) DeleteUser(login string) error {
+	apps, err := s.Apps.GetByOwner(login)
+	if err != nil {
+		return err
+	}
+	for _, app := range apps {
+		err = s.DeleteApp(login, app.ClientID)
+		if err != nil && !errors.Is(err, ErrNoApp) {
+			return err
+		}
+	}
+	return s.RevokeUser(login)
+}
+
+// ValidateClient checks what can't be reported by redirecting back to the app
+func (s *Server) ValidateClient(req AuthRequest) (App, error) {
+	app, err := s.Apps.Get(req.ClientID)
+	if err != nil {
+		return App{}, ErrBadClient
+	}
+	for _, uri := range app.RedirectURIs {
+		if uri == req.RedirectURI {
+			return app, nil
+		}
+	}
+	return App{}, ErrBadClient
+}
+
+// ValidateRequest returns the requested scopes, the errors are the ones
+// to redirect back to the app with
+func (s *Server) ValidateRequest(req AuthRequest) ([]string, error) {
+	if req.ResponseType != "code" {
+		return nil, oauthError("unsupported_response_type", "only the code response type is supported")
+	}
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != 43 {
+		return nil, oauthError("invalid_request", "PKCE with the S256 code challenge is required")
+	}
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		return nil, oauthError("invalid_scope", "no scope requested")
+	}
+	for _, scope := range scopes {
+		if _, ok := ScopeDescriptions[scope]; !ok {
+			return nil, oauthError("invalid_scope", "unknown scope "+scope)
+		}
+	}
+	return scopes, nil
+}
+
+func redirectWith(uri string, params url.Values) string {
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return uri + sep + params.Encode()
+}
+
+// Approve issues the code for the user consent, returns where to redirect the user
+func (s *Server) Approve(login string, req AuthRequest) (string, error) {
+	_, err := s.ValidateClient(req)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	scopes, err := s.ValidateRequest(req)
+	if err != nil {
+		oerr := err.(*Error)
+		params.Set("error", oerr.Code)
+		params.Set("error_description", oerr.Description)
+		return redirectWith(req.RedirectURI, params), nil
+	}
+	code, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	grantID, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	err = s.Grants.SaveCode(hash(code), Code{
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Login:         login,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		GrantID:       grantID,
+		Expires:       time.Now().Add(CodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	params.Set("code", code)
+	return redirectWith(req.RedirectURI, params), nil
+}
+
+// Deny returns where to redirect the user who refused the app
+func (s *Server) Deny(req AuthRequest) (string, error) {
+	_, err := s.ValidateClient(req)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{"error": {"access_denied"}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return redirectWith(req.RedirectURI, params), nil
+}
+
+// AuthenticateClient checks the secret of confidential apps,
+// public apps are identified by the client ID only
+func (s *Server) AuthenticateClient(clientID, secret string) (App, error) {
+	app, err := s.Apps.Get(clientID)
+	if err != nil {
+		return App{}, oauthError("invalid_client", "unknown client")
+	}
+	if !app.Confidential {
+		if secret != "" {
+			return App{}, oauthError("invalid_client", "public clients have no secret")
+		}
+		return app, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(app.SecretHash)) != 1 {
+		return App{}, oauthError("invalid_client", "wrong client secret")
+	}
+	return app, nil
+}
+
+func verifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// ExchangeCode is the authorization_code grant. A code used twice revokes
+// everything issued with it, as it was likely stolen
+func (s *Server) ExchangeCode(app App, code, redirectURI, verifier string) (TokenResponse, error) {
+	c, err := s.Grants.TakeCode(hash(code))
+	if err != nil || time.Now().After(c.Expires) || c.ClientID != app.ClientID {
+		return TokenResponse{}, oauthError("invalid_grant", "the code is invalid or expired")
+	}
+	if c.Used {
+		_ = s.Grants.RevokeGrant(c.GrantID)
+		return TokenResponse{}, oauthError("invalid_grant", "the code was already used")
+	}
+	if c.RedirectURI != redirectURI {
+		return TokenResponse{}, oauthError("invalid_grant", "redirect_uri doesn't match")
+	}
+	if !verifyPKCE(verifier, c.CodeChallenge) {
+		return TokenResponse{}, oauthError("invalid_grant", "code_verifier doesn't match")
+	}
+	return s.issue(app.ClientID, c.Login, c.GrantID, c.Scopes)
+}
+
+// Refresh is the refresh_token grant, the refresh token is rotated. A rotated
+// token used again revokes the whole grant, as it was likely stolen
+func (s *Server) Refresh(app App, refreshToken string) (TokenResponse, error) {
+	h := hash(refreshToken)
+	t, err := s.Grants.GetToken(h)
+	if err != nil || t.Kind != KindRefresh || t.ClientID != app.ClientID || time.Now().After(t.Expires) {
+		return TokenResponse{}, oauthError("invalid_grant", "the refresh token is invalid or expired")
+	}
+	t, err = s.Grants.TakeToken(h)
+	if err != nil {
+		return TokenResponse{}, oauthError("invalid_grant", "the refresh token is invalid or expired")
+	}
+	if t.Rotated {
+		_ = s.Grants.RevokeGrant(t.GrantID)
+		return TokenResponse{}, oauthError("invalid_grant", "the refresh token was already used")
+	}
+	return s.issue(app.ClientID, t.Login, t.GrantID, t.Scopes)
+}
+
+func (s *Server) issue(clientID, login, grantID string, scopes []string) (TokenResponse, error) {
+	now := time.Now()
+	access, err := randomHex(32)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	refresh, err := randomHex(32)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	access, refresh = AccessPrefix+access, RefreshPrefix+refresh
+	for _, t := range []Token{
+		{Hash: hash(access), Kind: KindAccess, Expires: now.Add(AccessTTL)},
+		{Hash: hash(refresh), Kind: KindRefresh, Expires: now.Add(RefreshTTL)},
+	} {
+		t.ClientID, t.Login, t.GrantID, t.Scopes, t.Issued = clientID, login, grantID, scopes, now
+		err = s.Grants.SaveToken(t)
+		if err != nil {
+			return TokenResponse{}, err
+		}
+	}
+	return TokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(AccessTTL.Seconds()),
+		RefreshToken: refresh,
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// Introspect reveals only the tokens issued to the asking app
+func (s *Server) Introspect(ctx context.Context, app App, token string) Introspection {
+	t, err := s.Grants.GetToken(hash(token))
+	if err != nil || t.ClientID != app.ClientID || t.Rotated || time.Now().After(t.Expires) {
+		return Introspection{}
+	}
+	if _, err = s.Users.GetByLogin(ctx, t.Login); err != nil {
+		return Introspection{}
+	}
+	return Introspection{
+		Active:    true,
+		Scope:     strings.Join(t.Scopes, " "),
+		ClientID:  t.ClientID,
+		Username:  t.Login,
+		TokenType: t.Kind,
+		Exp:       t.Expires.Unix(),
+		Iat:       t.Issued.Unix(),
+	}
+}
+
+// Revoke drops the access token, or the whole grant for a refresh token.
+// Unknown tokens are ignored as RFC 7009 requires
+func (s *Server) Revoke(app App, token string) {
+	h := hash(token)
+	t, err := s.Grants.GetToken(h)
+	if err != nil || t.ClientID != app.ClientID {
+		return
+	}
+	if t.Kind == KindRefresh {
+		_ = s.Grants.RevokeGrant(t.GrantID)
+		return
+	}
+	_ = s.Grants.DeleteToken(h)
+}
+
+func (s *Server) Accepts(token string) bool {
+	return strings.HasPrefix(token, AccessPrefix)
+}
+
+func (s *Server) Authenticate(ctx context.Context, token string) (*session.Session, error) {
+	t, err := s.Grants.GetToken(hash(token))
+	if err != nil || t.Kind != KindAccess || time.Now().After(t.Expires) {
+		return nil, session.ErrNoAuth
+	}
+	usr, err := s.Users.GetByLogin(ctx, t.Login)
+	if err != nil {
+		return nil, session.ErrNoAuth
+	}
+	sess := session.NewSession(usr.ID, usr.Login, usr.Roles...)
+	sess.ID = "oauth:" + t.GrantID
+	sess.Scopes = t.Scopes
+	return sess, nil
+}
@@ -0,0 +1,226 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+)
+
+const (
+	testRedirect = "https://app.example.com/callback"
+	testVerifier = "a-verifier-long-enough-to-pass-the-pkce-check"
+)
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newTestServer(t *testing.T) (*Server, App) {
+	t.Helper()
+	users := user.NewUserRepository()
+	for _, login := range []string{"alice", "carol"} {
+		if _, err := users.Register(context.Background(), login, "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewServer(NewAppMemoryRepository(), NewGrantMemoryRepository(), users)
+	app, _, err := s.RegisterApp("carol", "app", []string{testRedirect}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, app
+}
+
+// approve returns the code alice grants the app with
+func approve(t *testing.T, s *Server, app App, scope string) string {
+	t.Helper()
+	location, err := s.Approve("alice", AuthRequest{
+		ResponseType:        "code",
+		ClientID:            app.ClientID,
+		RedirectURI:         testRedirect,
+		Scope:               scope,
+		State:               "xyz",
+		CodeChallenge:       challenge(testVerifier),
+		CodeChallengeMethod: "S256",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("state") != "xyz" || u.Query().Get("code") == "" {
+		t.Fatalf("redirect to %v, want the code and the state", location)
+	}
+	return u.Query().Get("code")
+}
+
+func assertGrantError(t *testing.T, err error, code, description string) {
+	t.Helper()
+	var oerr *Error
+	if !errors.As(err, &oerr) || oerr.Code != code || !strings.Contains(oerr.Description, description) {
+		t.Errorf("error = %v, want %v: %v", err, code, description)
+	}
+}
+
+func TestExchangeCode(t *testing.T) {
+	s, app := newTestServer(t)
+	code := approve(t, s, app, "read vote")
+	resp, err := s.ExchangeCode(app, code, testRedirect, testVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(resp.AccessToken, AccessPrefix) || !strings.HasPrefix(resp.RefreshToken, RefreshPrefix) || resp.Scope != "read vote" {
+		t.Errorf("response = %+v", resp)
+	}
+	sess, err := s.Authenticate(context.Background(), resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Login != "alice" || !sess.HasScope(session.ScopeVote) || sess.HasScope(session.ScopePost) {
+		t.Errorf("session = %+v, want alice with read and vote", sess)
+	}
+	if _, err = s.Authenticate(context.Background(), resp.RefreshToken); !errors.Is(err, session.ErrNoAuth) {
+		t.Errorf("authenticate with the refresh token: error = %v, want ErrNoAuth", err)
+	}
+
+	// the code used twice revokes the tokens issued with it
+	_, err = s.ExchangeCode(app, code, testRedirect, testVerifier)
+	assertGrantError(t, err, "invalid_grant", "already used")
+	if _, err = s.Authenticate(context.Background(), resp.AccessToken); !errors.Is(err, session.ErrNoAuth) {
+		t.Errorf("token of the reused code: error = %v, want ErrNoAuth", err)
+	}
+
+	other, _, err := s.RegisterApp("carol", "other", []string{testRedirect}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ExchangeCode(other, approve(t, s, app, "read"), testRedirect, testVerifier)
+	assertGrantError(t, err, "invalid_grant", "invalid or expired")
+	_, err = s.ExchangeCode(app, approve(t, s, app, "read"), "https://app.example.com/other", testVerifier)
+	assertGrantError(t, err, "invalid_grant", "redirect_uri")
+}
+
+func TestExchangeCodePKCE(t *testing.T) {
+	s, app := newTestServer(t)
+	for _, verifier := range []string{"", "short", testVerifier + "x", strings.Repeat("a", 129)} {
+		_, err := s.ExchangeCode(app, approve(t, s, app, "read"), testRedirect, verifier)
+		assertGrantError(t, err, "invalid_grant", "code_verifier")
+	}
+
+	_, err := s.ValidateRequest(AuthRequest{ResponseType: "code", Scope: "read", CodeChallenge: testVerifier, CodeChallengeMethod: "plain"})
+	assertGrantError(t, err, "invalid_request", "PKCE")
+}
+
+func TestRefresh(t *testing.T) {
+	s, app := newTestServer(t)
+	first, err := s.ExchangeCode(app, approve(t, s, app, "read"), testRedirect, testVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Refresh(app, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.Scope != "read" {
+		t.Errorf("refreshed = %+v, want the new refresh token for read", second)
+	}
+	for _, token := range []string{first.AccessToken, second.AccessToken} {
+		if _, err = s.Authenticate(context.Background(), token); err != nil {
+			t.Errorf("access token before the reuse: %v", err)
+		}
+	}
+	if got := s.Introspect(context.Background(), app, first.RefreshToken); got.Active {
+		t.Errorf("rotated refresh token is active: %+v", got)
+	}
+
+	// the rotated token used again revokes the whole grant
+	_, err = s.Refresh(app, first.RefreshToken)
+	assertGrantError(t, err, "invalid_grant", "already used")
+	for _, token := range []string{first.AccessToken, second.AccessToken} {
+		if _, err = s.Authenticate(context.Background(), token); !errors.Is(err, session.ErrNoAuth) {
+			t.Errorf("access token after the reuse: error = %v, want ErrNoAuth", err)
+		}
+	}
+	_, err = s.Refresh(app, second.RefreshToken)
+	assertGrantError(t, err, "invalid_grant", "invalid or expired")
+
+	_, err = s.Refresh(app, first.AccessToken)
+	assertGrantError(t, err, "invalid_grant", "invalid or expired")
+}
+
+func TestIntrospect(t *testing.T) {
+	s, app := newTestServer(t)
+	resp, err := s.ExchangeCode(app, approve(t, s, app, "read vote"), testRedirect, testVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Introspect(context.Background(), app, resp.AccessToken)
+	if !got.Active || got.Username != "alice" || got.Scope != "read vote" || got.TokenType != KindAccess || got.ClientID != app.ClientID || got.Exp <= got.Iat {
+		t.Errorf("introspection = %+v", got)
+	}
+	if got = s.Introspect(context.Background(), app, resp.RefreshToken); !got.Active || got.TokenType != KindRefresh {
+		t.Errorf("introspection of the refresh token = %+v", got)
+	}
+
+	// other apps learn nothing about the token
+	other, _, err := s.RegisterApp("carol", "other", []string{testRedirect}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got = s.Introspect(context.Background(), other, resp.AccessToken); got != (Introspection{}) {
+		t.Errorf("introspection by another app = %+v, want inactive only", got)
+	}
+	if got = s.Introspect(context.Background(), app, "unknown"); got != (Introspection{}) {
+		t.Errorf("introspection of unknown token = %+v, want inactive only", got)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	s, app := newTestServer(t)
+	first, err := s.ExchangeCode(app, approve(t, s, app, "read"), testRedirect, testVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.ExchangeCode(app, approve(t, s, app, "read"), testRedirect, testVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the access token goes alone, the refresh token takes its grant along
+	s.Revoke(app, first.AccessToken)
+	if _, err = s.Authenticate(context.Background(), first.AccessToken); !errors.Is(err, session.ErrNoAuth) {
+		t.Errorf("revoked access token: error = %v, want ErrNoAuth", err)
+	}
+	if _, err = s.Refresh(app, first.RefreshToken); err != nil {
+		t.Errorf("refresh after the access token is revoked: %v", err)
+	}
+	s.Revoke(app, second.RefreshToken)
+	if _, err = s.Authenticate(context.Background(), second.AccessToken); !errors.Is(err, session.ErrNoAuth) {
+		t.Errorf("access token of the revoked grant: error = %v, want ErrNoAuth", err)
+	}
+
+	// other apps can't revoke the tokens, unknown tokens are ignored
+	other, _, err := s.RegisterApp("carol", "other", []string{testRedirect}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := s.ExchangeCode(app, approve(t, s, app, "read"), testRedirect, testVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Revoke(other, third.RefreshToken)
+	s.Revoke(app, "unknown")
+	if _, err = s.Authenticate(context.Background(), third.AccessToken); err != nil {
+		t.Errorf("token revoked by another app: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/user"
+	"regexp"
+	"strings"
+)
+
+var loginChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Linker finds the account of the external user, links the existing one
+// with the same verified email, or registers a new one
+type Linker struct {
+	Users user.UserRepo
+}
+
+func NewLinker(users user.UserRepo) *Linker {
+	return &Linker{Users: users}
+}
+
+func (l *Linker) Resolve(ctx context.Context, claims Claims) (user.User, error) {
+	usr, err := l.Users.GetByIdentity(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		return usr, nil
+	}
+	if !errors.Is(err, user.ErrNoUser) {
+		return user.User{}, err
+	}
+	if claims.Email != "" && claims.EmailVerified {
+		usr, err = l.Users.GetByEmail(ctx, claims.Email)
+		//only the verified emails prove it's the same person
+		if err == nil && usr.EmailVerified {
+			return usr, l.Users.LinkIdentity(ctx, usr.Login, claims.Issuer, claims.Subject)
+		}
+	}
+	return l.provision(ctx, claims)
+}
+
+func (l *Linker) provision(ctx context.Context, claims Claims) (user.User, error) {
+	password, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return user.User{}, err
+	}
+	base := baseLogin(claims)
+	var usr user.User
+	for i := 0; ; i++ {
+		login := base
+		if i > 0 {
+			login = fmt.Sprintf("%s%d", base, i)
+		}
+		//the random password is never told, the user logs in with the provider or resets it
+		usr, err = l.Users.Register(ctx, login, fmt.Sprintf("%x", password))
+		if !errors.Is(err, user.ErrExistUser) {
+			break
+		}
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+	if claims.Email != "" {
+		if _, err = l.Users.GetByEmail(ctx, claims.Email); errors.Is(err, user.ErrNoUser) {
+			if err = l.Users.SetEmail(ctx, usr.Login, claims.Email); err == nil {
+				usr.Email = claims.Email
+				if claims.EmailVerified && l.Users.SetEmailVerified(ctx, usr.Login, claims.Email) == nil {
+					usr.EmailVerified = true
+				}
+			}
+		}
+	}
+	return usr, l.Users.LinkIdentity(ctx, usr.Login, claims.Issuer, claims.Subject)
+}
+
+func baseLogin(claims Claims) string {
+	for _, candidate := range []string{claims.PreferredUsername, strings.Split(claims.Email, "@")[0]} {
+		login := loginChars.ReplaceAllString(candidate, "")
+		if login != "" {
+			return login
+		}
+	}
+	return "user"
+}
@@ -0,0 +1,150 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+
+	"myredditclone/pkg/user"
+)
+
+const issuer = "https://idp.example.com"
+
+func register(t *testing.T, users user.UserRepo, login, email string, verified bool) user.User {
+	t.Helper()
+	ctx := context.Background()
+	usr, err := users.Register(ctx, login, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email == "" {
+		return usr
+	}
+	if err := users.SetEmail(ctx, login, email); err != nil {
+		t.Fatal(err)
+	}
+	if verified {
+		if err := users.SetEmailVerified(ctx, login, email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return usr
+}
+
+func TestResolveLinkedIdentity(t *testing.T) {
+	ctx := context.Background()
+	users := user.NewUserRepository()
+	alice := register(t, users, "alice", "", false)
+	if err := users.LinkIdentity(ctx, "alice", issuer, "sub-1"); err != nil {
+		t.Fatal(err)
+	}
+	usr, err := NewLinker(users).Resolve(ctx, Claims{Issuer: issuer, Subject: "sub-1", PreferredUsername: "someone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usr.ID != alice.ID {
+		t.Errorf("resolved %v, want alice", usr.Login)
+	}
+}
+
+func TestResolveLinksVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	users := user.NewUserRepository()
+	register(t, users, "alice", "alice@example.com", true)
+	linker := NewLinker(users)
+
+	claims := Claims{Issuer: issuer, Subject: "sub-1", Email: "alice@example.com", EmailVerified: true}
+	usr, err := linker.Resolve(ctx, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usr.Login != "alice" {
+		t.Fatalf("resolved %v, want alice", usr.Login)
+	}
+	linked, err := users.GetByIdentity(ctx, issuer, "sub-1")
+	if err != nil || linked.Login != "alice" {
+		t.Errorf("identity is linked to %q, %v", linked.Login, err)
+	}
+}
+
+func TestResolveDoesNotLinkUnverifiedEmail(t *testing.T) {
+	cases := []struct {
+		name          string
+		localVerified bool
+		idpVerified   bool
+	}{
+		{"unverified at the provider", true, false},
+		{"unverified locally", false, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			users := user.NewUserRepository()
+			register(t, users, "alice", "alice@example.com", c.localVerified)
+			claims := Claims{
+				Issuer:            issuer,
+				Subject:           "sub-1",
+				Email:             "alice@example.com",
+				EmailVerified:     c.idpVerified,
+				PreferredUsername: "alice",
+			}
+			usr, err := NewLinker(users).Resolve(ctx, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if usr.Login == "alice" {
+				t.Fatal("the account was taken over by the email")
+			}
+			if usr.Login != "alice1" {
+				t.Errorf("provisioned %q, want alice1", usr.Login)
+			}
+			// the email stays with its owner
+			if usr.Email != "" {
+				t.Errorf("provisioned user got the email %q", usr.Email)
+			}
+		})
+	}
+}
+
+func TestResolveProvisions(t *testing.T) {
+	ctx := context.Background()
+	users := user.NewUserRepository()
+	linker := NewLinker(users)
+	claims := Claims{
+		Issuer:            issuer,
+		Subject:           "sub-1",
+		Email:             "bob@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "Bob Smith!",
+	}
+	usr, err := linker.Resolve(ctx, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usr.Login != "BobSmith" || usr.Email != "bob@example.com" || !usr.EmailVerified {
+		t.Errorf("provisioned %+v", usr)
+	}
+	again, err := linker.Resolve(ctx, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != usr.ID {
+		t.Errorf("second login provisioned %v again", again.Login)
+	}
+}
+
+func TestBaseLogin(t *testing.T) {
+	cases := []struct {
+		claims Claims
+		want   string
+	}{
+		{Claims{PreferredUsername: "jane_doe", Email: "jd@example.com"}, "jane_doe"},
+		{Claims{PreferredUsername: "!!!", Email: "j.d@example.com"}, "jd"},
+		{Claims{Email: "@example.com"}, "user"},
+		{Claims{}, "user"},
+	}
+	for _, c := range cases {
+		if got := baseLogin(c.claims); got != c.want {
+			t.Errorf("baseLogin(%+v) = %q, want %q", c.claims, got, c.want)
+		}
+	}
+}
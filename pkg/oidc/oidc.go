@@ -0,0 +1,151 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/hashicorp/go-uuid"
+	"golang.org/x/oauth2"
+	"myredditclone/pkg/apperrors"
+	"sync"
+	"time"
+)
+
+const StateTTL = 10 * time.Minute
+
+var (
+	ErrBadState = apperrors.Validation("state", "", "The login attempt is unknown or expired, start it again")
+	ErrIDToken  = apperrors.New(apperrors.ErrUnauthenticated, "The identity provider's answer can't be trusted")
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string //our callback
+}
+
+// Claims are the ID token claims used to find or provision the account
+type Claims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+type state struct {
+	Nonce    string
+	Verifier string
+	Expires  time.Time
+}
+
+// RelyingParty does the authorization code flow with PKCE against the
+// provider, discovered lazily so the server starts while it's unreachable
+type RelyingParty struct {
+	Config Config
+
+	provider *gooidc.Provider
+	states   map[string]state
+	mu       sync.Mutex
+}
+
+func NewRelyingParty(config Config) *RelyingParty {
+	return &RelyingParty{
+		Config: config,
+		states: map[string]state{},
+	}
+}
+
+func (rp *RelyingParty) discover(ctx context.Context) (*gooidc.Provider, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if rp.provider != nil {
+		return rp.provider, nil
+	}
+	provider, err := gooidc.NewProvider(ctx, rp.Config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	rp.provider = provider
+	return provider, nil
+}
+
+func (rp *RelyingParty) oauth2Config(provider *gooidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     rp.Config.ClientID,
+		ClientSecret: rp.Config.ClientSecret,
+		RedirectURL:  rp.Config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       []string{gooidc.ScopeOpenID, "email", "profile"},
+	}
+}
+
+func randomHex() (string, error) {
+	b, err := uuid.GenerateRandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
+
+// AuthURL is where to send the user to log in at the provider
+func (rp *RelyingParty) AuthURL(ctx context.Context) (string, error) {
+	provider, err := rp.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	st, err := randomHex()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomHex()
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+	now := time.Now()
+	rp.mu.Lock()
+	for k, v := range rp.states {
+		if now.After(v.Expires) {
+			delete(rp.states, k)
+		}
+	}
+	rp.states[st] = state{Nonce: nonce, Verifier: verifier, Expires: now.Add(StateTTL)}
+	rp.mu.Unlock()
+	return rp.oauth2Config(provider).AuthCodeURL(st, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange trades the code for the ID token and returns its verified claims
+func (rp *RelyingParty) Exchange(ctx context.Context, st, code string) (Claims, error) {
+	rp.mu.Lock()
+	s, ok := rp.states[st]
+	delete(rp.states, st)
+	rp.mu.Unlock()
+	if !ok || time.Now().After(s.Expires) {
+		return Claims{}, ErrBadState
+	}
+	provider, err := rp.discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	token, err := rp.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(s.Verifier))
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc code exchange: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Claims{}, ErrIDToken
+	}
+	idToken, err := provider.Verifier(&gooidc.Config{ClientID: rp.Config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil || idToken.Nonce != s.Nonce {
+		return Claims{}, ErrIDToken
+	}
+	claims := Claims{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return Claims{}, ErrIDToken
+	}
+	return claims, nil
+}
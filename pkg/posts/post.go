@@ -1,22 +1,37 @@
 package posts
 
-import "myredditclone/pkg/session"
+import (
+	"context"
+	"myredditclone/pkg/session"
+)
 
 type Author struct {
 	Username string `json:"username"`
 	ID       string `json:"id"`
 }
 
+// DeletedAuthor replaces the author of the content left by a deleted account
+var DeletedAuthor = Author{Username: "[deleted]"}
+
 type Vote struct {
 	User string `json:"user"` //UserID
 	Vote int8   `json:"vote"`
 }
 
 type Comment struct {
-	Created string `json:"created"`
-	Author  Author `json:"author"`
-	Body    string `json:"body"` //Comment
-	ID      string `json:"id"`
+	Created  string `json:"created"`
+	Author   Author `json:"author"`
+	Body     string `json:"body"` //Comment
+	ID       string `json:"id"`
+	ParentID string `json:"parentId,omitempty"`
+}
+
+// UserComment is a comment shown outside of its post
+type UserComment struct {
+	Comment
+	PostID    string `json:"postId"`
+	PostTitle string `json:"postTitle"`
+	Category  string `json:"category"`
 }
 
 type Post struct {
@@ -28,6 +43,7 @@ type Post struct {
 	Category         string          `json:"category"`
 	Text             string          `json:"text,omitempty"`
 	URL              string          `json:"url,omitempty"`
+	NSFW             bool            `json:"nsfw"`
 	VotesFromDB      map[string]Vote `json:"-"`
 	Votes            []Vote          `json:"votes"`
 	Comments         []Comment       `json:"comments"`
@@ -38,12 +54,13 @@ type Post struct {
 }
 
 type PostRepo interface {
-	GetAll() ([]Post, error)
-	GetByID(id string) (Post, error)
-	Add(item *Post) (uint64, error)
-	AddComment(postID, newCom string, sess session.Session) (Post, error)
-	DeleteComment(postID, newCom string, sess session.Session) (Post, error)
-	Vote(postID, userID string, newVote int8) (Post, error)
-	Update(newItem Post) error
-	Delete(id string) error
+	GetAll(ctx context.Context) ([]Post, error)
+	GetByID(ctx context.Context, id string) (Post, error)
+	Add(ctx context.Context, item *Post) (uint64, error)
+	AddComment(ctx context.Context, postID, parentID, newCom string, sess session.Session) (Post, error)
+	DeleteComment(ctx context.Context, postID, newCom string, sess session.Session) (Post, error)
+	Vote(ctx context.Context, postID, userID string, newVote int8) (Post, error)
+	Update(ctx context.Context, newItem Post) error
+	Delete(ctx context.Context, id string) error
+	AnonymizeAuthor(ctx context.Context, login string) error
 }
@@ -1,9 +1,10 @@
 package posts
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/apperrors"
 	"myredditclone/pkg/session"
 	"strconv"
 	"sync"
@@ -12,7 +13,10 @@ import (
 )
 
 var (
-	ErrRecordNotFound = errors.New("Current record doesn't exist")
+	ErrRecordNotFound   = apperrors.New(apperrors.ErrNotFound, "Current record doesn't exist")
+	ErrNoComment        = apperrors.New(apperrors.ErrNotFound, "Current comment doesn't exist")
+	ErrNoVote           = apperrors.New(apperrors.ErrNotFound, "Current vote doesn't exist")
+	ErrNotCommentAuthor = apperrors.New(apperrors.ErrForbidden, "The comment was not deleted by its creator")
 )
 
 var _ PostRepo = NewPostMemoryRepository()
@@ -37,11 +41,13 @@ func MapToSlice[K comparable, V any](m map[K]V) []V {
 	return s
 }
 
-func (repo *PostMemoryRepository) GetAll() ([]Post, error) {
+func (repo *PostMemoryRepository) GetAll(ctx context.Context) ([]Post, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
 	return MapToSlice(repo.data), nil
 }
 
-func (repo *PostMemoryRepository) GetByID(id string) (Post, error) {
+func (repo *PostMemoryRepository) GetByID(ctx context.Context, id string) (Post, error) {
 	repo.mu.RLock()
 	defer repo.mu.RUnlock()
 	post, ok := repo.data[id]
@@ -51,7 +57,7 @@ func (repo *PostMemoryRepository) GetByID(id string) (Post, error) {
 	return post, nil
 }
 
-func (repo *PostMemoryRepository) Add(item *Post) (lastID uint64, err error) {
+func (repo *PostMemoryRepository) Add(ctx context.Context, item *Post) (lastID uint64, err error) {
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
 	atomic.AddUint64(&repo.lastID, 1)
@@ -60,7 +66,7 @@ func (repo *PostMemoryRepository) Add(item *Post) (lastID uint64, err error) {
 	return repo.lastID, nil
 }
 
-func (repo *PostMemoryRepository) Update(newPost Post) error {
+func (repo *PostMemoryRepository) Update(ctx context.Context, newPost Post) error {
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
 	_, ok := repo.data[newPost.ID]
@@ -71,7 +77,7 @@ func (repo *PostMemoryRepository) Update(newPost Post) error {
 	return nil
 }
 
-func (repo *PostMemoryRepository) Delete(id string) error {
+func (repo *PostMemoryRepository) Delete(ctx context.Context, id string) error {
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
 	_, ok := repo.data[id]
@@ -82,16 +88,54 @@ func (repo *PostMemoryRepository) Delete(id string) error {
 	return nil
 }
 
-func (repo *PostMemoryRepository) AddComment(postID string, newCommentBody string, sess session.Session) (Post, error) {
+// AnonymizeAuthor replaces the user with DeletedAuthor in all posts and comments
+func (repo *PostMemoryRepository) AnonymizeAuthor(ctx context.Context, login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for id, post := range repo.data {
+		changed := false
+		if post.Author.Username == login {
+			post.Author = DeletedAuthor
+			changed = true
+		}
+		comments := make([]Comment, len(post.Comments))
+		for i, comm := range post.Comments {
+			if comm.Author.Username == login {
+				comm.Author = DeletedAuthor
+				changed = true
+			}
+			comments[i] = comm
+		}
+		if changed {
+			post.Comments = comments
+			repo.data[id] = post
+		}
+	}
+	return nil
+}
+
+func (repo *PostMemoryRepository) AddComment(ctx context.Context, postID, parentID, newCommentBody string, sess session.Session) (Post, error) {
 	repo.mu.RLock()
 	post, ok := repo.data[postID]
 	repo.mu.RUnlock()
 	if !ok {
 		return Post{}, ErrRecordNotFound
 	}
+	if parentID != "" {
+		parentExist := false
+		for _, com := range post.Comments {
+			if com.ID == parentID {
+				parentExist = true
+				break
+			}
+		}
+		if !parentExist {
+			return Post{}, ErrNoComment
+		}
+	}
 	randomID, err := uuid.GenerateRandomBytes(16)
 	if err != nil {
-		return Post{}, ErrRecordNotFound
+		return Post{}, err
 	}
 	comm := Comment{
 		Created: time.Now().Format("2006-01-02T15:04:05.000"),
@@ -99,8 +143,9 @@ func (repo *PostMemoryRepository) AddComment(postID string, newCommentBody strin
 			Username: sess.Login,
 			ID:       strconv.FormatUint(sess.UserID, 10),
 		},
-		Body: newCommentBody,
-		ID:   fmt.Sprintf("%x", randomID),
+		Body:     newCommentBody,
+		ID:       fmt.Sprintf("%x", randomID),
+		ParentID: parentID,
 	}
 	post.Comments = append(post.Comments, comm)
 	repo.mu.Lock()
@@ -110,7 +155,7 @@ func (repo *PostMemoryRepository) AddComment(postID string, newCommentBody strin
 	return post, nil
 }
 
-func (repo *PostMemoryRepository) DeleteComment(postID string, commID string, sess session.Session) (Post, error) {
+func (repo *PostMemoryRepository) DeleteComment(ctx context.Context, postID string, commID string, sess session.Session) (Post, error) {
 	repo.mu.RLock()
 	post, ok := repo.data[postID]
 	repo.mu.RUnlock()
@@ -118,20 +163,24 @@ func (repo *PostMemoryRepository) DeleteComment(postID string, commID string, se
 		return Post{}, ErrRecordNotFound
 	}
 	for i, com := range post.Comments {
-		if com.ID == commID && com.Author.Username == sess.Login && com.Author.ID == strconv.FormatUint(sess.UserID, 10) {
-			post.Comments[i] = post.Comments[len(post.Comments)-1]
-			post.Comments = post.Comments[:len(post.Comments)-1]
-			repo.mu.Lock()
-			repo.data[postID] = post
-			repo.mu.Unlock()
-			post.Votes = MapToSlice(post.VotesFromDB)
-			return post, nil
+		if com.ID != commID {
+			continue
+		}
+		if com.Author.Username != sess.Login || com.Author.ID != strconv.FormatUint(sess.UserID, 10) {
+			return Post{}, ErrNotCommentAuthor
 		}
+		post.Comments[i] = post.Comments[len(post.Comments)-1]
+		post.Comments = post.Comments[:len(post.Comments)-1]
+		repo.mu.Lock()
+		repo.data[postID] = post
+		repo.mu.Unlock()
+		post.Votes = MapToSlice(post.VotesFromDB)
+		return post, nil
 	}
-	return Post{}, ErrRecordNotFound
+	return Post{}, ErrNoComment
 }
 
-func (repo *PostMemoryRepository) Vote(postID, userID string, newVote int8) (Post, error) {
+func (repo *PostMemoryRepository) Vote(ctx context.Context, postID, userID string, newVote int8) (Post, error) {
 	repo.mu.RLock()
 	post, ok := repo.data[postID]
 	repo.mu.RUnlock()
@@ -157,7 +206,7 @@ func (repo *PostMemoryRepository) Vote(postID, userID string, newVote int8) (Pos
 		}
 	} else {
 		if !isVoteExist {
-			return Post{}, ErrRecordNotFound
+			return Post{}, ErrNoVote
 		}
 		delete(post.VotesFromDB, userID)
 	}
@@ -0,0 +1,289 @@
+package posts
+
+import (
+	"context"
+	"github.com/asaskevich/govalidator"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotAuthor   = apperrors.New(apperrors.ErrForbidden, "The post was not deleted by its creator")
+	ErrBlocked     = apperrors.New(apperrors.ErrForbidden, "The author has blocked you")
+	ErrUnknownVote = apperrors.Validation("vote", "", "The vote type wasn't sent")
+	ErrUnverified  = apperrors.New(apperrors.ErrForbidden, "Verify your email to post in this category")
+)
+
+var tracer = otel.Tracer("myredditclone/pkg/posts")
+
+// PostService keeps the business rules of posts, comments and votes
+type PostService struct {
+	Repo         PostRepo
+	Blocks       blocks.BlockRepo
+	Users        user.UserRepo
+	VerifiedOnly map[string]bool //categories only the users with verified emails can post in
+}
+
+func NewPostService(repo PostRepo, blockRepo blocks.BlockRepo) *PostService {
+	return &PostService{
+		Repo:   repo,
+		Blocks: blockRepo,
+	}
+}
+
+func SortSlicePosts(elems []Post) []Post {
+	sort.Slice(elems, func(i, j int) bool {
+		if elems[i].Score == elems[j].Score {
+			return elems[i].Created < elems[j].Created
+		}
+		return elems[i].Score > elems[j].Score
+	})
+	return elems
+}
+
+// Sort is SortSlicePostsBy in a span, to tell slow sorting from slow storage
+func Sort(ctx context.Context, elems []Post, mode string) []Post {
+	_, span := tracer.Start(ctx, "posts.Sort", trace.WithAttributes(
+		attribute.Int("posts.count", len(elems)),
+		attribute.String("posts.sort", mode),
+	))
+	defer span.End()
+	return SortSlicePostsBy(elems, mode)
+}
+
+// SortSlicePostsBy orders posts by one of the user.Sort* modes, by score otherwise
+func SortSlicePostsBy(elems []Post, mode string) []Post {
+	if mode != "new" {
+		return SortSlicePosts(elems)
+	}
+	sort.Slice(elems, func(i, j int) bool {
+		return elems[i].Created > elems[j].Created
+	})
+	return elems
+}
+
+func Validate(post Post) error {
+	if post.URL != "" && post.Text != "" {
+		return apperrors.Validation("urlAndText", post.URL+post.Text, "data was obtained simultaneously with two types of posts - containing links and text")
+	}
+	if post.URL == "" && post.Text == "" {
+		return apperrors.Validation("noUrlAndNoText", "", "it was not specified what type of data came in")
+	}
+	if post.URL != "" {
+		isURL := govalidator.IsURL(post.URL)
+		if !isURL {
+			return apperrors.Validation("URL", post.URL, "URL is not valid")
+		}
+	}
+	return nil
+}
+
+func AddDefaultFieldsPost(post *Post, sess session.Session) {
+	post.Score = 1
+	post.UpvoteNum = 1
+	post.Author.ID = strconv.FormatUint(sess.UserID, 10)
+	post.Author.Username = sess.Login
+	post.VotesFromDB = make(map[string]Vote)
+	post.VotesFromDB[post.Author.ID] = Vote{User: post.Author.ID, Vote: 1}
+	post.UpvotePercentage = 100
+	post.Created = time.Now().Format("2006-01-02T15:04:05.000")
+}
+
+func ParseVote(strVote string) (int8, error) {
+	switch strVote {
+	case "upvote":
+		return 1, nil
+	case "downvote":
+		return -1, nil
+	case "unvote":
+		return 0, nil
+	}
+	return 0, ErrUnknownVote
+}
+
+// filter returns sorted posts matching the condition, ready to be sent
+func (s *PostService) filter(ctx context.Context, match func(post Post) bool) ([]Post, error) {
+	elems, err := s.Repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	needElems := make([]Post, 0, len(elems))
+	for _, v := range elems {
+		if match(v) {
+			v.Votes = MapToSlice(v.VotesFromDB)
+			needElems = append(needElems, v)
+		}
+	}
+	return Sort(ctx, needElems, user.SortTop), nil
+}
+
+func (s *PostService) List(ctx context.Context) ([]Post, error) {
+	return s.filter(ctx, func(post Post) bool {
+		return true
+	})
+}
+
+func (s *PostService) ListByCategory(ctx context.Context, category string) ([]Post, error) {
+	return s.filter(ctx, func(post Post) bool {
+		return post.Category == category
+	})
+}
+
+func (s *PostService) ListByAuthor(ctx context.Context, login string) ([]Post, error) {
+	return s.filter(ctx, func(post Post) bool {
+		return post.Author.Username == login
+	})
+}
+
+// ListCommentsByAuthor returns the user's comments across all posts, newest first
+func (s *PostService) ListCommentsByAuthor(ctx context.Context, login string) ([]UserComment, error) {
+	elems, err := s.Repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]UserComment, 0)
+	for _, post := range elems {
+		for _, comm := range post.Comments {
+			if comm.Author.Username == login {
+				res = append(res, UserComment{
+					Comment:   comm,
+					PostID:    post.ID,
+					PostTitle: post.Title,
+					Category:  post.Category,
+				})
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Created > res[j].Created
+	})
+	return res, nil
+}
+
+func (s *PostService) Get(ctx context.Context, postID string) (Post, error) {
+	post, err := s.Repo.GetByID(ctx, postID)
+	if err != nil {
+		return Post{}, err
+	}
+	post.Votes = MapToSlice(post.VotesFromDB)
+	return post, nil
+}
+
+func (s *PostService) GetComment(ctx context.Context, postID, commentID string) (Comment, error) {
+	post, err := s.Repo.GetByID(ctx, postID)
+	if err != nil {
+		return Comment{}, err
+	}
+	for _, comm := range post.Comments {
+		if comm.ID == commentID {
+			return comm, nil
+		}
+	}
+	return Comment{}, ErrNoComment
+}
+
+// View returns the post and counts the view
+func (s *PostService) View(ctx context.Context, postID string) (Post, error) {
+	post, err := s.Repo.GetByID(ctx, postID)
+	if err != nil {
+		return Post{}, err
+	}
+	post.Views++
+	err = s.Repo.Update(ctx, post)
+	if err != nil {
+		return Post{}, err
+	}
+	post.Votes = MapToSlice(post.VotesFromDB)
+	return post, nil
+}
+
+// RequireVerified restricts posting in the categories to the users with verified emails
+func (s *PostService) RequireVerified(users user.UserRepo, categories ...string) {
+	s.Users = users
+	s.VerifiedOnly = make(map[string]bool, len(categories))
+	for _, category := range categories {
+		s.VerifiedOnly[category] = true
+	}
+}
+
+func (s *PostService) CreatePost(ctx context.Context, post *Post, sess session.Session) (uint64, error) {
+	err := Validate(*post)
+	if err != nil {
+		return 0, err
+	}
+	if s.VerifiedOnly[post.Category] {
+		usr, err := s.Users.GetByLogin(ctx, sess.Login)
+		if err != nil {
+			return 0, err
+		}
+		if !usr.EmailVerified {
+			return 0, ErrUnverified
+		}
+	}
+	AddDefaultFieldsPost(post, sess)
+	lastID, err := s.Repo.Add(ctx, post)
+	if err != nil {
+		return 0, err
+	}
+	post.Votes = MapToSlice(post.VotesFromDB)
+	return lastID, nil
+}
+
+func (s *PostService) DeletePost(ctx context.Context, postID string, sess session.Session) error {
+	post, err := s.Repo.GetByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if strconv.FormatUint(sess.UserID, 10) != post.Author.ID {
+		return ErrNotAuthor
+	}
+	return s.Repo.Delete(ctx, postID)
+}
+
+// AnonymizeAuthor detaches the content from the deleted account
+func (s *PostService) AnonymizeAuthor(ctx context.Context, login string) error {
+	return s.Repo.AnonymizeAuthor(ctx, login)
+}
+
+// AddComment refuses replies of the users blocked by the author of the post
+// or of the parent comment
+func (s *PostService) AddComment(ctx context.Context, postID, parentID, body string, sess session.Session) (Post, error) {
+	post, err := s.Repo.GetByID(ctx, postID)
+	if err != nil {
+		return Post{}, err
+	}
+	repliedTo := []string{post.Author.Username}
+	if parentID != "" {
+		parent, err := s.GetComment(ctx, postID, parentID)
+		if err != nil {
+			return Post{}, err
+		}
+		repliedTo = append(repliedTo, parent.Author.Username)
+	}
+	for _, login := range repliedTo {
+		isBlocked, err := s.Blocks.IsBlocked(login, sess.Login)
+		if err != nil {
+			return Post{}, err
+		}
+		if isBlocked {
+			return Post{}, ErrBlocked
+		}
+	}
+	return s.Repo.AddComment(ctx, postID, parentID, body, sess)
+}
+
+func (s *PostService) DeleteComment(ctx context.Context, postID, commID string, sess session.Session) (Post, error) {
+	return s.Repo.DeleteComment(ctx, postID, commID, sess)
+}
+
+func (s *PostService) Vote(ctx context.Context, postID string, sess session.Session, newVote int8) (Post, error) {
+	return s.Repo.Vote(ctx, postID, strconv.FormatUint(sess.UserID, 10), newVote)
+}
@@ -0,0 +1,263 @@
+package posts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+)
+
+var (
+	alice = session.Session{UserID: 1, Login: "alice"}
+	bob   = session.Session{UserID: 2, Login: "bob"}
+)
+
+func newService() *PostService {
+	return NewPostService(NewPostMemoryRepository(), blocks.NewBlockMemoryRepository())
+}
+
+func create(t *testing.T, s *PostService, post Post, sess session.Session) Post {
+	t.Helper()
+	if _, err := s.CreatePost(context.Background(), &post, sess); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	return post
+}
+
+func TestCreatePost(t *testing.T) {
+	s := newService()
+	post := create(t, s, Post{Title: "hello", Category: "music", Type: "text", Text: "body"}, alice)
+
+	if post.ID == "" {
+		t.Fatal("post has no ID")
+	}
+	if post.Author.Username != "alice" || post.Author.ID != "1" {
+		t.Errorf("author = %+v, want alice/1", post.Author)
+	}
+	if post.Score != 1 || post.UpvotePercentage != 100 || len(post.Votes) != 1 {
+		t.Errorf("score %v, upvotes %v%%, votes %v: want the author's upvote", post.Score, post.UpvotePercentage, post.Votes)
+	}
+	got, err := s.Get(context.Background(), post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "hello" {
+		t.Errorf("stored title = %q", got.Title)
+	}
+}
+
+func TestCreatePostValidation(t *testing.T) {
+	cases := map[string]Post{
+		"urlAndText":     {URL: "https://example.com", Text: "body"},
+		"noUrlAndNoText": {},
+		"URL":            {URL: "not a url"},
+	}
+	s := newService()
+	for param, post := range cases {
+		_, err := s.CreatePost(context.Background(), &post, alice)
+		var appErr *apperrors.Error
+		if !errors.As(err, &appErr) || appErr.Kind != apperrors.ErrValidation || appErr.Param != param {
+			t.Errorf("%v: error = %v, want validation of %v", param, err, param)
+		}
+	}
+}
+
+func TestCreatePostRequiresVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	users := user.NewUserRepository()
+	usr, err := users.Register(ctx, "alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := session.Session{UserID: usr.ID, Login: usr.Login}
+	s := newService()
+	s.RequireVerified(users, "news")
+
+	_, err = s.CreatePost(ctx, &Post{Category: "news", Text: "body"}, sess)
+	if !errors.Is(err, ErrUnverified) {
+		t.Fatalf("unverified user: error = %v, want ErrUnverified", err)
+	}
+	create(t, s, Post{Category: "music", Text: "body"}, sess)
+
+	if err := users.SetEmail(ctx, "alice", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SetEmailVerified(ctx, "alice", "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	create(t, s, Post{Category: "news", Text: "body"}, sess)
+}
+
+func TestDeletePost(t *testing.T) {
+	ctx := context.Background()
+	s := newService()
+	post := create(t, s, Post{Category: "music", Text: "body"}, alice)
+
+	if err := s.DeletePost(ctx, post.ID, bob); !errors.Is(err, ErrNotAuthor) {
+		t.Fatalf("delete by another user: error = %v, want ErrNotAuthor", err)
+	}
+	if !errors.Is(ErrNotAuthor, apperrors.ErrForbidden) {
+		t.Error("ErrNotAuthor is not forbidden")
+	}
+	if err := s.DeletePost(ctx, post.ID, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, post.ID); !errors.Is(err, apperrors.ErrNotFound) {
+		t.Errorf("deleted post: error = %v, want not found", err)
+	}
+	if err := s.DeletePost(ctx, post.ID, alice); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("second delete: error = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestVote(t *testing.T) {
+	ctx := context.Background()
+	s := newService()
+	post := create(t, s, Post{Category: "music", Text: "body"}, alice)
+
+	steps := []struct {
+		sess    session.Session
+		vote    int8
+		score   int64
+		percent uint8
+	}{
+		{bob, -1, 0, 50},
+		{bob, 1, 2, 100},
+		{bob, 0, 1, 100},
+		{alice, 0, 0, 0},
+	}
+	for _, step := range steps {
+		got, err := s.Vote(ctx, post.ID, step.sess, step.vote)
+		if err != nil {
+			t.Fatalf("%v votes %v: %v", step.sess.Login, step.vote, err)
+		}
+		if got.Score != step.score || got.UpvotePercentage != step.percent {
+			t.Errorf("%v votes %v: score %v, upvotes %v%%, want %v, %v%%",
+				step.sess.Login, step.vote, got.Score, got.UpvotePercentage, step.score, step.percent)
+		}
+	}
+	if _, err := s.Vote(ctx, post.ID, bob, 0); !errors.Is(err, ErrNoVote) {
+		t.Errorf("unvote without a vote: error = %v, want ErrNoVote", err)
+	}
+	if _, err := s.Vote(ctx, "missing", bob, 1); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("vote on a missing post: error = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestListing(t *testing.T) {
+	ctx := context.Background()
+	s := newService()
+	music := create(t, s, Post{Category: "music", Text: "alice's"}, alice)
+	news := create(t, s, Post{Category: "news", Text: "bob's"}, bob)
+	top := create(t, s, Post{Category: "music", Text: "bob's top"}, bob)
+	if _, err := s.Vote(ctx, top.ID, alice, 1); err != nil {
+		t.Fatal(err)
+	}
+	// the posts created within a millisecond tie by date, so the scores differ
+	if _, err := s.Vote(ctx, news.ID, alice, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(posts []Post, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := make([]string, len(posts))
+		for i, post := range posts {
+			res[i] = post.ID
+		}
+		return res
+	}
+	cases := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"all", ids(s.List(ctx)), []string{top.ID, music.ID, news.ID}},
+		{"category", ids(s.ListByCategory(ctx, "music")), []string{top.ID, music.ID}},
+		{"author", ids(s.ListByAuthor(ctx, "bob")), []string{top.ID, news.ID}},
+		{"empty", ids(s.ListByCategory(ctx, "funny")), []string{}},
+	}
+	for _, c := range cases {
+		if len(c.got) != len(c.want) {
+			t.Errorf("%v: got %v, want %v", c.name, c.got, c.want)
+			continue
+		}
+		for i := range c.want {
+			if c.got[i] != c.want[i] {
+				t.Errorf("%v: got %v, want %v", c.name, c.got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestView(t *testing.T) {
+	ctx := context.Background()
+	s := newService()
+	post := create(t, s, Post{Category: "music", Text: "body"}, alice)
+	for i := 1; i <= 3; i++ {
+		got, err := s.View(ctx, post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Views != uint64(i) {
+			t.Errorf("views = %v, want %v", got.Views, i)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	ctx := context.Background()
+	blockRepo := blocks.NewBlockMemoryRepository()
+	s := NewPostService(NewPostMemoryRepository(), blockRepo)
+	post := create(t, s, Post{Category: "music", Text: "body"}, alice)
+
+	got, err := s.AddComment(ctx, post.ID, "", "first", bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := got.Comments[0]
+	if _, err := s.AddComment(ctx, post.ID, "missing", "reply", bob); !errors.Is(err, ErrNoComment) {
+		t.Errorf("reply to a missing comment: error = %v, want ErrNoComment", err)
+	}
+	if _, err := s.DeleteComment(ctx, post.ID, first.ID, alice); !errors.Is(err, ErrNotCommentAuthor) {
+		t.Errorf("delete by another user: error = %v, want ErrNotCommentAuthor", err)
+	}
+
+	comments, err := s.ListCommentsByAuthor(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].PostID != post.ID || comments[0].Body != "first" {
+		t.Errorf("bob's comments = %+v", comments)
+	}
+
+	if err := blockRepo.Block("alice", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddComment(ctx, post.ID, "", "second", bob); !errors.Is(err, ErrBlocked) {
+		t.Errorf("comment of a blocked user: error = %v, want ErrBlocked", err)
+	}
+	// the block of the parent comment's author counts too
+	reply := create(t, s, Post{Category: "music", Text: "body"}, session.Session{UserID: 3, Login: "carol"})
+	got, err = s.AddComment(ctx, reply.ID, "", "by alice", alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddComment(ctx, reply.ID, got.Comments[0].ID, "reply", bob); !errors.Is(err, ErrBlocked) {
+		t.Errorf("reply to a blocking user: error = %v, want ErrBlocked", err)
+	}
+
+	if _, err := s.DeleteComment(ctx, post.ID, first.ID, bob); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetComment(ctx, post.ID, first.ID); !errors.Is(err, ErrNoComment) {
+		t.Errorf("deleted comment: error = %v, want ErrNoComment", err)
+	}
+}
@@ -0,0 +1,83 @@
+package profile
+
+import (
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/posts"
+	"sync"
+)
+
+// Tracker keeps karma up to date from the domain events.
+// The author's own upvote of a new post doesn't count, and deleting a post
+// or a comment takes back the karma it brought, so posting and deleting
+// can't farm karma
+type Tracker struct {
+	Repo   KarmaRepo
+	scores map[string]int64 //last seen score by post ID
+	mu     sync.Mutex
+}
+
+func NewTracker(repo KarmaRepo) *Tracker {
+	return &Tracker{
+		Repo:   repo,
+		scores: map[string]int64{},
+	}
+}
+
+// Handle is the domain events subscriber of the tracker
+func (t *Tracker) Handle(event events.Event) error {
+	switch e := event.(type) {
+	case events.PostCreated:
+		t.mu.Lock()
+		t.scores[e.Post.ID] = e.Post.Score
+		t.mu.Unlock()
+	case events.Voted:
+		t.mu.Lock()
+		last, ok := t.scores[e.Post.ID]
+		if !ok {
+			last = 1
+		}
+		t.scores[e.Post.ID] = e.Post.Score
+		t.mu.Unlock()
+		if delta := e.Post.Score - last; delta != 0 && !deleted(e.Post.Author) {
+			return t.Repo.AddPost(e.Post.Author.Username, delta)
+		}
+	case events.PostDeleted:
+		t.mu.Lock()
+		last, ok := t.scores[e.Post.ID]
+		if !ok {
+			last = e.Post.Score
+		}
+		delete(t.scores, e.Post.ID)
+		t.mu.Unlock()
+		if delta := 1 - last; delta != 0 && !deleted(e.Post.Author) {
+			err := t.Repo.AddPost(e.Post.Author.Username, delta)
+			if err != nil {
+				return err
+			}
+		}
+		for _, comm := range e.Post.Comments {
+			err := t.addComment(comm.Author, -1)
+			if err != nil {
+				return err
+			}
+		}
+	case events.CommentAdded:
+		//comments can't be voted yet, so each one brings its implicit own upvote
+		return t.addComment(e.Comment.Author, 1)
+	case events.CommentDeleted:
+		return t.addComment(e.Author, -1)
+	}
+	return nil
+}
+
+// deleted tells the content left by deleted accounts, it brings no karma
+func deleted(author posts.Author) bool {
+	return author.Username == "" || author == posts.DeletedAuthor
+}
+
+func (t *Tracker) addComment(author posts.Author, delta int64) error {
+	if deleted(author) {
+		return nil
+	}
+	return t.Repo.AddComment(author.Username, delta)
+}
@@ -0,0 +1,85 @@
+package profile
+
+import (
+	"testing"
+
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/posts"
+)
+
+var (
+	alice = posts.Author{Username: "alice", ID: "1"}
+	bob   = posts.Author{Username: "bob", ID: "2"}
+)
+
+func handle(t *testing.T, tracker *Tracker, evts ...events.Event) {
+	t.Helper()
+	for _, e := range evts {
+		if err := tracker.Handle(e); err != nil {
+			t.Fatalf("handle %v: %v", e.EventName(), err)
+		}
+	}
+}
+
+func wantKarma(t *testing.T, repo KarmaRepo, login string, want Karma) {
+	t.Helper()
+	got, err := repo.Get(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("karma of %v = %+v, want %+v", login, got, want)
+	}
+}
+
+func TestTrackerPostKarma(t *testing.T) {
+	repo := NewKarmaMemoryRepository()
+	tracker := NewTracker(repo)
+	post := posts.Post{ID: "1", Author: alice, Score: 1}
+	handle(t, tracker, events.PostCreated{Post: post})
+	wantKarma(t, repo, "alice", Karma{})
+
+	post.Score = 3
+	handle(t, tracker, events.Voted{Post: post, UserID: "2", Vote: 1})
+	post.Score = 2
+	handle(t, tracker, events.Voted{Post: post, UserID: "3", Vote: -1})
+	wantKarma(t, repo, "alice", Karma{Post: 1})
+
+	handle(t, tracker, events.PostDeleted{Post: post})
+	wantKarma(t, repo, "alice", Karma{})
+}
+
+func TestTrackerCommentKarma(t *testing.T) {
+	repo := NewKarmaMemoryRepository()
+	tracker := NewTracker(repo)
+	post := posts.Post{ID: "1", Author: alice, Score: 1}
+	first := posts.Comment{ID: "c1", Author: bob}
+	second := posts.Comment{ID: "c2", Author: bob}
+	handle(t, tracker,
+		events.PostCreated{Post: post},
+		events.CommentAdded{Post: post, Comment: first},
+		events.CommentAdded{Post: post, Comment: second},
+	)
+	wantKarma(t, repo, "bob", Karma{Comment: 2})
+
+	handle(t, tracker, events.CommentDeleted{Post: post, CommentID: first.ID, Author: bob})
+	wantKarma(t, repo, "bob", Karma{Comment: 1})
+
+	// the comments go with the post
+	post.Comments = []posts.Comment{second}
+	handle(t, tracker, events.PostDeleted{Post: post})
+	wantKarma(t, repo, "bob", Karma{})
+	wantKarma(t, repo, "alice", Karma{})
+}
+
+func TestTrackerDeletedAuthor(t *testing.T) {
+	repo := NewKarmaMemoryRepository()
+	tracker := NewTracker(repo)
+	post := posts.Post{ID: "1", Author: posts.DeletedAuthor, Score: 5}
+	handle(t, tracker,
+		events.Voted{Post: post, UserID: "2", Vote: 1},
+		events.CommentAdded{Post: post, Comment: posts.Comment{ID: "c1", Author: posts.DeletedAuthor}},
+		events.PostDeleted{Post: post},
+	)
+	wantKarma(t, repo, posts.DeletedAuthor.Username, Karma{})
+}
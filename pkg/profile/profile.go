@@ -0,0 +1,86 @@
+package profile
+
+import (
+	"fmt"
+	"myredditclone/pkg/user"
+	"time"
+)
+
+type Karma struct {
+	Post    int64 `json:"postKarma"`
+	Comment int64 `json:"commentKarma"`
+}
+
+type Trophy struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Profile struct {
+	Username     string   `json:"username"`
+	ID           string   `json:"id"`
+	Created      string   `json:"created"`
+	CakeDay      string   `json:"cakeDay"` //MM-DD
+	IsCakeDay    bool     `json:"isCakeDay"`
+	PostKarma    int64    `json:"postKarma"`
+	CommentKarma int64    `json:"commentKarma"`
+	TotalKarma   int64    `json:"totalKarma"`
+	Trophies     []Trophy `json:"trophies"`
+}
+
+type KarmaRepo interface {
+	Get(login string) (Karma, error)
+	AddPost(login string, delta int64) error
+	AddComment(login string, delta int64) error
+}
+
+var karmaMilestones = []int64{100, 1000, 10000, 100000}
+
+// Build assembles the public profile of the user at the moment now
+func Build(usr user.User, karma Karma, now time.Time) Profile {
+	res := Profile{
+		Username:     usr.Login,
+		ID:           fmt.Sprint(usr.ID),
+		Created:      usr.Created,
+		PostKarma:    karma.Post,
+		CommentKarma: karma.Comment,
+		TotalKarma:   karma.Post + karma.Comment,
+		Trophies:     []Trophy{},
+	}
+	if usr.EmailVerified {
+		res.Trophies = append(res.Trophies, Trophy{
+			Name:        "Verified Email",
+			Description: "Has confirmed the email of the account",
+		})
+	}
+	created, err := time.ParseInLocation("2006-01-02T15:04:05.000", usr.Created, now.Location())
+	if err != nil {
+		return res
+	}
+	res.CakeDay = created.Format("01-02")
+	res.IsCakeDay = res.CakeDay == now.Format("01-02") && now.Year() > created.Year()
+	if years := yearsBetween(created, now); years > 0 {
+		res.Trophies = append(res.Trophies, Trophy{
+			Name:        fmt.Sprintf("%d-Year Club", years),
+			Description: fmt.Sprintf("Has been a member for %d years", years),
+		})
+	}
+	for _, milestone := range karmaMilestones {
+		if res.TotalKarma >= milestone {
+			res.Trophies = append(res.Trophies, Trophy{
+				Name:        fmt.Sprintf("%d Karma", milestone),
+				Description: fmt.Sprintf("Earned at least %d karma", milestone),
+			})
+		}
+	}
+	return res
+}
+
+func yearsBetween(from, to time.Time) int {
+	years := to.Year() - from.Year()
+	// by the date, the day of the year shifts after February in leap years
+	if to.Month() < from.Month() || to.Month() == from.Month() && to.Day() < from.Day() {
+		years--
+	}
+	return years
+}
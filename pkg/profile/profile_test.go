@@ -0,0 +1,79 @@
+package profile
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"myredditclone/pkg/user"
+)
+
+func names(trophies []Trophy) []string {
+	res := make([]string, 0, len(trophies))
+	for _, trophy := range trophies {
+		res = append(res, trophy.Name)
+	}
+	return res
+}
+
+func TestBuild(t *testing.T) {
+	usr := user.User{ID: 7, Login: "alice", Created: "2020-03-15T10:00:00.000", EmailVerified: true}
+	now := time.Date(2024, time.January, 2, 12, 0, 0, 0, time.UTC)
+	got := Build(usr, Karma{Post: 900, Comment: 150}, now)
+
+	if got.Username != "alice" || got.ID != "7" || got.Created != usr.Created {
+		t.Errorf("profile = %+v", got)
+	}
+	if got.PostKarma != 900 || got.CommentKarma != 150 || got.TotalKarma != 1050 {
+		t.Errorf("karma = %v + %v = %v, want 900 + 150 = 1050", got.PostKarma, got.CommentKarma, got.TotalKarma)
+	}
+	want := []string{"Verified Email", "3-Year Club", "100 Karma", "1000 Karma"}
+	if !reflect.DeepEqual(names(got.Trophies), want) {
+		t.Errorf("trophies = %v, want %v", names(got.Trophies), want)
+	}
+}
+
+func TestBuildNewUser(t *testing.T) {
+	usr := user.User{Login: "bob", Created: "2024-01-02T10:00:00.000"}
+	got := Build(usr, Karma{Post: 99}, time.Date(2024, time.January, 2, 12, 0, 0, 0, time.UTC))
+	if got.Trophies == nil || len(got.Trophies) != 0 {
+		t.Errorf("trophies = %#v, want an empty list", got.Trophies)
+	}
+	// the day of the registration isn't a cake day
+	if got.CakeDay != "01-02" || got.IsCakeDay {
+		t.Errorf("cake day = %v, today %v", got.CakeDay, got.IsCakeDay)
+	}
+
+	got = Build(user.User{Login: "bob", Created: "garbage"}, Karma{}, time.Now())
+	if got.CakeDay != "" || got.IsCakeDay || len(got.Trophies) != 0 {
+		t.Errorf("profile with a bad date = %+v", got)
+	}
+}
+
+func TestCakeDay(t *testing.T) {
+	// registered in a leap year, after February
+	usr := user.User{Login: "alice", Created: "2020-03-15T10:00:00.000"}
+	cases := []struct {
+		now       time.Time
+		isCakeDay bool
+		club      string
+	}{
+		{time.Date(2021, time.March, 14, 23, 59, 0, 0, time.UTC), false, ""},
+		{time.Date(2021, time.March, 15, 0, 0, 0, 0, time.UTC), true, "1-Year Club"},
+		{time.Date(2021, time.March, 16, 0, 0, 0, 0, time.UTC), false, "1-Year Club"},
+		{time.Date(2025, time.March, 15, 9, 0, 0, 0, time.UTC), true, "5-Year Club"},
+	}
+	for _, c := range cases {
+		got := Build(usr, Karma{}, c.now)
+		if got.CakeDay != "03-15" || got.IsCakeDay != c.isCakeDay {
+			t.Errorf("%v: cake day %v, today %v, want 03-15, %v", c.now, got.CakeDay, got.IsCakeDay, c.isCakeDay)
+		}
+		var want []string
+		if c.club != "" {
+			want = []string{c.club}
+		}
+		if trophies := names(got.Trophies); len(trophies) != len(want) || len(want) == 1 && trophies[0] != want[0] {
+			t.Errorf("%v: trophies = %v, want %v", c.now, trophies, want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package profile
+
+import "sync"
+
+var _ KarmaRepo = NewKarmaMemoryRepository()
+
+type KarmaMemoryRepository struct {
+	data map[string]Karma
+	mu   sync.RWMutex
+}
+
+func NewKarmaMemoryRepository() *KarmaMemoryRepository {
+	return &KarmaMemoryRepository{
+		data: map[string]Karma{},
+	}
+}
+
+func (repo *KarmaMemoryRepository) Get(login string) (Karma, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.data[login], nil
+}
+
+func (repo *KarmaMemoryRepository) AddPost(login string, delta int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	karma := repo.data[login]
+	karma.Post += delta
+	repo.data[login] = karma
+	return nil
+}
+
+func (repo *KarmaMemoryRepository) AddComment(login string, delta int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	karma := repo.data[login]
+	karma.Comment += delta
+	repo.data[login] = karma
+	return nil
+}
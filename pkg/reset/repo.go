@@ -0,0 +1,82 @@
+package reset
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/apperrors"
+	"sync"
+	"time"
+)
+
+var ErrBadToken = apperrors.Validation("token", "", "The reset token is invalid or expired")
+
+var _ ResetRepo = NewResetMemoryRepository()
+
+type resetToken struct {
+	Login   string
+	Expires time.Time
+}
+
+// ResetMemoryRepository keeps only the hashes of the tokens
+type ResetMemoryRepository struct {
+	data map[string]resetToken //by token hash
+	mu   sync.Mutex
+}
+
+func NewResetMemoryRepository() *ResetMemoryRepository {
+	return &ResetMemoryRepository{
+		data: map[string]resetToken{},
+	}
+}
+
+func hash(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+func (repo *ResetMemoryRepository) Create(login string, ttl time.Duration) (string, error) {
+	tokenBytes, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	token := fmt.Sprintf("%x", tokenBytes)
+	now := time.Now()
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for h, t := range repo.data {
+		if t.Login == login || now.After(t.Expires) {
+			delete(repo.data, h)
+		}
+	}
+	repo.data[hash(token)] = resetToken{
+		Login:   login,
+		Expires: now.Add(ttl),
+	}
+	return token, nil
+}
+
+func (repo *ResetMemoryRepository) Consume(token string) (string, error) {
+	h := hash(token)
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	t, ok := repo.data[h]
+	if !ok {
+		return "", ErrBadToken
+	}
+	delete(repo.data, h)
+	if time.Now().After(t.Expires) {
+		return "", ErrBadToken
+	}
+	return t.Login, nil
+}
+
+func (repo *ResetMemoryRepository) Revoke(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for h, t := range repo.data {
+		if t.Login == login {
+			delete(repo.data, h)
+		}
+	}
+	return nil
+}
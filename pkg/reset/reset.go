@@ -0,0 +1,14 @@
+package reset
+
+import "time"
+
+var TokenTTL = time.Hour
+
+type ResetRepo interface {
+	// Create issues a new token for the user, revoking the previous ones
+	Create(login string, ttl time.Duration) (string, error)
+	// Consume returns the login the token was issued for and revokes it
+	Consume(token string) (string, error)
+	// Revoke drops the tokens of the user
+	Revoke(login string) error
+}
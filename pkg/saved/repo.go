@@ -0,0 +1,85 @@
+package saved
+
+import (
+	"myredditclone/pkg/apperrors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotSaved = apperrors.New(apperrors.ErrNotFound, "Current item isn't saved")
+)
+
+var _ SavedRepo = NewSavedMemoryRepository()
+
+type SavedMemoryRepository struct {
+	data map[string][]Item
+	mu   sync.RWMutex
+}
+
+func NewSavedMemoryRepository() *SavedMemoryRepository {
+	return &SavedMemoryRepository{
+		data: map[string][]Item{},
+	}
+}
+
+func (repo *SavedMemoryRepository) find(login, postID, commentID string) int {
+	for i, item := range repo.data[login] {
+		if item.PostID == postID && item.CommentID == commentID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Save is idempotent, saving the item twice keeps the first date
+func (repo *SavedMemoryRepository) Save(login, postID, commentID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.find(login, postID, commentID) != -1 {
+		return nil
+	}
+	repo.data[login] = append(repo.data[login], Item{
+		PostID:    postID,
+		CommentID: commentID,
+		Saved:     time.Now().Format("2006-01-02T15:04:05.000"),
+	})
+	return nil
+}
+
+func (repo *SavedMemoryRepository) Unsave(login, postID, commentID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	i := repo.find(login, postID, commentID)
+	if i == -1 {
+		return ErrNotSaved
+	}
+	items := repo.data[login]
+	repo.data[login] = append(items[:i], items[i+1:]...)
+	return nil
+}
+
+func (repo *SavedMemoryRepository) IsSaved(login, postID, commentID string) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	return repo.find(login, postID, commentID) != -1, nil
+}
+
+// GetByUser returns saved items, the last saved first
+func (repo *SavedMemoryRepository) GetByUser(login string) ([]Item, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	items := repo.data[login]
+	res := make([]Item, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		res = append(res, items[i])
+	}
+	return res, nil
+}
+
+func (repo *SavedMemoryRepository) DeleteByUser(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	delete(repo.data, login)
+	return nil
+}
@@ -0,0 +1,15 @@
+package saved
+
+type Item struct {
+	PostID    string `json:"postId"`
+	CommentID string `json:"commentId,omitempty"` //empty for saved posts
+	Saved     string `json:"saved"`
+}
+
+type SavedRepo interface {
+	Save(login, postID, commentID string) error
+	Unsave(login, postID, commentID string) error
+	IsSaved(login, postID, commentID string) (bool, error)
+	GetByUser(login string) ([]Item, error)
+	DeleteByUser(login string) error
+}
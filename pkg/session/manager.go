@@ -1,7 +1,11 @@
 package session
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
+	"github.com/hashicorp/go-uuid"
 	"myredditclone/pkg/user"
 	"net/http"
 	"strconv"
@@ -10,11 +14,26 @@ import (
 	"time"
 )
 
-var Key = []byte("osfhvjfblkvbke")
+// Key signs the JWTs, it's set from the config, no token is issued or
+// accepted without it
+var Key []byte
+
+var ErrNoKey = errors.New("session: the JWT key isn't set")
+
+// TokenTTL is the lifetime of the JWTs
+var TokenTTL = 30 * 24 * time.Hour
+
+// TokenAuthenticator checks the tokens that aren't JWTs
+type TokenAuthenticator interface {
+	Accepts(token string) bool
+	Authenticate(ctx context.Context, token string) (*Session, error)
+}
 
 type SessionsManager struct {
-	data map[string]*Session
-	mu   sync.RWMutex
+	Tokens []TokenAuthenticator //of the tokens issued besides the JWTs
+	Users  user.UserRepo        //the roles are read from it on every check, if set
+	data   map[string]*Session  //by session ID
+	mu     sync.RWMutex
 }
 
 func NewSessionManager() *SessionsManager {
@@ -23,14 +42,18 @@ func NewSessionManager() *SessionsManager {
 	}
 }
 
-func CreateNewToken(user user.User) (string, error) {
+func CreateNewToken(user user.User, sessID string) (string, error) {
+	if len(Key) == 0 {
+		return "", ErrNoKey
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user": map[string]interface{}{
 			"username": user.Login,
 			"id":       strconv.FormatUint(user.ID, 10),
 		},
+		"sid": sessID,
 		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(30 * 24 * time.Hour).Unix(),
+		"exp": time.Now().Add(TokenTTL).Unix(),
 	})
 	return token.SignedString(Key)
 }
@@ -41,9 +64,20 @@ func (sm *SessionsManager) Check(w http.ResponseWriter, r *http.Request) (*Sessi
 	if !ok {
 		return nil, ErrNoAuth
 	}
+	return sm.CheckToken(r.Context(), tokenString)
+}
+
+// CheckToken validates the token itself, for clients that can't send
+// the Authorization header (e.g. browser WebSockets)
+func (sm *SessionsManager) CheckToken(ctx context.Context, tokenString string) (*Session, error) {
+	for _, auth := range sm.Tokens {
+		if auth.Accepts(tokenString) {
+			return auth.Authenticate(ctx, tokenString)
+		}
+	}
 	claims := jwt.MapClaims{}
 	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		if t.Method != jwt.SigningMethodHS256 {
+		if t.Method != jwt.SigningMethodHS256 || len(Key) == 0 {
 			return nil, ErrNoAuth
 		}
 		return Key, nil
@@ -51,27 +85,58 @@ func (sm *SessionsManager) Check(w http.ResponseWriter, r *http.Request) (*Sessi
 	if err != nil {
 		return nil, ErrNoAuth
 	}
-	user, ok := (claims["user"]).(map[string]interface{})
+	sessID, ok := (claims["sid"]).(string)
 	if !ok {
 		return nil, ErrNoAuth
 	}
-	userID, success := (user["id"]).(string)
-	if !success {
-		return nil, ErrNoAuth
-	}
 	sm.mu.RLock()
-	sess, ok := sm.data[userID]
+	sess, ok := sm.data[sessID]
 	sm.mu.RUnlock()
 	if !ok {
 		return nil, ErrNoAuth
 	}
-	return sess, nil
+	if sm.Users == nil {
+		return sess, nil
+	}
+	// the roles may have changed since the login
+	usr, err := sm.Users.GetByLogin(ctx, sess.Login)
+	if errors.Is(err, user.ErrNoUser) {
+		return nil, ErrNoAuth
+	}
+	if err != nil {
+		return nil, err
+	}
+	current := *sess
+	current.Roles = usr.Roles
+	return &current, nil
 }
 
-func (sm *SessionsManager) Create(w http.ResponseWriter, userID uint64, login string) (*Session, error) {
-	sess := NewSession(userID, login)
+func (sm *SessionsManager) Create(w http.ResponseWriter, userID uint64, login string, roles ...string) (*Session, error) {
+	sessID, err := uuid.GenerateRandomBytes(16)
+	if err != nil {
+		return nil, err
+	}
+	sess := NewSession(userID, login, roles...)
+	sess.ID = fmt.Sprintf("%x", sessID)
 	sm.mu.Lock()
-	sm.data[strconv.FormatUint(userID, 10)] = sess
+	sm.data[sess.ID] = sess
 	sm.mu.Unlock()
 	return sess, nil
 }
+
+func (sm *SessionsManager) Destroy(sessID string) {
+	sm.mu.Lock()
+	delete(sm.data, sessID)
+	sm.mu.Unlock()
+}
+
+// DestroyByUser logs the user out everywhere except the session with keepID
+func (sm *SessionsManager) DestroyByUser(userID uint64, keepID string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for id, sess := range sm.data {
+		if sess.UserID == userID && id != keepID {
+			delete(sm.data, id)
+		}
+	}
+}
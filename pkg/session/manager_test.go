@@ -0,0 +1,94 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"myredditclone/pkg/user"
+)
+
+func TestMain(m *testing.M) {
+	Key = []byte("test key")
+	os.Exit(m.Run())
+}
+
+// Without the key from the config, the tokens would be signed by nothing
+func TestTokensNeedKey(t *testing.T) {
+	ctx := context.Background()
+	usr := user.User{ID: 1, Login: "alice"}
+	sm := NewSessionManager()
+	sess, err := sm.Create(nil, usr.ID, usr.Login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := CreateNewToken(usr, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key
+	Key = nil
+	defer func() { Key = key }()
+	if _, err := CreateNewToken(usr, sess.ID); !errors.Is(err, ErrNoKey) {
+		t.Errorf("token without the key: error = %v, want ErrNoKey", err)
+	}
+	if _, err := sm.CheckToken(ctx, token); !errors.Is(err, ErrNoAuth) {
+		t.Errorf("check without the key: error = %v, want ErrNoAuth", err)
+	}
+}
+
+func TestCheckTokenReadsRoles(t *testing.T) {
+	ctx := context.Background()
+	users := user.NewUserRepository()
+	usr, err := users.Register(ctx, "alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSessionManager()
+	sm.Users = users
+	sess, err := sm.Create(nil, usr.ID, usr.Login, usr.Roles...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := CreateNewToken(usr, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := sm.CheckToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HasRole(user.RoleAdmin) {
+		t.Fatal("a new user is admin")
+	}
+	if err := users.SetRoles(ctx, "alice", user.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	got, err = sm.CheckToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.HasRole(user.RoleAdmin) {
+		t.Error("the granted role isn't seen by the existing session")
+	}
+	if err := users.SetRoles(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = sm.CheckToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HasRole(user.RoleAdmin) {
+		t.Error("the revoked role is still in the session")
+	}
+
+	if _, err := users.Delete(ctx, "alice", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sm.CheckToken(ctx, token); !errors.Is(err, ErrNoAuth) {
+		t.Errorf("session of a deleted user: error = %v, want ErrNoAuth", err)
+	}
+}
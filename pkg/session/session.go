@@ -2,26 +2,63 @@ package session
 
 import (
 	"context"
-	"errors"
+	"myredditclone/pkg/apperrors"
 )
 
 type sessKey string
 
 var (
-	ErrNoAuth          = errors.New("No session found")
+	ErrNoAuth          = apperrors.New(apperrors.ErrUnauthenticated, "No session found")
 	sessionKey sessKey = "session key"
 )
 
+// Scopes of the personal access tokens, ScopeAccount is never granted to them
+const (
+	ScopeRead     = "read"
+	ScopePost     = "post"
+	ScopeVote     = "vote"
+	ScopeModerate = "moderate"
+	ScopeAccount  = "account"
+)
+
+// TokenScopes are the scopes the tokens can be issued with
+var TokenScopes = []string{ScopeRead, ScopePost, ScopeVote, ScopeModerate}
+
 type Session struct {
+	ID     string
 	UserID uint64
 	Login  string
+	Roles  []string
+	Scopes []string //nil for the sessions with full access
 }
 
-func NewSession(userID uint64, login string) *Session {
+func NewSession(userID uint64, login string, roles ...string) *Session {
 	return &Session{
 		UserID: userID,
 		Login:  login,
+		Roles:  roles,
+	}
+}
+
+func (sess *Session) HasRole(role string) bool {
+	for _, r := range sess.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (sess *Session) HasScope(scope string) bool {
+	if sess.Scopes == nil {
+		return true
+	}
+	for _, s := range sess.Scopes {
+		if s == scope {
+			return true
+		}
 	}
+	return false
 }
 
 func SessionFromContext(ctx context.Context) (*Session, error) {
@@ -0,0 +1,247 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+var ErrBrokerClosed = errors.New("Stream broker is closed")
+
+const (
+	DefaultHistorySize = 128
+	DefaultBufferSize  = 32
+	DefaultMaxTopics   = 1024
+)
+
+type Event struct {
+	ID    uint64
+	Topic string
+	Type  string
+	Data  []byte
+}
+
+// Subscription receives events of one topic. C is closed when the
+// subscriber is removed, either by Unsubscribe or because it was too slow
+// to read the events
+type Subscription struct {
+	C     <-chan Event
+	topic string
+	ch    chan Event
+}
+
+// Broker is an in-process publish/subscribe bus. It keeps the last events
+// of up to maxTopics topics so clients can resume after reconnect
+type Broker struct {
+	lastID      uint64
+	historySize int
+	bufferSize  int
+	maxTopics   int
+	history     map[string][]Event
+	// lost keeps the ID of the last event trimmed from the history of the
+	// topic, evicted the last ID of the evicted or dropped histories
+	lost        map[string]uint64
+	evicted     uint64
+	subscribers map[string]map[*Subscription]struct{}
+	done        chan struct{}
+	closed      bool
+	mu          sync.Mutex
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		historySize: DefaultHistorySize,
+		bufferSize:  DefaultBufferSize,
+		maxTopics:   DefaultMaxTopics,
+		history:     map[string][]Event{},
+		lost:        map[string]uint64{},
+		subscribers: map[string]map[*Subscription]struct{}{},
+		done:        make(chan struct{}),
+	}
+}
+
+// Close tells the subscribers the server is going down through Done,
+// they should say goodbye to their clients and unsubscribe
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.done)
+}
+
+func (b *Broker) Done() <-chan struct{} {
+	return b.done
+}
+
+func (b *Broker) Ping(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	return nil
+}
+
+func PostTopic(postID string) string {
+	return "post:" + postID
+}
+
+func UserTopic(login string) string {
+	return "user:" + login
+}
+
+const PostsTopic = "posts"
+
+func (b *Broker) Publish(topic, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	event := Event{
+		ID:    b.lastID,
+		Topic: topic,
+		Type:  eventType,
+		Data:  payload,
+	}
+	history, ok := b.history[topic]
+	if !ok {
+		// the earlier events of the topic might have been evicted
+		b.lost[topic] = b.evicted
+	}
+	history = append(history, event)
+	if len(history) > b.historySize {
+		cut := len(history) - b.historySize
+		b.lost[topic] = history[cut-1].ID
+		history = history[cut:]
+	}
+	b.history[topic] = history
+	if len(b.history) > b.maxTopics {
+		b.evict()
+	}
+	for sub := range b.subscribers[topic] {
+		select {
+		case sub.ch <- event:
+		default:
+			// the subscriber can't keep up, it has to reconnect with Last-Event-ID
+			b.remove(sub)
+		}
+	}
+	return nil
+}
+
+// evict drops the history of the topic nobody listens to with the oldest
+// last event, so topics of idle users and old posts don't pile up
+func (b *Broker) evict() {
+	oldest := ""
+	var oldestID uint64
+	for topic, history := range b.history {
+		if len(b.subscribers[topic]) != 0 {
+			continue
+		}
+		lastID := history[len(history)-1].ID
+		if oldest == "" || lastID < oldestID {
+			oldest, oldestID = topic, lastID
+		}
+	}
+	if oldest != "" {
+		b.forget(oldest)
+	}
+}
+
+func (b *Broker) forget(topic string) {
+	history, ok := b.history[topic]
+	if !ok {
+		return
+	}
+	if lastID := history[len(history)-1].ID; lastID > b.evicted {
+		b.evicted = lastID
+	}
+	delete(b.history, topic)
+	delete(b.lost, topic)
+}
+
+// Drop forgets the history of the topic, e.g. of a deleted post
+func (b *Broker) Drop(topic string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.forget(topic)
+}
+
+// Subscribe registers a new subscriber and returns the stored events of the
+// topic published after lastEventID. If some of them aren't stored anymore,
+// it returns the single EventReset instead, the client has to reload
+func (b *Broker) Subscribe(topic string, lastEventID uint64) (*Subscription, []Event) {
+	ch := make(chan Event, b.bufferSize)
+	sub := &Subscription{
+		C:     ch,
+		topic: topic,
+		ch:    ch,
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[topic]; !ok {
+		b.subscribers[topic] = map[*Subscription]struct{}{}
+	}
+	b.subscribers[topic][sub] = struct{}{}
+	missed := make([]Event, 0)
+	if lastEventID != 0 && b.gap(topic, lastEventID) {
+		reset := Event{ID: b.lastID, Topic: topic, Type: EventReset, Data: []byte("{}")}
+		return sub, append(missed, reset)
+	}
+	if lastEventID != 0 {
+		for _, event := range b.history[topic] {
+			if event.ID > lastEventID {
+				missed = append(missed, event)
+			}
+		}
+	}
+	return sub, missed
+}
+
+// gap tells whether events of the topic after lastEventID were lost. The ID
+// from the future comes from before the restart of the server
+func (b *Broker) gap(topic string, lastEventID uint64) bool {
+	if lastEventID > b.lastID {
+		return true
+	}
+	lost, ok := b.lost[topic]
+	if _, kept := b.history[topic]; !kept {
+		lost, ok = b.evicted, true
+	}
+	return ok && lastEventID < lost
+}
+
+// Subscribers returns the number of the current subscribers of the topic
+func (b *Broker) Subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers[topic])
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	subs, ok := b.subscribers[sub.topic]
+	if !ok {
+		return
+	}
+	if _, ok = subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(b.subscribers, sub.topic)
+	}
+}
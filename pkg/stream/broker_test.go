@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"fmt"
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/posts"
+	"testing"
+)
+
+func TestBrokerEvictsIdleTopics(t *testing.T) {
+	b := NewBroker()
+	b.maxTopics = 3
+	sub, _ := b.Subscribe("watched", 0)
+	defer b.Unsubscribe(sub)
+	if err := b.Publish("watched", "test", 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := b.Publish(fmt.Sprintf("idle:%d", i), "test", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(b.history) != 3 {
+		t.Fatalf("got %d topics, want 3", len(b.history))
+	}
+	for _, topic := range []string{"watched", "idle:3", "idle:4"} {
+		if _, ok := b.history[topic]; !ok {
+			t.Errorf("history of %q was evicted", topic)
+		}
+	}
+}
+
+func TestBrokerDropsDeletedPost(t *testing.T) {
+	b := NewBroker()
+	post := posts.Post{ID: "7"}
+	if err := b.Handle(events.Voted{Post: post}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.history[PostTopic("7")]; !ok {
+		t.Fatal("no history of the post topic")
+	}
+	if err := b.Handle(events.PostDeleted{Post: post}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.history[PostTopic("7")]; ok {
+		t.Error("history of the deleted post is kept")
+	}
+	if _, ok := b.history[PostsTopic]; !ok {
+		t.Error("the deletion isn't in the posts topic")
+	}
+}
+
+func TestBrokerResetsAfterLostEvents(t *testing.T) {
+	b := NewBroker()
+	b.historySize = 2
+	b.maxTopics = 2
+	for i := 0; i < 4; i++ {
+		if err := b.Publish("trimmed", "test", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// events 1 and 2 are trimmed, 3 and 4 are kept
+	for _, c := range []struct {
+		name        string
+		topic       string
+		lastEventID uint64
+		want        []string
+	}{
+		{"kept", "trimmed", 2, []string{"3 test", "4 test"}},
+		{"trimmed", "trimmed", 1, []string{"4 reset"}},
+		{"from before restart", "trimmed", 9, []string{"4 reset"}},
+		{"new topic", "new", 4, nil},
+	} {
+		sub, missed := b.Subscribe(c.topic, c.lastEventID)
+		b.Unsubscribe(sub)
+		got := make([]string, 0, len(missed))
+		for _, event := range missed {
+			got = append(got, fmt.Sprintf("%d %s", event.ID, event.Type))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("%s: missed %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// the evicted and the dropped topics lose all their events
+	for _, topic := range []string{"second", "third"} {
+		if err := b.Publish(topic, "test", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Drop("third")
+	for _, topic := range []string{"trimmed", "third", "fourth"} {
+		sub, missed := b.Subscribe(topic, 3)
+		b.Unsubscribe(sub)
+		if len(missed) != 1 || missed[0].Type != EventReset || missed[0].ID != 6 {
+			t.Errorf("%s: missed %+v, want the reset at 6", topic, missed)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package stream
+
+import (
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/notifications"
+	"myredditclone/pkg/posts"
+)
+
+const (
+	EventPostCreated    = events.NamePostCreated
+	EventPostDeleted    = events.NamePostDeleted
+	EventCommentAdded   = events.NameCommentAdded
+	EventCommentDeleted = events.NameCommentDeleted
+	EventVoted          = events.NameVoted
+	EventNotification   = "notification"
+	// EventReset tells the client it missed events, it has to reload
+	EventReset = "reset"
+)
+
+// PostUpdate is the payload of events published into the post topic
+type PostUpdate struct {
+	Post      posts.Post `json:"post"`
+	CommentID string     `json:"commentId,omitempty"`
+}
+
+var _ notifications.NotificationRepo = &NotificationRepo{}
+
+// Handle is the domain events subscriber, it publishes post updates into
+// the post topics and new or deleted posts into the posts topic
+func (b *Broker) Handle(event events.Event) error {
+	switch e := event.(type) {
+	case events.PostCreated:
+		return b.Publish(PostsTopic, EventPostCreated, PostUpdate{Post: e.Post})
+	case events.CommentAdded:
+		update := PostUpdate{
+			Post:      e.Post,
+			CommentID: e.Comment.ID,
+		}
+		return b.Publish(PostTopic(e.Post.ID), EventCommentAdded, update)
+	case events.CommentDeleted:
+		update := PostUpdate{
+			Post:      e.Post,
+			CommentID: e.CommentID,
+		}
+		return b.Publish(PostTopic(e.Post.ID), EventCommentDeleted, update)
+	case events.Voted:
+		return b.Publish(PostTopic(e.Post.ID), EventVoted, PostUpdate{Post: e.Post})
+	case events.PostDeleted:
+		update := PostUpdate{Post: posts.Post{ID: e.Post.ID}}
+		err := b.Publish(PostTopic(e.Post.ID), EventPostDeleted, update)
+		if err != nil {
+			return err
+		}
+		// the live subscribers already have the event, nobody resumes a deleted post
+		b.Drop(PostTopic(e.Post.ID))
+		return b.Publish(PostsTopic, EventPostDeleted, update)
+	}
+	return nil
+}
+
+// NotificationRepo publishes new notifications into the recipient's stream
+type NotificationRepo struct {
+	notifications.NotificationRepo
+	Broker *Broker
+}
+
+func NewNotificationRepo(repo notifications.NotificationRepo, broker *Broker) *NotificationRepo {
+	return &NotificationRepo{
+		NotificationRepo: repo,
+		Broker:           broker,
+	}
+}
+
+func (repo *NotificationRepo) Add(item *notifications.Notification) error {
+	err := repo.NotificationRepo.Add(item)
+	if err != nil {
+		return err
+	}
+	return repo.Broker.Publish(UserTopic(item.Recipient), EventNotification, item)
+}
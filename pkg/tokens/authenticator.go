@@ -0,0 +1,107 @@
+package tokens
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+	"strings"
+	"time"
+)
+
+var _ session.TokenAuthenticator = &Authenticator{}
+
+const lastUsedLayout = "2006-01-02T15:04:05.000"
+
+// touchInterval is how stale the last use of a token may be, so a client
+// making many requests doesn't take the write lock of the repository on each
+const touchInterval = time.Minute
+
+// Authenticator issues the tokens and turns them into sessions limited by their scopes
+type Authenticator struct {
+	Repo  TokenRepo
+	Users user.UserRepo
+}
+
+func NewAuthenticator(repo TokenRepo, users user.UserRepo) *Authenticator {
+	return &Authenticator{
+		Repo:  repo,
+		Users: users,
+	}
+}
+
+func hash(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Create returns the token itself, only its hash is kept
+func (a *Authenticator) Create(login, name string, scopes []string) (Token, string, error) {
+	if name == "" {
+		return Token{}, "", apperrors.Validation("name", "", "name is required")
+	}
+	if len(scopes) == 0 {
+		return Token{}, "", apperrors.Validation("scopes", "", "at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return Token{}, "", apperrors.Validation("scopes", scope, "unknown scope")
+		}
+	}
+	secret, err := uuid.GenerateRandomBytes(20)
+	if err != nil {
+		return Token{}, "", err
+	}
+	plain := fmt.Sprintf("%s%x", Prefix, secret)
+	item := Token{
+		Name:   name,
+		Hint:   plain[:len(Prefix)+4],
+		Scopes: scopes,
+		Login:  login,
+		Hash:   hash(plain),
+	}
+	err = a.Repo.Add(&item)
+	if err != nil {
+		return Token{}, "", err
+	}
+	return item, plain, nil
+}
+
+func (a *Authenticator) Accepts(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// Authenticate takes the roles from the user, so they are always up to date
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*session.Session, error) {
+	item, err := a.Repo.GetByHash(hash(token))
+	if err != nil {
+		return nil, session.ErrNoAuth
+	}
+	usr, err := a.Users.GetByLogin(ctx, item.Login)
+	if err != nil {
+		return nil, session.ErrNoAuth
+	}
+	now := time.Now()
+	lastUsed, err := time.ParseInLocation(lastUsedLayout, item.LastUsed, time.Local)
+	if err != nil || now.Sub(lastUsed) >= touchInterval {
+		err = a.Repo.Touch(item.ID, now.Format(lastUsedLayout))
+		if err != nil {
+			return nil, session.ErrNoAuth
+		}
+	}
+	sess := session.NewSession(usr.ID, usr.Login, usr.Roles...)
+	sess.ID = "token:" + item.ID
+	sess.Scopes = item.Scopes
+	return sess, nil
+}
@@ -0,0 +1,166 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+)
+
+func newTestAuthenticator(t *testing.T, logins ...string) *Authenticator {
+	t.Helper()
+	users := user.NewUserRepository()
+	for _, login := range logins {
+		if _, err := users.Register(context.Background(), login, "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewAuthenticator(NewTokenMemoryRepository(), users)
+}
+
+func TestCreate(t *testing.T) {
+	a := newTestAuthenticator(t, "alice")
+	cases := []struct {
+		name   string
+		scopes []string
+		param  string
+	}{
+		{"", []string{session.ScopeRead}, "name"},
+		{"ci", nil, "scopes"},
+		{"ci", []string{session.ScopeAccount}, "scopes"},
+	}
+	for _, c := range cases {
+		_, _, err := a.Create("alice", c.name, c.scopes)
+		var appErr *apperrors.Error
+		if !errors.As(err, &appErr) || apperrors.Kind(err) != apperrors.ErrValidation || appErr.Param != c.param {
+			t.Errorf("create %q with %v: error = %v, want a validation error of %v", c.name, c.scopes, err, c.param)
+		}
+	}
+
+	item, plain, err := a.Create("alice", "ci", []string{session.ScopeRead, session.ScopeVote})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Accepts(plain) || item.Hint != plain[:len(Prefix)+4] || item.Hash == plain {
+		t.Errorf("token %q, item %+v", plain, item)
+	}
+	list, err := a.Repo.GetByUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != item.ID || list[0].Name != "ci" || list[0].Created == "" {
+		t.Errorf("tokens of alice = %+v, want %+v", list, item)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := newTestAuthenticator(t, "alice")
+	item, plain, err := a.Create("alice", "ci", []string{session.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := a.Authenticate(context.Background(), plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Login != "alice" || sess.ID != "token:"+item.ID || !sess.HasScope(session.ScopeRead) || sess.HasScope(session.ScopePost) {
+		t.Errorf("session = %+v, want alice with the read scope only", sess)
+	}
+	for _, token := range []string{"", Prefix + "unknown", plain + "0"} {
+		if _, err = a.Authenticate(context.Background(), token); !errors.Is(err, session.ErrNoAuth) {
+			t.Errorf("authenticate %q: error = %v, want ErrNoAuth", token, err)
+		}
+	}
+}
+
+func TestAuthenticateTouch(t *testing.T) {
+	a := newTestAuthenticator(t, "alice")
+	item, plain, err := a.Create("alice", "ci", []string{session.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastUsed := func() string {
+		t.Helper()
+		got, err := a.Repo.GetByHash(item.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.LastUsed
+	}
+	if _, err = a.Authenticate(context.Background(), plain); err != nil {
+		t.Fatal(err)
+	}
+	first := lastUsed()
+	if first == "" {
+		t.Fatal("the used token has no last use")
+	}
+
+	// the recent use is kept as is
+	recent := time.Now().Add(-touchInterval / 2).Format(lastUsedLayout)
+	if err = a.Repo.Touch(item.ID, recent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Authenticate(context.Background(), plain); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); got != recent {
+		t.Errorf("last used = %v, want %v kept", got, recent)
+	}
+
+	stale := time.Now().Add(-2 * touchInterval).Format(lastUsedLayout)
+	if err = a.Repo.Touch(item.ID, stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Authenticate(context.Background(), plain); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); got <= stale {
+		t.Errorf("last used = %v, want it after %v", got, stale)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	a := newTestAuthenticator(t, "alice", "bob")
+	first, firstPlain, err := a.Create("alice", "first", []string{session.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secondPlain, err := a.Create("alice", "second", []string{session.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bobPlain, err := a.Create("bob", "bob", []string{session.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = a.Repo.Delete("bob", first.ID); !errors.Is(err, ErrNoToken) {
+		t.Errorf("delete the token of alice as bob: error = %v, want ErrNoToken", err)
+	}
+	if err = a.Repo.Delete("alice", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Repo.Delete("alice", first.ID); !errors.Is(err, ErrNoToken) {
+		t.Errorf("delete twice: error = %v, want ErrNoToken", err)
+	}
+	if _, err = a.Authenticate(context.Background(), firstPlain); !errors.Is(err, session.ErrNoAuth) {
+		t.Errorf("revoked token: error = %v, want ErrNoAuth", err)
+	}
+
+	if err = a.Repo.DeleteByUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Authenticate(context.Background(), secondPlain); !errors.Is(err, session.ErrNoAuth) {
+		t.Errorf("token of the deleted user: error = %v, want ErrNoAuth", err)
+	}
+	if _, err = a.Authenticate(context.Background(), bobPlain); err != nil {
+		t.Errorf("token of bob: %v", err)
+	}
+	if list, _ := a.Repo.GetByUser("alice"); len(list) != 0 {
+		t.Errorf("tokens of alice = %+v, want none", list)
+	}
+}
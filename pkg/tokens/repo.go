@@ -0,0 +1,102 @@
+package tokens
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/apperrors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoToken = apperrors.New(apperrors.ErrNotFound, "Current token doesn't exist")
+
+var _ TokenRepo = NewTokenMemoryRepository()
+
+type TokenMemoryRepository struct {
+	data   map[string]Token  //by ID
+	byHash map[string]string //IDs by hash, every request looks the token up
+	mu     sync.RWMutex
+}
+
+func NewTokenMemoryRepository() *TokenMemoryRepository {
+	return &TokenMemoryRepository{
+		data:   map[string]Token{},
+		byHash: map[string]string{},
+	}
+}
+
+func (repo *TokenMemoryRepository) Add(item *Token) error {
+	id, err := uuid.GenerateRandomBytes(16)
+	if err != nil {
+		return err
+	}
+	item.ID = fmt.Sprintf("%x", id)
+	item.Created = time.Now().Format("2006-01-02T15:04:05.000")
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.data[item.ID] = *item
+	repo.byHash[item.Hash] = item.ID
+	return nil
+}
+
+func (repo *TokenMemoryRepository) GetByUser(login string) ([]Token, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	res := make([]Token, 0)
+	for _, item := range repo.data {
+		if item.Login == login {
+			res = append(res, item)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Created > res[j].Created
+	})
+	return res, nil
+}
+
+func (repo *TokenMemoryRepository) GetByHash(hash string) (Token, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	item, ok := repo.data[repo.byHash[hash]]
+	if !ok {
+		return Token{}, ErrNoToken
+	}
+	return item, nil
+}
+
+func (repo *TokenMemoryRepository) Touch(id, lastUsed string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	item, ok := repo.data[id]
+	if !ok {
+		return ErrNoToken
+	}
+	item.LastUsed = lastUsed
+	repo.data[id] = item
+	return nil
+}
+
+func (repo *TokenMemoryRepository) Delete(login, id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	item, ok := repo.data[id]
+	if !ok || item.Login != login {
+		return ErrNoToken
+	}
+	delete(repo.data, id)
+	delete(repo.byHash, item.Hash)
+	return nil
+}
+
+func (repo *TokenMemoryRepository) DeleteByUser(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for id, item := range repo.data {
+		if item.Login == login {
+			delete(repo.data, id)
+			delete(repo.byHash, item.Hash)
+		}
+	}
+	return nil
+}
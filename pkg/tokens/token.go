@@ -0,0 +1,29 @@
+package tokens
+
+import "myredditclone/pkg/session"
+
+// Prefix tells the personal access tokens from JWTs
+const Prefix = "rcp_"
+
+// Scopes the tokens can be created with
+var Scopes = session.TokenScopes
+
+type Token struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Hint     string   `json:"hint"` //the beginning of the token to recognize it
+	Scopes   []string `json:"scopes"`
+	Created  string   `json:"created"`
+	LastUsed string   `json:"lastUsed,omitempty"`
+	Login    string   `json:"-"`
+	Hash     string   `json:"-"`
+}
+
+type TokenRepo interface {
+	Add(item *Token) error
+	GetByUser(login string) ([]Token, error)
+	GetByHash(hash string) (Token, error)
+	Touch(id, lastUsed string) error
+	Delete(login, id string) error
+	DeleteByUser(login string) error
+}
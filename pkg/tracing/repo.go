@@ -0,0 +1,207 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/user"
+)
+
+var (
+	_ posts.PostRepo = &PostRepo{}
+	_ user.UserRepo  = &UserRepo{}
+)
+
+var tracer = otel.Tracer("myredditclone/pkg/tracing")
+
+func start(ctx context.Context, repo, op string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, repo+"."+op, trace.WithAttributes(
+		attribute.String("repository", repo),
+		attribute.String("operation", op),
+	))
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// PostRepo makes a span of every operation of the wrapped repository, the
+// time it waits for the locks included
+type PostRepo struct {
+	Repo posts.PostRepo
+}
+
+func NewPostRepo(repo posts.PostRepo) *PostRepo {
+	return &PostRepo{Repo: repo}
+}
+
+func (repo *PostRepo) GetAll(ctx context.Context) ([]posts.Post, error) {
+	ctx, span := start(ctx, "posts", "GetAll")
+	elems, err := repo.Repo.GetAll(ctx)
+	end(span, err)
+	return elems, err
+}
+
+func (repo *PostRepo) GetByID(ctx context.Context, id string) (posts.Post, error) {
+	ctx, span := start(ctx, "posts", "GetByID")
+	post, err := repo.Repo.GetByID(ctx, id)
+	end(span, err)
+	return post, err
+}
+
+func (repo *PostRepo) Add(ctx context.Context, item *posts.Post) (uint64, error) {
+	ctx, span := start(ctx, "posts", "Add")
+	lastID, err := repo.Repo.Add(ctx, item)
+	end(span, err)
+	return lastID, err
+}
+
+func (repo *PostRepo) AddComment(ctx context.Context, postID, parentID, newCom string, sess session.Session) (posts.Post, error) {
+	ctx, span := start(ctx, "posts", "AddComment")
+	post, err := repo.Repo.AddComment(ctx, postID, parentID, newCom, sess)
+	end(span, err)
+	return post, err
+}
+
+func (repo *PostRepo) DeleteComment(ctx context.Context, postID, commID string, sess session.Session) (posts.Post, error) {
+	ctx, span := start(ctx, "posts", "DeleteComment")
+	post, err := repo.Repo.DeleteComment(ctx, postID, commID, sess)
+	end(span, err)
+	return post, err
+}
+
+func (repo *PostRepo) Vote(ctx context.Context, postID, userID string, newVote int8) (posts.Post, error) {
+	ctx, span := start(ctx, "posts", "Vote")
+	post, err := repo.Repo.Vote(ctx, postID, userID, newVote)
+	end(span, err)
+	return post, err
+}
+
+func (repo *PostRepo) Update(ctx context.Context, newItem posts.Post) error {
+	ctx, span := start(ctx, "posts", "Update")
+	err := repo.Repo.Update(ctx, newItem)
+	end(span, err)
+	return err
+}
+
+func (repo *PostRepo) Delete(ctx context.Context, id string) error {
+	ctx, span := start(ctx, "posts", "Delete")
+	err := repo.Repo.Delete(ctx, id)
+	end(span, err)
+	return err
+}
+
+func (repo *PostRepo) AnonymizeAuthor(ctx context.Context, login string) error {
+	ctx, span := start(ctx, "posts", "AnonymizeAuthor")
+	err := repo.Repo.AnonymizeAuthor(ctx, login)
+	end(span, err)
+	return err
+}
+
+// UserRepo makes a span of every operation of the wrapped repository
+type UserRepo struct {
+	Repo user.UserRepo
+}
+
+func NewUserRepo(repo user.UserRepo) *UserRepo {
+	return &UserRepo{Repo: repo}
+}
+
+func (repo *UserRepo) Authorize(ctx context.Context, login, pass string) (user.User, error) {
+	ctx, span := start(ctx, "users", "Authorize")
+	usr, err := repo.Repo.Authorize(ctx, login, pass)
+	end(span, err)
+	return usr, err
+}
+
+func (repo *UserRepo) Register(ctx context.Context, login, pass string) (user.User, error) {
+	ctx, span := start(ctx, "users", "Register")
+	usr, err := repo.Repo.Register(ctx, login, pass)
+	end(span, err)
+	return usr, err
+}
+
+func (repo *UserRepo) GetByLogin(ctx context.Context, login string) (user.User, error) {
+	ctx, span := start(ctx, "users", "GetByLogin")
+	usr, err := repo.Repo.GetByLogin(ctx, login)
+	end(span, err)
+	return usr, err
+}
+
+func (repo *UserRepo) GetByEmail(ctx context.Context, email string) (user.User, error) {
+	ctx, span := start(ctx, "users", "GetByEmail")
+	usr, err := repo.Repo.GetByEmail(ctx, email)
+	end(span, err)
+	return usr, err
+}
+
+func (repo *UserRepo) GetByIdentity(ctx context.Context, issuer, subject string) (user.User, error) {
+	ctx, span := start(ctx, "users", "GetByIdentity")
+	usr, err := repo.Repo.GetByIdentity(ctx, issuer, subject)
+	end(span, err)
+	return usr, err
+}
+
+func (repo *UserRepo) LinkIdentity(ctx context.Context, login, issuer, subject string) error {
+	ctx, span := start(ctx, "users", "LinkIdentity")
+	err := repo.Repo.LinkIdentity(ctx, login, issuer, subject)
+	end(span, err)
+	return err
+}
+
+func (repo *UserRepo) SetEmail(ctx context.Context, login, email string) error {
+	ctx, span := start(ctx, "users", "SetEmail")
+	err := repo.Repo.SetEmail(ctx, login, email)
+	end(span, err)
+	return err
+}
+
+func (repo *UserRepo) SetEmailVerified(ctx context.Context, login, email string) error {
+	ctx, span := start(ctx, "users", "SetEmailVerified")
+	err := repo.Repo.SetEmailVerified(ctx, login, email)
+	end(span, err)
+	return err
+}
+
+func (repo *UserRepo) SetRoles(ctx context.Context, login string, roles ...string) error {
+	ctx, span := start(ctx, "users", "SetRoles")
+	err := repo.Repo.SetRoles(ctx, login, roles...)
+	end(span, err)
+	return err
+}
+
+func (repo *UserRepo) ChangePassword(ctx context.Context, login, oldPass, newPass string) error {
+	ctx, span := start(ctx, "users", "ChangePassword")
+	err := repo.Repo.ChangePassword(ctx, login, oldPass, newPass)
+	end(span, err)
+	return err
+}
+
+func (repo *UserRepo) ResetPassword(ctx context.Context, login, newPass string) error {
+	ctx, span := start(ctx, "users", "ResetPassword")
+	err := repo.Repo.ResetPassword(ctx, login, newPass)
+	end(span, err)
+	return err
+}
+
+func (repo *UserRepo) SetPreferences(ctx context.Context, login string, prefs user.Preferences) error {
+	ctx, span := start(ctx, "users", "SetPreferences")
+	err := repo.Repo.SetPreferences(ctx, login, prefs)
+	end(span, err)
+	return err
+}
+
+func (repo *UserRepo) Delete(ctx context.Context, login, pass string) (user.User, error) {
+	ctx, span := start(ctx, "users", "Delete")
+	usr, err := repo.Repo.Delete(ctx, login, pass)
+	end(span, err)
+	return usr, err
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"io"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// NewExporter makes the exporter of the kind: stdout writes the spans as
+// JSON lines to out, otlp sends them over HTTP to the endpoint or to
+// OTEL_EXPORTER_OTLP_ENDPOINT when it's empty
+func NewExporter(ctx context.Context, kind, endpoint string, out io.Writer) (sdktrace.SpanExporter, error) {
+	switch kind {
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(out))
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("unknown trace exporter %q", kind)
+}
+
+// NewProvider batches the sampled spans into the exporter, tests pass an
+// in-memory one from sdk/trace/tracetest
+func NewProvider(exporter sdktrace.SpanExporter, service, version string, ratio float64) *sdktrace.TracerProvider {
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(service),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		res = resource.Default()
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// the callers deciding to sample are followed, so a trace isn't cut
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+}
+
+// Install makes the provider global and sets the W3C trace context and
+// baggage propagation, a nil provider leaves the no-op one of otel
+func Install(provider *sdktrace.TracerProvider) {
+	if provider != nil {
+		otel.SetTracerProvider(provider)
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
@@ -0,0 +1,228 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"myredditclone/pkg/blocks"
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/metrics"
+	"myredditclone/pkg/middleware"
+	"myredditclone/pkg/posts"
+	"myredditclone/pkg/session"
+	"myredditclone/pkg/tracing"
+	"myredditclone/pkg/user"
+)
+
+// the tracers of the packages are bound to the first global provider,
+// so all the tests share one recorder and tell their spans by the trace
+var (
+	recorder = tracetest.NewSpanRecorder()
+	provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+)
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	os.Exit(m.Run())
+}
+
+// spansOf returns the ended spans of the trace by their names
+func spansOf(t *testing.T, traceID trace.TraceID) map[string]sdktrace.ReadOnlySpan {
+	t.Helper()
+	res := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != traceID {
+			continue
+		}
+		if _, ok := res[span.Name()]; ok {
+			t.Errorf("span %v is recorded twice", span.Name())
+		}
+		res[span.Name()] = span
+	}
+	return res
+}
+
+func root(t *testing.T) (context.Context, trace.Span) {
+	return provider.Tracer("test").Start(context.Background(), t.Name())
+}
+
+func TestPostRepoSpans(t *testing.T) {
+	repo := tracing.NewPostRepo(posts.NewPostMemoryRepository())
+	ctx, parent := root(t)
+	post := &posts.Post{Category: "music", Text: "body"}
+	if _, err := repo.Add(ctx, post); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetByID(ctx, "missing"); !errors.Is(err, posts.ErrRecordNotFound) {
+		t.Fatalf("error = %v, want ErrRecordNotFound", err)
+	}
+	parent.End()
+
+	spans := spansOf(t, parent.SpanContext().TraceID())
+	if len(spans) != 3 {
+		t.Fatalf("recorded %v spans, want 3: %v", len(spans), spans)
+	}
+	for _, name := range []string{"posts.Add", "posts.GetByID"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %v span", name)
+			continue
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%v is not a child of the caller's span", name)
+		}
+	}
+	if status := spans["posts.Add"].Status(); status.Code != codes.Unset {
+		t.Errorf("posts.Add status = %v, want unset", status)
+	}
+	failed := spans["posts.GetByID"]
+	if status := failed.Status(); status.Code != codes.Error || status.Description != posts.ErrRecordNotFound.Error() {
+		t.Errorf("posts.GetByID status = %+v, want the error", status)
+	}
+	if events := failed.Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("posts.GetByID events = %v, want the recorded error", events)
+	}
+}
+
+func TestUserRepoSpans(t *testing.T) {
+	repo := tracing.NewUserRepo(user.NewUserRepository())
+	ctx, parent := root(t)
+	if _, err := repo.Register(ctx, "alice", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Authorize(ctx, "alice", "wrong"); err == nil {
+		t.Fatal("wrong password accepted")
+	}
+	parent.End()
+
+	spans := spansOf(t, parent.SpanContext().TraceID())
+	if _, ok := spans["users.Register"]; !ok {
+		t.Errorf("no users.Register span in %v", spans)
+	}
+	authorize, ok := spans["users.Authorize"]
+	if !ok {
+		t.Fatalf("no users.Authorize span in %v", spans)
+	}
+	if authorize.Status().Code != codes.Error {
+		t.Errorf("users.Authorize status = %+v, want error", authorize.Status())
+	}
+}
+
+// A request makes the server span, the service and repository spans are
+// its children through the context passed down the decorators wired as in main
+func TestRequestTrace(t *testing.T) {
+	bus := events.NewBus(zap.NewNop().Sugar())
+	defer bus.Close()
+	repo := events.NewPostRepo(tracing.NewPostRepo(metrics.NewPostRepo(posts.NewPostMemoryRepository(), metrics.New())), bus)
+	service := posts.NewPostService(repo, blocks.NewBlockMemoryRepository())
+	post := &posts.Post{Category: "music", Text: "body"}
+	if _, err := service.CreatePost(context.Background(), post, session.Session{UserID: 1, Login: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := mux.NewRouter()
+	r.Use(middleware.Route)
+	r.HandleFunc("/api/posts/{CATEGORY_NAME}", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := service.ListByCategory(r.Context(), mux.Vars(r)["CATEGORY_NAME"]); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	// the caller's trace is continued
+	_, caller := root(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/posts/music", nil)
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpan(context.Background(), caller), propagation.HeaderCarrier(req.Header))
+	middleware.Tracing(r).ServeHTTP(httptest.NewRecorder(), req)
+	caller.End()
+
+	spans := spansOf(t, caller.SpanContext().TraceID())
+	server, ok := spans["GET /api/posts/{CATEGORY_NAME}"]
+	if !ok {
+		t.Fatalf("no server span in %v", spans)
+	}
+	if server.SpanKind() != trace.SpanKindServer || server.Parent().SpanID() != caller.SpanContext().SpanID() {
+		t.Errorf("server span kind %v, parent %v: want a server span of the caller", server.SpanKind(), server.Parent().SpanID())
+	}
+	for _, name := range []string{"posts.GetAll", "posts.Sort"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %v span in %v", name, spans)
+			continue
+		}
+		if span.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("%v is not a child of the server span", name)
+		}
+	}
+	if len(spans) != 4 {
+		t.Errorf("recorded %v spans, want the caller, server, repository and sort ones", len(spans))
+	}
+}
+
+func TestServerSpanError(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(middleware.Route)
+	r.HandleFunc("/api/post/{POST_ID:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	_, caller := root(t)
+	req := httptest.NewRequest(http.MethodGet, "/api/post/1", nil)
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpan(context.Background(), caller), propagation.HeaderCarrier(req.Header))
+	middleware.Tracing(r).ServeHTTP(httptest.NewRecorder(), req)
+	caller.End()
+
+	server, ok := spansOf(t, caller.SpanContext().TraceID())["GET /api/post/{POST_ID}"]
+	if !ok {
+		t.Fatal("no server span named by the route template")
+	}
+	if server.Status().Code != codes.Error {
+		t.Errorf("status = %+v, want error for 503", server.Status())
+	}
+}
+
+// The requests no route took are traced too, mux skips its middlewares for them
+func TestServerSpanUnmatched(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(middleware.Route)
+	r.HandleFunc("/api/posts", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+	for _, c := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/unknown", http.StatusNotFound},
+		{http.MethodDelete, "/api/posts", http.StatusMethodNotAllowed},
+	} {
+		_, caller := root(t)
+		req := httptest.NewRequest(c.method, c.path, nil)
+		otel.GetTextMapPropagator().Inject(trace.ContextWithSpan(context.Background(), caller), propagation.HeaderCarrier(req.Header))
+		middleware.Tracing(r).ServeHTTP(httptest.NewRecorder(), req)
+		caller.End()
+
+		server, ok := spansOf(t, caller.SpanContext().TraceID())[c.method+" "+metrics.RouteUnmatched]
+		if !ok {
+			t.Errorf("%v %v: no server span of the unmatched request", c.method, c.path)
+			continue
+		}
+		var status int64
+		for _, attr := range server.Attributes() {
+			if attr.Key == semconv.HTTPResponseStatusCodeKey {
+				status = attr.Value.AsInt64()
+			}
+		}
+		if status != int64(c.status) {
+			t.Errorf("%v %v: status %v, want %v", c.method, c.path, status, c.status)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package twofactor
+
+import (
+	"myredditclone/pkg/apperrors"
+	"sync"
+)
+
+var ErrNotEnrolled = apperrors.New(apperrors.ErrNotFound, "Two-factor authentication isn't set up")
+
+var _ TwoFactorRepo = NewTwoFactorMemoryRepository()
+
+type TwoFactorMemoryRepository struct {
+	data map[string]State
+	mu   sync.RWMutex
+}
+
+func NewTwoFactorMemoryRepository() *TwoFactorMemoryRepository {
+	return &TwoFactorMemoryRepository{
+		data: map[string]State{},
+	}
+}
+
+func (repo *TwoFactorMemoryRepository) Get(login string) (State, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	state, ok := repo.data[login]
+	if !ok {
+		return State{}, ErrNotEnrolled
+	}
+	return state, nil
+}
+
+func (repo *TwoFactorMemoryRepository) Save(login string, state State) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.data[login] = state
+	return nil
+}
+
+func (repo *TwoFactorMemoryRepository) Delete(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, ok := repo.data[login]; !ok {
+		return ErrNotEnrolled
+	}
+	delete(repo.data, login)
+	return nil
+}
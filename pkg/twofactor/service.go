@@ -0,0 +1,345 @@
+package twofactor
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"myredditclone/pkg/apperrors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ChallengeTTL      = 5 * time.Minute
+	ChallengeAttempts = 5
+	RecoveryCodesNum  = 10
+	period            = 30
+)
+
+// A login is locked out after MaxFailures wrong codes within FailureWindow,
+// whatever the number of challenges they were sent with
+const (
+	MaxFailures     = 10
+	FailureWindow   = 15 * time.Minute
+	LockoutDuration = 15 * time.Minute
+)
+
+var (
+	ErrEnabled    = apperrors.New(apperrors.ErrConflict, "Two-factor authentication is already enabled")
+	ErrBadCode    = apperrors.Validation("code", "", "The code is wrong or was already used")
+	ErrChallenge  = apperrors.New(apperrors.ErrUnauthenticated, "The login challenge is invalid or expired")
+	ErrNotEnabled = apperrors.New(apperrors.ErrNotFound, "Two-factor authentication isn't enabled")
+	ErrLocked     = apperrors.New(apperrors.ErrForbidden, "Too many wrong codes, try again later")
+	validateOpts  = totp.ValidateOpts{Period: period, Skew: 1, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+)
+
+type challenge struct {
+	Login    string
+	Expires  time.Time
+	Attempts int
+}
+
+type failures struct {
+	Count       int
+	Since       time.Time //start of the window the failures are counted in
+	LockedUntil time.Time
+}
+
+// Service enrolls the users into TOTP and checks the second step of their logins
+type Service struct {
+	Repo   TwoFactorRepo
+	Clock  Clock
+	Issuer string
+
+	challenges map[string]*challenge //by token hash
+	failures   map[string]*failures  //by login
+	mu         sync.Mutex
+	stateMu    sync.Mutex //serializes the checks, so a code can't be used twice
+}
+
+func NewService(repo TwoFactorRepo, issuer string) *Service {
+	return &Service{
+		Repo:       repo,
+		Clock:      RealClock{},
+		Issuer:     issuer,
+		challenges: map[string]*challenge{},
+		failures:   map[string]*failures{},
+	}
+}
+
+func hash(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+func randomHex(n int) (string, error) {
+	b, err := uuid.GenerateRandomBytes(n)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
+
+func (s *Service) Enabled(login string) bool {
+	state, err := s.Repo.Get(login)
+	return err == nil && state.Enabled
+}
+
+// Enroll generates a new secret, it works only after Confirm
+func (s *Service) Enroll(login string) (secret, uri string, err error) {
+	if s.Enabled(login) {
+		return "", "", ErrEnabled
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.Issuer,
+		AccountName: login,
+		Period:      period,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	err = s.Repo.Save(login, State{Secret: key.Secret()})
+	if err != nil {
+		return "", "", err
+	}
+	return key.Secret(), key.URL(), nil
+}
+
+// Confirm enables the enrolled secret once the user proves the app has it
+func (s *Service) Confirm(login, code string) ([]string, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	state, err := s.Repo.Get(login)
+	if err != nil {
+		return nil, err
+	}
+	if state.Enabled {
+		return nil, ErrEnabled
+	}
+	step, ok := s.checkTOTP(state, code)
+	if !ok {
+		return nil, ErrBadCode
+	}
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	state.Enabled = true
+	state.LastStep = step
+	state.RecoveryCodes = hashes
+	return codes, s.Repo.Save(login, state)
+}
+
+// Disable needs a code only if the enrollment was confirmed
+func (s *Service) Disable(login, code string) error {
+	if s.Enabled(login) {
+		err := s.Verify(login, code)
+		if err != nil {
+			return err
+		}
+	}
+	return s.Repo.Delete(login)
+}
+
+// Forget drops the secret, the pending challenges and the failures of the user
+func (s *Service) Forget(login string) error {
+	err := s.Repo.Delete(login)
+	if err != nil && !errors.Is(err, ErrNotEnrolled) {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, login)
+	for hash, c := range s.challenges {
+		if c.Login == login {
+			delete(s.challenges, hash)
+		}
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all the recovery codes of the user
+func (s *Service) RegenerateRecoveryCodes(login, code string) ([]string, error) {
+	err := s.Verify(login, code)
+	if err != nil {
+		return nil, err
+	}
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	state, err := s.Repo.Get(login)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	state.RecoveryCodes = hashes
+	return codes, s.Repo.Save(login, state)
+}
+
+// Verify accepts either a TOTP code not used before or an unused recovery code,
+// the wrong codes count towards the lockout of the login
+func (s *Service) Verify(login, code string) error {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.locked(login) {
+		return ErrLocked
+	}
+	state, err := s.Repo.Get(login)
+	if err != nil {
+		return err
+	}
+	if !state.Enabled {
+		return ErrNotEnabled
+	}
+	if step, ok := s.checkTOTP(state, code); ok {
+		state.LastStep = step
+		s.forgetFailures(login)
+		return s.Repo.Save(login, state)
+	}
+	codeHash := hash(normalizeRecovery(code))
+	for i, h := range state.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(codeHash)) == 1 {
+			state.RecoveryCodes = append(state.RecoveryCodes[:i:i], state.RecoveryCodes[i+1:]...)
+			s.forgetFailures(login)
+			return s.Repo.Save(login, state)
+		}
+	}
+	if s.fail(login) {
+		return ErrLocked
+	}
+	return ErrBadCode
+}
+
+func (s *Service) locked(login string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.failures[login]
+	return ok && s.Clock.Now().Before(f.LockedUntil)
+}
+
+// fail counts the wrong code and reports whether the login got locked out
+func (s *Service) fail(login string) bool {
+	now := s.Clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for l, f := range s.failures {
+		if now.Sub(f.Since) > FailureWindow && now.After(f.LockedUntil) {
+			delete(s.failures, l)
+		}
+	}
+	f, ok := s.failures[login]
+	if !ok {
+		f = &failures{Since: now}
+		s.failures[login] = f
+	}
+	if now.Sub(f.Since) > FailureWindow {
+		f.Count = 0
+		f.Since = now
+	}
+	f.Count++
+	if f.Count < MaxFailures {
+		return false
+	}
+	f.Count = 0
+	f.Since = now
+	f.LockedUntil = now.Add(LockoutDuration)
+	return true
+}
+
+func (s *Service) forgetFailures(login string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, login)
+}
+
+// checkTOTP returns the time step the code matches, newer than the last used one
+func (s *Service) checkTOTP(state State, code string) (int64, bool) {
+	now := s.Clock.Now()
+	for skew := -int64(validateOpts.Skew); skew <= int64(validateOpts.Skew); skew++ {
+		t := now.Add(time.Duration(skew*period) * time.Second)
+		step := t.Unix() / period
+		if step <= state.LastStep {
+			continue
+		}
+		expected, err := totp.GenerateCodeCustom(state.Secret, t, validateOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func normalizeRecovery(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func recoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < RecoveryCodesNum; i++ {
+		code, err := randomHex(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hash(code))
+	}
+	return codes, hashes, nil
+}
+
+// StartChallenge issues the short-lived token the second login step is done with
+func (s *Service) StartChallenge(login string) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	now := s.Clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for h, c := range s.challenges {
+		if now.After(c.Expires) {
+			delete(s.challenges, h)
+		}
+	}
+	s.challenges[hash(token)] = &challenge{
+		Login:   login,
+		Expires: now.Add(ChallengeTTL),
+	}
+	return token, nil
+}
+
+// CompleteChallenge returns the login once the code is right,
+// the challenge is dropped after success, expiry or too many attempts
+func (s *Service) CompleteChallenge(token, code string) (string, error) {
+	h := hash(token)
+	s.mu.Lock()
+	c, ok := s.challenges[h]
+	if !ok || s.Clock.Now().After(c.Expires) || c.Attempts >= ChallengeAttempts {
+		delete(s.challenges, h)
+		s.mu.Unlock()
+		return "", ErrChallenge
+	}
+	c.Attempts++
+	login := c.Login
+	s.mu.Unlock()
+
+	err := s.Verify(login, code)
+	if errors.Is(err, ErrNotEnabled) || errors.Is(err, ErrNotEnrolled) {
+		//2FA was turned off meanwhile, the password was checked already
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	delete(s.challenges, h)
+	s.mu.Unlock()
+	return login, nil
+}
@@ -0,0 +1,226 @@
+package twofactor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// newEnabled returns the service with 2FA enabled for alice and her secret
+func newEnabled(t *testing.T) (*Service, *fakeClock, string) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 10, 0, time.UTC)}
+	s := NewService(NewTwoFactorMemoryRepository(), "test")
+	s.Clock = clock
+	secret, _, err := s.Enroll("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the enrollment is confirmed with the code of the previous step, so
+	// the codes of the current one are still unused
+	if _, err := s.Confirm("alice", codeAt(t, secret, clock.now.Add(-period*time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	return s, clock, secret
+}
+
+func codeAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.GenerateCodeCustom(secret, at, validateOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestVerifyWindow(t *testing.T) {
+	cases := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"current step", 0, true},
+		{"next step", period * time.Second, true},
+		{"two steps ahead", 2 * period * time.Second, false},
+		{"three steps ago", -3 * period * time.Second, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, clock, secret := newEnabled(t)
+			err := s.Verify("alice", codeAt(t, secret, clock.now.Add(c.offset)))
+			if c.ok && err != nil {
+				t.Errorf("error = %v, want the code accepted", err)
+			}
+			if !c.ok && !errors.Is(err, ErrBadCode) {
+				t.Errorf("error = %v, want ErrBadCode", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	s, clock, secret := newEnabled(t)
+	code := codeAt(t, secret, clock.now)
+	if err := s.Verify("alice", code); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("alice", code); !errors.Is(err, ErrBadCode) {
+		t.Errorf("replayed code: error = %v, want ErrBadCode", err)
+	}
+	// the code of the previous step is older than the used one
+	if err := s.Verify("alice", codeAt(t, secret, clock.now.Add(-period*time.Second))); !errors.Is(err, ErrBadCode) {
+		t.Errorf("older code: error = %v, want ErrBadCode", err)
+	}
+	clock.Advance(period * time.Second)
+	if err := s.Verify("alice", codeAt(t, secret, clock.now)); err != nil {
+		t.Errorf("code of the next step: %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	s, _, _ := newEnabled(t)
+	codes, err := s.RegenerateRecoveryCodes("alice", "")
+	if !errors.Is(err, ErrBadCode) || codes != nil {
+		t.Fatalf("regenerate without a code: %v, %v", codes, err)
+	}
+	s, clock, secret := newEnabled(t)
+	codes, err = s.RegenerateRecoveryCodes("alice", codeAt(t, secret, clock.now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodesNum {
+		t.Fatalf("got %v recovery codes, want %v", len(codes), RecoveryCodesNum)
+	}
+	if err := s.Verify("alice", codes[0]); err != nil {
+		t.Errorf("recovery code: %v", err)
+	}
+	if err := s.Verify("alice", codes[0]); !errors.Is(err, ErrBadCode) {
+		t.Errorf("used recovery code: error = %v, want ErrBadCode", err)
+	}
+}
+
+func TestChallengeExpires(t *testing.T) {
+	s, clock, secret := newEnabled(t)
+	token, err := s.StartChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(ChallengeTTL + time.Second)
+	if _, err := s.CompleteChallenge(token, codeAt(t, secret, clock.now)); !errors.Is(err, ErrChallenge) {
+		t.Errorf("expired challenge: error = %v, want ErrChallenge", err)
+	}
+
+	token, err = s.StartChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(ChallengeTTL - time.Second)
+	login, err := s.CompleteChallenge(token, codeAt(t, secret, clock.now))
+	if err != nil || login != "alice" {
+		t.Fatalf("challenge before expiry: %q, %v", login, err)
+	}
+	if _, err := s.CompleteChallenge(token, codeAt(t, secret, clock.now)); !errors.Is(err, ErrChallenge) {
+		t.Errorf("completed challenge: error = %v, want ErrChallenge", err)
+	}
+	if _, err := s.CompleteChallenge("unknown", "123456"); !errors.Is(err, ErrChallenge) {
+		t.Errorf("unknown challenge: error = %v, want ErrChallenge", err)
+	}
+}
+
+func TestChallengeAttempts(t *testing.T) {
+	s, clock, secret := newEnabled(t)
+	token, err := s.StartChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < ChallengeAttempts; i++ {
+		if _, err := s.CompleteChallenge(token, "000000"); !errors.Is(err, ErrBadCode) {
+			t.Fatalf("attempt %v: error = %v, want ErrBadCode", i+1, err)
+		}
+	}
+	// even the right code doesn't help after the attempts are spent
+	if _, err := s.CompleteChallenge(token, codeAt(t, secret, clock.now)); !errors.Is(err, ErrChallenge) {
+		t.Errorf("after %v attempts: error = %v, want ErrChallenge", ChallengeAttempts, err)
+	}
+}
+
+func TestLockoutAcrossChallenges(t *testing.T) {
+	s, clock, secret := newEnabled(t)
+	failed := 0
+	for failed < MaxFailures-1 {
+		token, err := s.StartChallenge("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < ChallengeAttempts && failed < MaxFailures-1; i++ {
+			if _, err := s.CompleteChallenge(token, "000000"); !errors.Is(err, ErrBadCode) {
+				t.Fatalf("failure %v: error = %v, want ErrBadCode", failed+1, err)
+			}
+			failed++
+		}
+	}
+	token, err := s.StartChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompleteChallenge(token, "000000"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("failure %v: error = %v, want ErrLocked", MaxFailures, err)
+	}
+	// a fresh challenge with the right code is refused during the lockout
+	token, err = s.StartChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CompleteChallenge(token, codeAt(t, secret, clock.now)); !errors.Is(err, ErrLocked) {
+		t.Errorf("right code while locked: error = %v, want ErrLocked", err)
+	}
+	// other users aren't affected
+	if err := s.Repo.Save("bob", State{Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("bob", "000000"); !errors.Is(err, ErrBadCode) {
+		t.Errorf("bob: error = %v, want ErrBadCode", err)
+	}
+
+	clock.Advance(LockoutDuration + time.Second)
+	token, err = s.StartChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login, err := s.CompleteChallenge(token, codeAt(t, secret, clock.now)); err != nil || login != "alice" {
+		t.Errorf("after the lockout: %q, %v", login, err)
+	}
+}
+
+func TestFailuresExpire(t *testing.T) {
+	s, clock, secret := newEnabled(t)
+	for i := 0; i < MaxFailures-1; i++ {
+		if err := s.Verify("alice", "000000"); !errors.Is(err, ErrBadCode) {
+			t.Fatalf("failure %v: error = %v", i+1, err)
+		}
+	}
+	clock.Advance(FailureWindow + time.Second)
+	if err := s.Verify("alice", "000000"); !errors.Is(err, ErrBadCode) {
+		t.Fatalf("failure after the window: error = %v, want ErrBadCode", err)
+	}
+	// a success forgets the failures
+	for i := 0; i < MaxFailures-2; i++ {
+		_ = s.Verify("alice", "000000")
+	}
+	if err := s.Verify("alice", codeAt(t, secret, clock.now)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("alice", "000000"); !errors.Is(err, ErrBadCode) {
+		t.Errorf("failure after a success: error = %v, want ErrBadCode", err)
+	}
+}
@@ -0,0 +1,25 @@
+package twofactor
+
+import "time"
+
+// Clock is replaced with a fixed one to check the codes deterministically
+type Clock interface {
+	Now() time.Time
+}
+
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+type State struct {
+	Secret        string
+	Enabled       bool     //false while the enrollment isn't confirmed
+	RecoveryCodes []string //hashes of the unused codes
+	LastStep      int64    //the last accepted TOTP time step, against replays
+}
+
+type TwoFactorRepo interface {
+	Get(login string) (State, error)
+	Save(login string, state State) error
+	Delete(login string) error
+}
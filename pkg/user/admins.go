@@ -0,0 +1,48 @@
+package user
+
+import (
+	"context"
+	"errors"
+)
+
+// Admins grants the admin role to the configured logins, so the first admin
+// doesn't need another one to appoint them
+type Admins struct {
+	Repo   UserRepo
+	Logins []string
+}
+
+// Grant gives the role to the configured users that already exist
+func (a Admins) Grant(ctx context.Context) error {
+	for _, login := range a.Logins {
+		err := a.GrantTo(ctx, login)
+		if err != nil && !errors.Is(err, ErrNoUser) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GrantTo gives the role to the user if the login is configured
+func (a Admins) GrantTo(ctx context.Context, login string) error {
+	configured := false
+	for _, l := range a.Logins {
+		if l == login {
+			configured = true
+			break
+		}
+	}
+	if !configured {
+		return nil
+	}
+	usr, err := a.Repo.GetByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+	for _, role := range usr.Roles {
+		if role == RoleAdmin {
+			return nil
+		}
+	}
+	return a.Repo.SetRoles(ctx, login, append(usr.Roles, RoleAdmin)...)
+}
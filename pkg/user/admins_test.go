@@ -0,0 +1,63 @@
+package user
+
+import (
+	"context"
+	"testing"
+)
+
+func roles(t *testing.T, repo UserRepo, login string) []string {
+	t.Helper()
+	usr, err := repo.GetByLogin(context.Background(), login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return usr.Roles
+}
+
+func TestAdmins(t *testing.T) {
+	ctx := context.Background()
+	repo := NewUserRepository()
+	for _, login := range []string{"root", "alice"} {
+		if _, err := repo.Register(ctx, login, "password"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.SetRoles(ctx, "root", RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+	admins := Admins{Repo: repo, Logins: []string{"root", "later"}}
+
+	// the users registered later are skipped at startup
+	if err := admins.Grant(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := roles(t, repo, "root"); len(got) != 2 || got[0] != RoleModerator || got[1] != RoleAdmin {
+		t.Errorf("root roles = %v, want moderator and admin", got)
+	}
+	if got := roles(t, repo, "alice"); len(got) != 0 {
+		t.Errorf("alice roles = %v, want none", got)
+	}
+	// granting again doesn't duplicate the role
+	if err := admins.Grant(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := roles(t, repo, "root"); len(got) != 2 {
+		t.Errorf("root roles after the second grant = %v", got)
+	}
+
+	if _, err := repo.Register(ctx, "later", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := admins.GrantTo(ctx, "later"); err != nil {
+		t.Fatal(err)
+	}
+	if got := roles(t, repo, "later"); len(got) != 1 || got[0] != RoleAdmin {
+		t.Errorf("later roles = %v, want admin", got)
+	}
+	if err := admins.GrantTo(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if got := roles(t, repo, "alice"); len(got) != 0 {
+		t.Errorf("alice roles = %v, want none", got)
+	}
+}
@@ -1,15 +1,22 @@
 package user
 
 import (
-	"errors"
+	"context"
+	"myredditclone/pkg/apperrors"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var (
-	ErrExistUser = errors.New("This user already exists")
-	ErrNoUser    = errors.New("There's no user")
-	ErrBadPass   = errors.New("Wrong password")
+	ErrExistUser = apperrors.New(apperrors.ErrConflict, "This user already exists")
+	ErrNoUser    = apperrors.New(apperrors.ErrNotFound, "There's no user")
+	ErrBadPass   = apperrors.New(apperrors.ErrUnauthenticated, "Wrong password")
+	ErrExistMail = apperrors.New(apperrors.ErrConflict, "This email is already used")
+	// ErrBadCredentials is what the login answers for both a wrong login and
+	// a wrong password, so it doesn't tell which logins are registered
+	ErrBadCredentials = apperrors.New(apperrors.ErrUnauthenticated, "Wrong login or password")
 )
 
 var _ UserRepo = NewUserRepository()
@@ -17,20 +24,22 @@ var _ UserRepo = NewUserRepository()
 type UserRepository struct {
 	currentFreeID atomic.Uint64
 	data          map[string]User
+	identities    map[[2]string]string //logins by the issuer and the subject of external accounts
 	mu            sync.RWMutex
 }
 
 func NewUserRepository() *UserRepository {
 	return &UserRepository{
-		data: make(map[string]User, 0),
+		data:       make(map[string]User, 0),
+		identities: map[[2]string]string{},
 	}
 }
 
-func (repo *UserRepository) Authorize(login, pass string) (User, error) {
+func (repo *UserRepository) Authorize(ctx context.Context, login, pass string) (User, error) {
 	repo.mu.RLock()
 	usr, ok := repo.data[login]
 	repo.mu.RUnlock()
-	if !ok {
+	if !ok || usr.deleted {
 		return User{}, ErrNoUser
 	}
 	if usr.password != pass {
@@ -39,11 +48,13 @@ func (repo *UserRepository) Authorize(login, pass string) (User, error) {
 	return usr, nil
 }
 
-func (repo *UserRepository) Register(login, pass string) (User, error) {
+func (repo *UserRepository) Register(ctx context.Context, login, pass string) (User, error) {
 	newUser := User{
-		ID:       repo.currentFreeID.Load(),
-		Login:    login,
-		password: pass,
+		ID:          repo.currentFreeID.Load(),
+		Login:       login,
+		Created:     time.Now().Format("2006-01-02T15:04:05.000"),
+		Preferences: DefaultPreferences(),
+		password:    pass,
 	}
 	repo.mu.Lock()
 	defer repo.mu.Unlock()
@@ -56,3 +67,155 @@ func (repo *UserRepository) Register(login, pass string) (User, error) {
 	}
 	return newUser, nil
 }
+
+func (repo *UserRepository) GetByLogin(ctx context.Context, login string) (User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return User{}, ErrNoUser
+	}
+	return usr, nil
+}
+
+// GetByEmail matches the email case-insensitively
+func (repo *UserRepository) GetByEmail(ctx context.Context, email string) (User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	for _, usr := range repo.data {
+		if !usr.deleted && usr.Email != "" && strings.EqualFold(usr.Email, email) {
+			return usr, nil
+		}
+	}
+	return User{}, ErrNoUser
+}
+
+func (repo *UserRepository) GetByIdentity(ctx context.Context, issuer, subject string) (User, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	usr, ok := repo.data[repo.identities[[2]string{issuer, subject}]]
+	if !ok || usr.deleted {
+		return User{}, ErrNoUser
+	}
+	return usr, nil
+}
+
+// LinkIdentity lets the user log in with the external account
+func (repo *UserRepository) LinkIdentity(ctx context.Context, login, issuer, subject string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return ErrNoUser
+	}
+	repo.identities[[2]string{issuer, subject}] = login
+	return nil
+}
+
+func (repo *UserRepository) SetEmail(ctx context.Context, login, email string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return ErrNoUser
+	}
+	for _, other := range repo.data {
+		if other.Login != login && !other.deleted && other.Email != "" && strings.EqualFold(other.Email, email) {
+			return ErrExistMail
+		}
+	}
+	if !strings.EqualFold(usr.Email, email) {
+		usr.EmailVerified = false
+	}
+	usr.Email = email
+	repo.data[login] = usr
+	return nil
+}
+
+// SetEmailVerified fails if the user's email isn't the verified one anymore
+func (repo *UserRepository) SetEmailVerified(ctx context.Context, login, email string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return ErrNoUser
+	}
+	if usr.Email != email {
+		return ErrNoUser
+	}
+	usr.EmailVerified = true
+	repo.data[login] = usr
+	return nil
+}
+
+func (repo *UserRepository) SetRoles(ctx context.Context, login string, roles ...string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return ErrNoUser
+	}
+	usr.Roles = roles
+	repo.data[login] = usr
+	return nil
+}
+
+func (repo *UserRepository) ChangePassword(ctx context.Context, login, oldPass, newPass string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return ErrNoUser
+	}
+	if usr.password != oldPass {
+		return ErrBadPass
+	}
+	usr.password = newPass
+	repo.data[login] = usr
+	return nil
+}
+
+func (repo *UserRepository) ResetPassword(ctx context.Context, login, newPass string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return ErrNoUser
+	}
+	usr.password = newPass
+	repo.data[login] = usr
+	return nil
+}
+
+func (repo *UserRepository) SetPreferences(ctx context.Context, login string, prefs Preferences) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return ErrNoUser
+	}
+	usr.Preferences = prefs
+	repo.data[login] = usr
+	return nil
+}
+
+// Delete keeps the record as a tombstone, so the login can't be taken
+// by someone else and inherit what refers to it
+func (repo *UserRepository) Delete(ctx context.Context, login, pass string) (User, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	usr, ok := repo.data[login]
+	if !ok || usr.deleted {
+		return User{}, ErrNoUser
+	}
+	if usr.password != pass {
+		return User{}, ErrBadPass
+	}
+	repo.data[login] = User{
+		ID:      usr.ID,
+		Login:   usr.Login,
+		Created: usr.Created,
+		deleted: true,
+	}
+	return usr, nil
+}
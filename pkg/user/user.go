@@ -1,12 +1,53 @@
 package user
 
+import "context"
+
+const (
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const (
+	SortTop = "top"
+	SortNew = "new"
+)
+
+type Preferences struct {
+	DefaultSort string `json:"defaultSort"`
+	ShowNSFW    bool   `json:"showNsfw"`
+	NotifyEmail string `json:"notifyEmail"`
+}
+
+func DefaultPreferences() Preferences {
+	return Preferences{
+		DefaultSort: SortTop,
+	}
+}
+
 type User struct {
-	ID       uint64
-	Login    string
-	password string
+	ID            uint64
+	Login         string
+	Email         string
+	EmailVerified bool
+	Roles         []string
+	Created       string
+	Preferences   Preferences
+	password      string
+	deleted       bool
 }
 
 type UserRepo interface {
-	Authorize(login, pass string) (User, error)
-	Register(login, pass string) (User, error)
+	Authorize(ctx context.Context, login, pass string) (User, error)
+	Register(ctx context.Context, login, pass string) (User, error)
+	GetByLogin(ctx context.Context, login string) (User, error)
+	GetByEmail(ctx context.Context, email string) (User, error)
+	GetByIdentity(ctx context.Context, issuer, subject string) (User, error)
+	LinkIdentity(ctx context.Context, login, issuer, subject string) error
+	SetEmail(ctx context.Context, login, email string) error
+	SetEmailVerified(ctx context.Context, login, email string) error
+	SetRoles(ctx context.Context, login string, roles ...string) error
+	ChangePassword(ctx context.Context, login, oldPass, newPass string) error
+	ResetPassword(ctx context.Context, login, newPass string) error
+	SetPreferences(ctx context.Context, login string, prefs Preferences) error
+	Delete(ctx context.Context, login, pass string) (User, error)
 }
@@ -0,0 +1,71 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/apperrors"
+	"sync"
+	"time"
+)
+
+var ErrBadToken = apperrors.Validation("token", "", "The verification token is invalid or expired")
+
+var _ VerifyRepo = NewVerifyMemoryRepository()
+
+type verifyToken struct {
+	Item    Item
+	Expires time.Time
+}
+
+// VerifyMemoryRepository keeps only the hashes of the tokens
+type VerifyMemoryRepository struct {
+	data map[string]verifyToken //by token hash
+	mu   sync.Mutex
+}
+
+func NewVerifyMemoryRepository() *VerifyMemoryRepository {
+	return &VerifyMemoryRepository{
+		data: map[string]verifyToken{},
+	}
+}
+
+func hash(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+func (repo *VerifyMemoryRepository) Create(item Item, ttl time.Duration) (string, error) {
+	tokenBytes, err := uuid.GenerateRandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	token := fmt.Sprintf("%x", tokenBytes)
+	now := time.Now()
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for h, t := range repo.data {
+		if t.Item.Login == item.Login || now.After(t.Expires) {
+			delete(repo.data, h)
+		}
+	}
+	repo.data[hash(token)] = verifyToken{
+		Item:    item,
+		Expires: now.Add(ttl),
+	}
+	return token, nil
+}
+
+func (repo *VerifyMemoryRepository) Consume(token string) (Item, error) {
+	h := hash(token)
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	t, ok := repo.data[h]
+	if !ok {
+		return Item{}, ErrBadToken
+	}
+	delete(repo.data, h)
+	if time.Now().After(t.Expires) {
+		return Item{}, ErrBadToken
+	}
+	return t.Item, nil
+}
@@ -0,0 +1,66 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"myredditclone/pkg/apperrors"
+	"myredditclone/pkg/mail"
+	"myredditclone/pkg/user"
+	"strings"
+)
+
+var (
+	ErrNoEmail  = apperrors.Validation("email", "", "The account has no email")
+	ErrVerified = apperrors.New(apperrors.ErrConflict, "The email is already verified")
+)
+
+// Verifier mails the verification links and confirms them
+type Verifier struct {
+	Repo    VerifyRepo
+	Mailer  mail.Sender
+	Users   user.UserRepo
+	BaseURL string //the scheme and the host the API is reachable at
+}
+
+func NewVerifier(repo VerifyRepo, mailer mail.Sender, users user.UserRepo, baseURL string) *Verifier {
+	return &Verifier{
+		Repo:    repo,
+		Mailer:  mailer,
+		Users:   users,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Send mails the link to the current email of the user
+func (v *Verifier) Send(usr user.User) error {
+	if usr.Email == "" {
+		return ErrNoEmail
+	}
+	if usr.EmailVerified {
+		return ErrVerified
+	}
+	token, err := v.Repo.Create(Item{Login: usr.Login, Email: usr.Email}, TokenTTL)
+	if err != nil {
+		return err
+	}
+	return v.Mailer.Send(mail.Message{
+		To:      usr.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nto confirm this email belongs to your account open the link below:\n\n"+
+			"%s/api/email/verify?token=%s\n\nThe link is valid for %v.\n",
+			usr.Login, v.BaseURL, token, TokenTTL),
+	})
+}
+
+// Confirm marks the email as verified if it wasn't changed since the token was sent
+func (v *Verifier) Confirm(ctx context.Context, token string) (user.User, error) {
+	item, err := v.Repo.Consume(token)
+	if err != nil {
+		return user.User{}, err
+	}
+	err = v.Users.SetEmailVerified(ctx, item.Login, item.Email)
+	if err != nil {
+		return user.User{}, ErrBadToken
+	}
+	return v.Users.GetByLogin(ctx, item.Login)
+}
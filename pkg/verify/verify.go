@@ -0,0 +1,18 @@
+package verify
+
+import "time"
+
+var TokenTTL = 48 * time.Hour
+
+// Item is what the token confirms: the user owned the email when it was sent
+type Item struct {
+	Login string
+	Email string
+}
+
+type VerifyRepo interface {
+	// Create issues a new token for the email, revoking the previous ones of the user
+	Create(item Item, ttl time.Duration) (string, error)
+	// Consume returns what the token was issued for and revokes it
+	Consume(token string) (Item, error)
+}
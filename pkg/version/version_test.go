@@ -0,0 +1,19 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+)
+
+// The values set at link time win over the VCS info of the build
+func TestGet(t *testing.T) {
+	defer func(v, c, b string) { Version, Commit, BuildTime = v, c, b }(Version, Commit, BuildTime)
+	Version, Commit, BuildTime = "v1.2.0", "abc123", "2024-05-01T10:00:00Z"
+	info := Get()
+	if info.Version != Version || info.Commit != Commit || info.BuildTime != BuildTime {
+		t.Errorf("info = %+v, want the link time values", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("go version = %q, want %q", info.GoVersion, runtime.Version())
+	}
+}
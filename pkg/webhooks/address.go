@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBadScheme        = errors.New("Webhook URL must be http or https")
+	ErrForbiddenAddress = errors.New("Webhook address is not public")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicAddress reports whether the webhooks may be sent to the IP: not
+// loopback, private, link-local (cloud metadata lives there) or the like
+func PublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// CheckURL rejects the URLs webhooks can't be sent to before they are
+// resolved, the dialer of NewClient checks the resolved addresses
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ErrBadScheme
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrBadScheme
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrForbiddenAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !PublicAddress(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// denyPrivate is the dialer control checking the address after DNS
+// resolution, so a public name pointing to an internal IP is refused too,
+// including on redirects
+func denyPrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !PublicAddress(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// NewClient makes the HTTP client of the dispatcher, it connects only to
+// public addresses and ignores the proxy settings of the environment
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: denyPrivate,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
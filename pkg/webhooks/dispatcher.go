@@ -0,0 +1,290 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var (
+	ErrDispatcherStopped    = errors.New("Webhook dispatcher is stopped")
+	ErrDispatcherNotStarted = errors.New("Webhook dispatcher has no workers")
+	ErrQueueFull            = errors.New("Webhook delivery queue is full")
+)
+
+type job struct {
+	webhook  Webhook
+	delivery Delivery
+}
+
+type retry struct {
+	timer *time.Timer
+	job   job
+}
+
+// Dispatcher delivers events to the subscribed webhooks in background
+// workers, retrying failed deliveries with exponential backoff
+type Dispatcher struct {
+	Repo        WebhookRepo
+	Client      *http.Client
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Logger      *zap.SugaredLogger
+
+	queue   chan job
+	done    chan struct{}
+	retries map[string]retry
+	workers int
+	stopped bool
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+func NewDispatcher(repo WebhookRepo, logger *zap.SugaredLogger) *Dispatcher {
+	return &Dispatcher{
+		Repo:        repo,
+		Client:      NewClient(10 * time.Second),
+		MaxAttempts: 5,
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Minute,
+		Logger:      logger,
+		queue:       make(chan job, 1024),
+		done:        make(chan struct{}),
+		retries:     map[string]retry{},
+	}
+}
+
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) Start(workers int) {
+	d.mu.Lock()
+	d.workers += workers
+	d.mu.Unlock()
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for {
+				select {
+				case j := <-d.queue:
+					d.deliver(j)
+				case <-d.done:
+					// finish the deliveries queued before stop
+					for {
+						select {
+						case j := <-d.queue:
+							d.deliver(j)
+						default:
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+}
+
+// Stop waits for the queued deliveries, the scheduled retries are moved into
+// the dead-letter list
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	close(d.done)
+	pending := make([]job, 0, len(d.retries))
+	for _, r := range d.retries {
+		if r.timer.Stop() {
+			pending = append(pending, r.job)
+		}
+	}
+	d.retries = map[string]retry{}
+	d.mu.Unlock()
+	d.wg.Wait()
+	for _, j := range pending {
+		j.delivery.Status = StatusDead
+		j.delivery.Updated = time.Now().Format("2006-01-02T15:04:05.000")
+		if err := d.Repo.SaveDelivery(j.delivery); err != nil && !errors.Is(err, ErrNoWebhook) {
+			d.Logger.Errorf("Save webhook delivery %v error: %v", j.delivery.ID, err)
+		}
+	}
+}
+
+func (d *Dispatcher) enqueue(j job) error {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return ErrDispatcherStopped
+	}
+	delete(d.retries, j.delivery.ID)
+	d.mu.Unlock()
+	select {
+	case d.queue <- j:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Dispatch creates a delivery for every webhook subscribed to the event,
+// a webhook failing doesn't stop the others
+func (d *Dispatcher) Dispatch(event, category string, data interface{}) error {
+	webhooks, err := d.Repo.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, wh := range webhooks {
+		if !wh.Match(event, category) {
+			continue
+		}
+		err = d.dispatch(wh, event, category, data)
+		if err != nil {
+			d.Logger.Errorf("Dispatch event %v to webhook %v error: %v", event, wh.ID, err)
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) dispatch(wh Webhook, event, category string, data interface{}) error {
+	id, err := RandomID()
+	if err != nil {
+		return err
+	}
+	now := time.Now().Format("2006-01-02T15:04:05.000")
+	body, err := json.Marshal(Payload{
+		ID:       id,
+		Event:    event,
+		Category: category,
+		Created:  now,
+		Data:     data,
+	})
+	if err != nil {
+		return err
+	}
+	delivery := Delivery{
+		ID:        id,
+		WebhookID: wh.ID,
+		Event:     event,
+		Payload:   body,
+		Status:    StatusPending,
+		Created:   now,
+		Updated:   now,
+	}
+	err = d.Repo.SaveDelivery(delivery)
+	if err != nil {
+		return err
+	}
+	err = d.enqueue(job{webhook: wh, delivery: delivery})
+	if err != nil {
+		// the owner finds the delivery in the dead-letter list
+		delivery.Status = StatusDead
+		delivery.Error = err.Error()
+		_ = d.Repo.SaveDelivery(delivery)
+	}
+	return err
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	backoff := d.BaseBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > d.MaxBackoff {
+		return d.MaxBackoff
+	}
+	return backoff
+}
+
+func (d *Dispatcher) send(wh Webhook, delivery Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(wh.Secret, delivery.Payload))
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) deliver(j job) {
+	delivery := j.delivery
+	delivery.Attempts++
+	code, err := d.send(j.webhook, delivery)
+	delivery.ResponseCode = code
+	delivery.Updated = time.Now().Format("2006-01-02T15:04:05.000")
+	switch {
+	case err == nil:
+		delivery.Status = StatusDelivered
+		delivery.Error = ""
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = StatusDead
+		delivery.Error = err.Error()
+		d.Logger.Warnf("Webhook delivery %v to %v is dead after %v attempts: %v", delivery.ID, j.webhook.URL, delivery.Attempts, err)
+	default:
+		delivery.Error = err.Error()
+		backoff := d.backoff(delivery.Attempts)
+		next := job{webhook: j.webhook, delivery: delivery}
+		d.mu.Lock()
+		if !d.stopped {
+			d.retries[delivery.ID] = retry{
+				timer: time.AfterFunc(backoff, func() {
+					if err := d.enqueue(next); err != nil {
+						next.delivery.Status = StatusDead
+						_ = d.Repo.SaveDelivery(next.delivery)
+						d.Logger.Warnf("Webhook delivery %v was not retried: %v", next.delivery.ID, err)
+					}
+				}),
+				job: next,
+			}
+		} else {
+			delivery.Status = StatusDead
+		}
+		d.mu.Unlock()
+	}
+	if err := d.Repo.SaveDelivery(delivery); err != nil && !errors.Is(err, ErrNoWebhook) {
+		d.Logger.Errorf("Save webhook delivery %v error: %v", delivery.ID, err)
+	}
+}
+
+// Ping fails until the workers are started and after Stop
+func (d *Dispatcher) Ping(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return ErrDispatcherStopped
+	}
+	if d.workers == 0 {
+		return ErrDispatcherNotStarted
+	}
+	return nil
+}
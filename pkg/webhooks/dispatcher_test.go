@@ -0,0 +1,142 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// staleRepo lists a webhook deleted after GetAll, saving its delivery fails
+type staleRepo struct {
+	*WebhookMemoryRepository
+	stale Webhook
+}
+
+func (repo staleRepo) GetAll() ([]Webhook, error) {
+	all, err := repo.WebhookMemoryRepository.GetAll()
+	return append([]Webhook{repo.stale}, all...), err
+}
+
+func waitStatus(t *testing.T, repo WebhookRepo, id, status string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		log, err := repo.GetDeliveries(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(log) == 1 && log[0].Status == status {
+			return log[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("delivery of webhook %v is not %v", id, status)
+	return Delivery{}
+}
+
+func TestDispatchContinuesAfterFailedWebhook(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	mem := NewWebhookMemoryRepository()
+	wh := &Webhook{URL: srv.URL}
+	if err := mem.Add(wh); err != nil {
+		t.Fatal(err)
+	}
+	repo := staleRepo{WebhookMemoryRepository: mem, stale: Webhook{ID: "deleted", URL: srv.URL}}
+	d := NewDispatcher(repo, zap.NewNop().Sugar())
+	// the test server listens on loopback
+	d.Client = srv.Client()
+	d.Start(1)
+	defer d.Stop()
+
+	if err := d.Dispatch(EventPostCreated, "music", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, repo, wh.ID, StatusDelivered)
+	if got := hits.Load(); got != 1 {
+		t.Errorf("hits = %v, want 1", got)
+	}
+}
+
+func TestDispatcherRefusesLoopback(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	repo := NewWebhookMemoryRepository()
+	wh := &Webhook{URL: srv.URL}
+	if err := repo.Add(wh); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDispatcher(repo, zap.NewNop().Sugar())
+	d.MaxAttempts = 1
+	d.Start(1)
+	defer d.Stop()
+
+	if err := d.Dispatch(EventPostCreated, "music", nil); err != nil {
+		t.Fatal(err)
+	}
+	delivery := waitStatus(t, repo, wh.ID, StatusDead)
+	if delivery.ResponseCode != 0 || delivery.Error == "" {
+		t.Errorf("delivery = %+v, want a dial error", delivery)
+	}
+	if got := hits.Load(); got != 0 {
+		t.Errorf("hits = %v, want 0", got)
+	}
+}
+
+func TestPublicAddress(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":            true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"::1":                false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"fe80::1":            false,
+		"fd00::1":            false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"::ffff:127.0.0.1":   false,
+		"::ffff:203.0.113.9": true,
+	}
+	for addr, want := range cases {
+		if got := PublicAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("PublicAddress(%v) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	cases := map[string]error{
+		"https://example.com/hook":    nil,
+		"http://example.com:8080/":    nil,
+		"ftp://example.com/hook":      ErrBadScheme,
+		"file:///etc/passwd":          ErrBadScheme,
+		"example.com/hook":            ErrBadScheme,
+		"http://localhost/hook":       ErrForbiddenAddress,
+		"http://127.0.0.1:8080/":      ErrForbiddenAddress,
+		"http://[::1]/":               ErrForbiddenAddress,
+		"http://169.254.169.254/meta": ErrForbiddenAddress,
+		"http://10.0.0.1/":            ErrForbiddenAddress,
+	}
+	for raw, want := range cases {
+		if err := CheckURL(raw); !errors.Is(err, want) {
+			t.Errorf("CheckURL(%v) = %v, want %v", raw, err, want)
+		}
+	}
+}
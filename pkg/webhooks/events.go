@@ -0,0 +1,48 @@
+package webhooks
+
+import (
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/posts"
+)
+
+type CommentData struct {
+	PostID  string        `json:"postId"`
+	Comment posts.Comment `json:"comment"`
+}
+
+// VoteData doesn't tell who voted, the votes are private to the site
+type VoteData struct {
+	PostID           string `json:"postId"`
+	Vote             int8   `json:"vote"`
+	Score            int64  `json:"score"`
+	UpvotePercentage uint8  `json:"upvotePercentage"`
+}
+
+// Handle is the domain events subscriber of the dispatcher
+func (d *Dispatcher) Handle(event events.Event) error {
+	switch e := event.(type) {
+	case events.PostCreated:
+		return d.Dispatch(EventPostCreated, e.Post.Category, e.Post)
+	case events.CommentAdded:
+		data := CommentData{
+			PostID:  e.Post.ID,
+			Comment: e.Comment,
+		}
+		return d.Dispatch(EventCommentAdded, e.Post.Category, data)
+	case events.CommentDeleted:
+		data := map[string]string{"postId": e.Post.ID, "commentId": e.CommentID}
+		return d.Dispatch(EventCommentDeleted, e.Post.Category, data)
+	case events.Voted:
+		data := VoteData{
+			PostID:           e.Post.ID,
+			Vote:             e.Vote,
+			Score:            e.Post.Score,
+			UpvotePercentage: e.Post.UpvotePercentage,
+		}
+		return d.Dispatch(EventVoted, e.Post.Category, data)
+	case events.PostDeleted:
+		data := map[string]string{"postId": e.Post.ID}
+		return d.Dispatch(EventPostDeleted, e.Post.Category, data)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package webhooks
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-uuid"
+	"myredditclone/pkg/apperrors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoWebhook       = apperrors.New(apperrors.ErrNotFound, "Current webhook doesn't exist")
+	ErrTooManyWebhooks = apperrors.New(apperrors.ErrConflict, fmt.Sprintf("A user can't have more than %v webhooks", MaxPerOwner))
+)
+
+const (
+	// MaxPerOwner is how many webhooks a user can have
+	MaxPerOwner = 20
+	// MaxDeliveries is how many last deliveries of a webhook are kept,
+	// the older ones are dropped with their dead letters
+	MaxDeliveries = 100
+)
+
+var _ WebhookRepo = NewWebhookMemoryRepository()
+
+// deliveryLog is the ring of the last deliveries of a webhook
+type deliveryLog struct {
+	items []Delivery
+	next  int            //slot of the next delivery once the ring is full
+	slots map[string]int //by delivery ID
+}
+
+func newDeliveryLog() *deliveryLog {
+	return &deliveryLog{slots: map[string]int{}}
+}
+
+func (log *deliveryLog) save(item Delivery) {
+	if i, ok := log.slots[item.ID]; ok {
+		log.items[i] = item
+		return
+	}
+	if len(log.items) < MaxDeliveries {
+		log.slots[item.ID] = len(log.items)
+		log.items = append(log.items, item)
+		return
+	}
+	delete(log.slots, log.items[log.next].ID)
+	log.items[log.next] = item
+	log.slots[item.ID] = log.next
+	log.next = (log.next + 1) % MaxDeliveries
+}
+
+// all returns the deliveries from the oldest
+func (log *deliveryLog) all() []Delivery {
+	res := make([]Delivery, 0, len(log.items))
+	res = append(res, log.items[log.next:]...)
+	return append(res, log.items[:log.next]...)
+}
+
+type WebhookMemoryRepository struct {
+	data       map[string]Webhook
+	deliveries map[string]*deliveryLog
+	mu         sync.RWMutex
+}
+
+func NewWebhookMemoryRepository() *WebhookMemoryRepository {
+	return &WebhookMemoryRepository{
+		data:       map[string]Webhook{},
+		deliveries: map[string]*deliveryLog{},
+	}
+}
+
+func RandomID() (string, error) {
+	randomID, err := uuid.GenerateRandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", randomID), nil
+}
+
+func (repo *WebhookMemoryRepository) Add(item *Webhook) error {
+	id, err := RandomID()
+	if err != nil {
+		return err
+	}
+	item.ID = id
+	item.Created = time.Now().Format("2006-01-02T15:04:05.000")
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	owned := 0
+	for _, v := range repo.data {
+		if v.Owner == item.Owner {
+			owned++
+		}
+	}
+	if owned >= MaxPerOwner {
+		return ErrTooManyWebhooks
+	}
+	repo.data[item.ID] = *item
+	repo.deliveries[item.ID] = newDeliveryLog()
+	return nil
+}
+
+func (repo *WebhookMemoryRepository) GetAll() ([]Webhook, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	res := make([]Webhook, 0, len(repo.data))
+	for _, v := range repo.data {
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func (repo *WebhookMemoryRepository) GetByOwner(login string) ([]Webhook, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	res := make([]Webhook, 0)
+	for _, v := range repo.data {
+		if v.Owner == login {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func (repo *WebhookMemoryRepository) GetByID(id string) (Webhook, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	wh, ok := repo.data[id]
+	if !ok {
+		return Webhook{}, ErrNoWebhook
+	}
+	return wh, nil
+}
+
+func (repo *WebhookMemoryRepository) Delete(id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	_, ok := repo.data[id]
+	if !ok {
+		return ErrNoWebhook
+	}
+	delete(repo.data, id)
+	delete(repo.deliveries, id)
+	return nil
+}
+
+func (repo *WebhookMemoryRepository) DeleteByOwner(login string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for id, wh := range repo.data {
+		if wh.Owner == login {
+			delete(repo.data, id)
+			delete(repo.deliveries, id)
+		}
+	}
+	return nil
+}
+
+// SaveDelivery adds the delivery into the log or replaces its previous state
+func (repo *WebhookMemoryRepository) SaveDelivery(item Delivery) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if _, ok := repo.data[item.WebhookID]; !ok {
+		return ErrNoWebhook
+	}
+	repo.deliveries[item.WebhookID].save(item)
+	return nil
+}
+
+func (repo *WebhookMemoryRepository) GetDeliveries(webhookID string) ([]Delivery, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	if _, ok := repo.data[webhookID]; !ok {
+		return nil, ErrNoWebhook
+	}
+	return repo.deliveries[webhookID].all(), nil
+}
+
+func (repo *WebhookMemoryRepository) GetDeadLetters(login string) ([]Delivery, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	res := make([]Delivery, 0)
+	for id, wh := range repo.data {
+		if wh.Owner != login {
+			continue
+		}
+		for _, d := range repo.deliveries[id].all() {
+			if d.Status == StatusDead {
+				res = append(res, d)
+			}
+		}
+	}
+	return res, nil
+}
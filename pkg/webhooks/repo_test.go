@@ -0,0 +1,119 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+	"myredditclone/pkg/events"
+	"myredditclone/pkg/posts"
+)
+
+func addWebhook(t *testing.T, repo *WebhookMemoryRepository, owner string) Webhook {
+	t.Helper()
+	wh := &Webhook{Owner: owner, URL: "https://example.com/hook"}
+	if err := repo.Add(wh); err != nil {
+		t.Fatal(err)
+	}
+	return *wh
+}
+
+func TestDeliveryLogKeepsTheLast(t *testing.T) {
+	repo := NewWebhookMemoryRepository()
+	wh := addWebhook(t, repo, "alice")
+	total := MaxDeliveries + 10
+	for i := 0; i < total; i++ {
+		d := Delivery{ID: fmt.Sprint(i), WebhookID: wh.ID, Status: StatusPending}
+		if err := repo.SaveDelivery(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the state of a kept delivery is replaced, not added
+	last := Delivery{ID: fmt.Sprint(total - 1), WebhookID: wh.ID, Status: StatusDead}
+	if err := repo.SaveDelivery(last); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := repo.GetDeliveries(wh.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != MaxDeliveries {
+		t.Fatalf("%v deliveries kept, want %v", len(log), MaxDeliveries)
+	}
+	for i, d := range log {
+		if want := fmt.Sprint(i + 10); d.ID != want {
+			t.Fatalf("delivery %v is %v, want %v", i, d.ID, want)
+		}
+	}
+	if log[len(log)-1].Status != StatusDead {
+		t.Errorf("last delivery = %+v, want dead", log[len(log)-1])
+	}
+	dead, err := repo.GetDeadLetters("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].ID != last.ID {
+		t.Errorf("dead letters = %+v, want %v", dead, last.ID)
+	}
+
+	if err := repo.SaveDelivery(Delivery{ID: "x", WebhookID: "deleted"}); !errors.Is(err, ErrNoWebhook) {
+		t.Errorf("delivery of an unknown webhook: error = %v, want ErrNoWebhook", err)
+	}
+}
+
+func TestWebhooksPerOwner(t *testing.T) {
+	repo := NewWebhookMemoryRepository()
+	for i := 0; i < MaxPerOwner; i++ {
+		addWebhook(t, repo, "alice")
+	}
+	if err := repo.Add(&Webhook{Owner: "alice"}); !errors.Is(err, ErrTooManyWebhooks) {
+		t.Fatalf("webhook over the limit: error = %v, want ErrTooManyWebhooks", err)
+	}
+	addWebhook(t, repo, "bob")
+
+	own, err := repo.GetByOwner("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(own[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	addWebhook(t, repo, "alice")
+}
+
+// The vote payload goes to third parties, it must not tell who voted
+func TestVotePayloadHidesVoter(t *testing.T) {
+	repo := NewWebhookMemoryRepository()
+	wh := addWebhook(t, repo, "alice")
+	d := NewDispatcher(repo, zap.NewNop().Sugar())
+
+	post := posts.Post{ID: "1", Category: "music", Score: 2, Votes: []posts.Vote{{User: "7", Vote: 1}}}
+	if err := d.Handle(events.Voted{Post: post, UserID: "7", Vote: 1}); err != nil {
+		t.Fatal(err)
+	}
+	log, err := repo.GetDeliveries(wh.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 {
+		t.Fatalf("deliveries = %+v, want one", log)
+	}
+	var payload struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(log[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"postId": "1", "vote": 1.0, "score": 2.0, "upvotePercentage": 0.0}
+	if len(payload.Data) != len(want) {
+		t.Fatalf("vote data = %v, want %v", payload.Data, want)
+	}
+	for k, v := range want {
+		if payload.Data[k] != v {
+			t.Errorf("vote data %v = %v, want %v", k, payload.Data[k], v)
+		}
+	}
+}
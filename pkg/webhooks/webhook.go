@@ -0,0 +1,78 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"myredditclone/pkg/events"
+)
+
+const (
+	EventPostCreated    = events.NamePostCreated
+	EventPostDeleted    = events.NamePostDeleted
+	EventCommentAdded   = events.NameCommentAdded
+	EventCommentDeleted = events.NameCommentDeleted
+	EventVoted          = events.NameVoted
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+type Webhook struct {
+	ID       string   `json:"id"`
+	Owner    string   `json:"owner"` //Login of the user
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret,omitempty"`
+	Category string   `json:"category,omitempty"` //empty for all categories
+	Events   []string `json:"events,omitempty"`   //empty for all events
+	Created  string   `json:"created"`
+}
+
+func (wh Webhook) Match(event, category string) bool {
+	if wh.Category != "" && wh.Category != category {
+		return false
+	}
+	if len(wh.Events) == 0 {
+		return true
+	}
+	for _, e := range wh.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type Payload struct {
+	ID       string      `json:"id"`
+	Event    string      `json:"event"`
+	Category string      `json:"category"`
+	Created  string      `json:"created"`
+	Data     interface{} `json:"data"`
+}
+
+type Delivery struct {
+	ID           string          `json:"id"`
+	WebhookID    string          `json:"webhookId"`
+	Event        string          `json:"event"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"responseCode,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Created      string          `json:"created"`
+	Updated      string          `json:"updated"`
+}
+
+type WebhookRepo interface {
+	Add(item *Webhook) error
+	GetAll() ([]Webhook, error)
+	GetByOwner(login string) ([]Webhook, error)
+	GetByID(id string) (Webhook, error)
+	Delete(id string) error
+	DeleteByOwner(login string) error
+	SaveDelivery(item Delivery) error
+	GetDeliveries(webhookID string) ([]Delivery, error)
+	GetDeadLetters(login string) ([]Delivery, error)
+}